@@ -1,8 +1,11 @@
 package dynamodb
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 type AttributeValue struct {
@@ -13,10 +16,14 @@ type AttributeValue struct {
 	NULL *bool
 	M    AttributeValueMap
 	L    []*AttributeValue
+	SS   []*string
+	NS   []*string
+	BS   [][]byte
 }
 
 func (a *AttributeValue) IsValid() bool {
-	return a.B != nil || a.BOOL != nil || a.S != nil || a.N != nil || a.NULL != nil || a.M != nil || a.L != nil
+	return a.B != nil || a.BOOL != nil || a.S != nil || a.N != nil || a.NULL != nil || a.M != nil || a.L != nil ||
+		a.SS != nil || a.NS != nil || a.BS != nil
 }
 
 func (a *AttributeValue) Type() AttributeValueType {
@@ -35,6 +42,12 @@ func (a *AttributeValue) Type() AttributeValueType {
 		return M
 	case a.L != nil:
 		return L
+	case a.SS != nil:
+		return SS
+	case a.NS != nil:
+		return NS
+	case a.BS != nil:
+		return BS
 	default:
 		return INVALID_ATTRIBUTEVALUE_TYPE
 	}
@@ -68,13 +81,324 @@ func (a *AttributeValue) MarshalJSON() ([]byte, error) {
 		} else {
 			return json.Marshal(struct{ L []*AttributeValue }{a.L})
 		}
+	case a.SS != nil:
+		return json.Marshal(struct{ SS []*string }{a.SS})
+	case a.NS != nil:
+		return json.Marshal(struct{ NS []*string }{a.NS})
+	case a.BS != nil:
+		return json.Marshal(struct{ BS [][]byte }{a.BS})
 	default:
 		return nil, fmt.Errorf("cannot serialize an AttributeValue with no values set")
 	}
 }
 
+// UnmarshalJSON parses an AttributeValue from dynamo's JSON format. It is
+// stricter than decoding into the struct would be: there must be exactly one
+// type descriptor, with a non-null value, descriptors are case sensitive,
+// numbers must be valid, sets must not be empty and NULL must be true. Errors
+// are a DecodeError with the path of the offending value.
+func (a *AttributeValue) UnmarshalJSON(data []byte) error {
+	out, err := parseAttribute(newJSONDecoder(data))
+	if err != nil {
+		return err
+	}
+	*a = out
+	return nil
+}
+
 type AttributeValueMap map[string]*AttributeValue
 
+// UnmarshalJSON parses each attribute as AttributeValue.UnmarshalJSON does,
+// adding the attribute name to the path of any error.
+func (m *AttributeValueMap) UnmarshalJSON(data []byte) error {
+	dec := newJSONDecoder(data)
+	tok, err := dec.Token()
+	if err != nil {
+		return DecodeError{Message: err.Error()}
+	}
+	if tok == nil {
+		*m = nil
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return DecodeError{Message: fmt.Sprintf("AttributeValueMap must be a JSON object, not %s", tokenKind(tok))}
+	}
+	out, err := parseAttributeMap(dec)
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// The parse functions read dynamo JSON from the tokens of a json.Decoder in a
+// single pass, so nested values aren't parsed again at every level.
+
+func newJSONDecoder(data []byte) *json.Decoder {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec
+}
+
+// parseAttribute parses the next value of dec as an AttributeValue.
+func parseAttribute(dec *json.Decoder) (AttributeValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return AttributeValue{}, DecodeError{Message: err.Error()}
+	}
+	if tok != json.Delim('{') {
+		return AttributeValue{}, DecodeError{Message: fmt.Sprintf("AttributeValue must be a JSON object, not %s", tokenKind(tok))}
+	}
+	if !dec.More() {
+		return AttributeValue{}, DecodeError{Message: "AttributeValue has no type descriptor"}
+	}
+
+	name, err := readKey(dec)
+	if err != nil {
+		return AttributeValue{}, err
+	}
+	out, err := parseDescriptor(dec, name)
+	if _, unknown := err.(unknownDescriptorError); err != nil && !unknown {
+		return out, err
+	}
+	if dec.More() {
+		// report every descriptor, rather than whichever came first
+		names := []string{name}
+		for dec.More() {
+			next, err := readKey(dec)
+			if err != nil {
+				return out, err
+			}
+			names = append(names, next)
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return out, DecodeError{Message: err.Error()}
+			}
+		}
+		sort.Strings(names)
+		return out, DecodeError{Message: fmt.Sprintf("AttributeValue must have exactly one type descriptor, found %d: %s", len(names), strings.Join(names, ", "))}
+	}
+	if err != nil {
+		return out, DecodeError{Message: err.Error()}
+	}
+	return out, expectDelim(dec, '}')
+}
+
+// unknownDescriptorError is returned by parseDescriptor once it has skipped
+// the value of an unknown descriptor, so that parseAttribute can still report
+// a value with several descriptors.
+type unknownDescriptorError string
+
+func (e unknownDescriptorError) Error() string {
+	return fmt.Sprintf("unknown type descriptor %q", string(e))
+}
+
+// unmarshalDescriptor parses the value of a single type descriptor.
+func unmarshalDescriptor(name string, raw json.RawMessage) (AttributeValue, error) {
+	out, err := parseDescriptor(newJSONDecoder(raw), name)
+	if _, unknown := err.(unknownDescriptorError); unknown {
+		return out, DecodeError{Message: err.Error()}
+	}
+	return out, err
+}
+
+// parseDescriptor parses the next value of dec as the value of the type
+// descriptor name.
+func parseDescriptor(dec *json.Decoder, name string) (AttributeValue, error) {
+	var out AttributeValue
+	typeError := func(err error) error {
+		return DecodeError{Message: fmt.Sprintf("invalid %s value: %s", name, err)}
+	}
+	nullError := func() error {
+		return DecodeError{Message: fmt.Sprintf("%s must not be null", name)}
+	}
+	decode := func(v interface{}) error {
+		if err := dec.Decode(v); err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				return typeError(err)
+			}
+			return DecodeError{Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch name {
+	case "B":
+		if err := decode(&out.B); err != nil {
+			return out, err
+		}
+		if out.B == nil {
+			return out, nullError()
+		}
+	case "BOOL":
+		if err := decode(&out.BOOL); err != nil {
+			return out, err
+		}
+		if out.BOOL == nil {
+			return out, nullError()
+		}
+	case "S":
+		if err := decode(&out.S); err != nil {
+			return out, err
+		}
+		if out.S == nil {
+			return out, nullError()
+		}
+	case "N":
+		if err := decode(&out.N); err != nil {
+			return out, err
+		}
+		if out.N == nil {
+			return out, nullError()
+		}
+		if err := checkNumber(*out.N); err != nil {
+			return out, DecodeError{Message: err.Error(), AttributeType: N}
+		}
+	case "NULL":
+		if err := decode(&out.NULL); err != nil {
+			return out, err
+		}
+		if out.NULL == nil {
+			return out, nullError()
+		}
+		if !*out.NULL {
+			return out, DecodeError{Message: "NULL must be true", AttributeType: NULL}
+		}
+	case "M", "L":
+		tok, err := dec.Token()
+		if err != nil {
+			return out, DecodeError{Message: err.Error()}
+		}
+		if tok == nil {
+			return out, nullError()
+		}
+		if name == "M" {
+			if tok != json.Delim('{') {
+				return out, typeError(fmt.Errorf("%s is not an object", tokenKind(tok)))
+			}
+			out.M, err = parseAttributeMap(dec)
+			return out, err
+		}
+		if tok != json.Delim('[') {
+			return out, typeError(fmt.Errorf("%s is not an array", tokenKind(tok)))
+		}
+		out.L = []*AttributeValue{}
+		for i := 0; dec.More(); i++ {
+			attr, err := parseAttribute(dec)
+			if err != nil {
+				return out, decodeErrorAt(err, indexPath(i), nil, "")
+			}
+			out.L = append(out.L, &attr)
+		}
+		return out, expectDelim(dec, ']')
+	case "SS", "NS":
+		var elems []*string
+		if err := decode(&elems); err != nil {
+			return out, err
+		}
+		if elems == nil {
+			return out, nullError()
+		}
+		if len(elems) == 0 {
+			return out, DecodeError{Message: fmt.Sprintf("%s must not be empty", name)}
+		}
+		for i, e := range elems {
+			if e == nil {
+				return out, DecodeError{Message: fmt.Sprintf("%s elements must not be null", name), Path: indexPath(i)}
+			}
+			if name == "NS" {
+				if err := checkNumber(*e); err != nil {
+					return out, DecodeError{Message: err.Error(), Path: indexPath(i), AttributeType: NS}
+				}
+			}
+		}
+		if name == "SS" {
+			out.SS = elems
+		} else {
+			out.NS = elems
+		}
+	case "BS":
+		if err := decode(&out.BS); err != nil {
+			return out, err
+		}
+		if out.BS == nil {
+			return out, nullError()
+		}
+		if len(out.BS) == 0 {
+			return out, DecodeError{Message: "BS must not be empty"}
+		}
+		for i, e := range out.BS {
+			if e == nil {
+				return out, DecodeError{Message: "BS elements must not be null", Path: indexPath(i)}
+			}
+		}
+	default:
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return out, DecodeError{Message: err.Error()}
+		}
+		return out, unknownDescriptorError(name)
+	}
+	return out, nil
+}
+
+// parseAttributeMap parses the attributes of a JSON object whose opening brace
+// has been read.
+func parseAttributeMap(dec *json.Decoder) (AttributeValueMap, error) {
+	out := AttributeValueMap{}
+	for dec.More() {
+		k, err := readKey(dec)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := parseAttribute(dec)
+		if err != nil {
+			return nil, decodeErrorAt(err, k, nil, "")
+		}
+		out[k] = &attr
+	}
+	return out, expectDelim(dec, '}')
+}
+
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", DecodeError{Message: err.Error()}
+	}
+	return tok.(string), nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return DecodeError{Message: err.Error()}
+	}
+	if tok != want {
+		return DecodeError{Message: fmt.Sprintf("expected %s, found %v", want, tok)}
+	}
+	return nil
+}
+
+// tokenKind describes a JSON token for errors.
+func tokenKind(tok json.Token) string {
+	switch tok := tok.(type) {
+	case nil:
+		return "null"
+	case json.Delim:
+		if tok == '[' {
+			return "an array"
+		}
+		return "an object"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%v", tok)
+}
+
 type AttributeValueType int
 
 func (a AttributeValueType) String() string {
@@ -93,6 +417,12 @@ func (a AttributeValueType) String() string {
 		return "NULL"
 	case a == S:
 		return "S"
+	case a == BS:
+		return "BS"
+	case a == NS:
+		return "NS"
+	case a == SS:
+		return "SS"
 	case a == INVALID_ATTRIBUTEVALUE_TYPE:
 		return "INVALID"
 	default:
@@ -121,6 +451,12 @@ func (a *AttributeValueType) UnmarshalJSON(d []byte) error {
 		*a = NULL
 	case `"S"`:
 		*a = S
+	case `"BS"`:
+		*a = BS
+	case `"NS"`:
+		*a = NS
+	case `"SS"`:
+		*a = SS
 	default:
 		*a = INVALID_ATTRIBUTEVALUE_TYPE
 		return fmt.Errorf("aws.dynamodb: unknown AttributeValueType %s", s)
@@ -138,4 +474,10 @@ const (
 	N
 	NULL
 	S
+
+	// Set types are appended so the values of the scalar and document
+	// types above stay stable.
+	BS
+	NS
+	SS
 )
@@ -4,6 +4,7 @@ import (
 	. "backflip/aws/dynamodb"
 	"backflip/tools/testutils"
 	"encoding/json"
+	"strings"
 	"testing"
 
 	ck "gopkg.in/check.v1"
@@ -20,6 +21,7 @@ var HasLen = ck.HasLen
 var ErrorMatches = ck.ErrorMatches
 var FitsTypeOf = ck.FitsTypeOf
 var PanicMatches = ck.PanicMatches
+var Matches = ck.Matches
 
 func TestValue(t *testing.T) {
 	_ = testutils.GetTestFlags()
@@ -182,3 +184,93 @@ func (s *AttributeValueSuite) TestEmptyValue(c *ck.C) {
 	_, err := json.Marshal(&v)
 	c.Assert(err, ErrorMatches, ".*cannot serialize.*with no values.*")
 }
+
+func (s *AttributeValueSuite) TestSetValues(c *ck.C) {
+	data := []byte(`{"SS":["foo","bar"]}`)
+	v := s.getValue(c, data)
+	c.Assert(v.IsValid(), Equals, true)
+	c.Assert(v.Type(), Equals, SS)
+	c.Assert(v.SS, HasLen, 2)
+	c.Assert(*v.SS[0], Equals, "foo")
+	c.Assert(*v.SS[1], Equals, "bar")
+	c.Assert(string(s.encodeValue(c, v)), Equals, string(data))
+
+	data = []byte(`{"NS":["1","2.5"]}`)
+	v = s.getValue(c, data)
+	c.Assert(v.IsValid(), Equals, true)
+	c.Assert(v.Type(), Equals, NS)
+	c.Assert(v.NS, HasLen, 2)
+	c.Assert(*v.NS[1], Equals, "2.5")
+	c.Assert(string(s.encodeValue(c, v)), Equals, string(data))
+
+	data = []byte(`{"BS":["Zm9v","YmFy"]}`)
+	v = s.getValue(c, data)
+	c.Assert(v.IsValid(), Equals, true)
+	c.Assert(v.Type(), Equals, BS)
+	c.Assert(v.BS, HasLen, 2)
+	c.Assert(string(v.BS[0]), Equals, "foo")
+	c.Assert(string(s.encodeValue(c, v)), Equals, string(data))
+}
+
+func (s *AttributeValueSuite) TestSetTypeJSON(c *ck.C) {
+	for _, t := range []AttributeValueType{SS, NS, BS} {
+		d := s.encodeValue(c, t)
+		var t2 AttributeValueType
+		c.Assert(json.Unmarshal(d, &t2), IsNil)
+		c.Assert(t2, Equals, t)
+	}
+}
+
+func (s *AttributeValueSuite) TestStrictUnmarshal(c *ck.C) {
+	for _, t := range []struct {
+		data string
+		err  string
+	}{
+		{`{}`, "aws.dynamodb.DecodeError: AttributeValue has no type descriptor"},
+		{`{"S":"a","N":"1"}`, "aws.dynamodb.DecodeError: AttributeValue must have exactly one type descriptor, found 2: N, S"},
+		{`{"X":1}`, `aws.dynamodb.DecodeError: unknown type descriptor "X"`},
+		{`{"X":{"S":"a"},"S":"a"}`, "aws.dynamodb.DecodeError: AttributeValue must have exactly one type descriptor, found 2: S, X"},
+		{`{"M":{"a":{"M":{}}},"L":[{"S":"a"}]}`, "aws.dynamodb.DecodeError: AttributeValue must have exactly one type descriptor, found 2: L, M"},
+		{`{"L":{}}`, "aws.dynamodb.DecodeError: invalid L value: an object is not an array"},
+		{`{"s":"a"}`, `aws.dynamodb.DecodeError: unknown type descriptor "s"`},
+		{`{"S":null}`, "aws.dynamodb.DecodeError: S must not be null"},
+		{`{"S":1}`, "aws.dynamodb.DecodeError: invalid S value: .*"},
+		{`{"N":"1x"}`, `aws.dynamodb.DecodeError: malformed number "1x"`},
+		{`{"N":"1E999"}`, "aws.dynamodb.DecodeError: number 1E999 is out of range"},
+		{`{"NS":["1",null]}`, `aws.dynamodb.DecodeError: \[1\]: NS elements must not be null`},
+		{`{"NS":["1","NaN"]}`, `aws.dynamodb.DecodeError: \[1\]: malformed number "NaN"`},
+		{`{"M":{"a":{"L":[{"S":"x"},{"Q":1}]}}}`, `aws.dynamodb.DecodeError: a\[1\]: unknown type descriptor "Q"`},
+		{`{"SS":[]}`, "aws.dynamodb.DecodeError: SS must not be empty"},
+		{`{"NS":[]}`, "aws.dynamodb.DecodeError: NS must not be empty"},
+		{`{"BS":[]}`, "aws.dynamodb.DecodeError: BS must not be empty"},
+		{`{"M":{"a":{"L":[{"BS":["AA==",null]}]}}}`, `aws.dynamodb.DecodeError: a\[0\]\[1\]: BS elements must not be null`},
+		{`{"NULL":false}`, "aws.dynamodb.DecodeError: NULL must be true"},
+		{`{"M":{"a":{"NULL":false}}}`, "aws.dynamodb.DecodeError: a: NULL must be true"},
+		{`{"M":[]}`, "aws.dynamodb.DecodeError: invalid M value: .*"},
+		{`[]`, "aws.dynamodb.DecodeError: AttributeValue must be a JSON object, not an array"},
+		{`null`, "aws.dynamodb.DecodeError: AttributeValue must be a JSON object, not null"},
+	} {
+		_, err := DecodeToAttributeValue([]byte(t.data))
+		c.Check(err, ErrorMatches, t.err, ck.Commentf("%s", t.data))
+		c.Check(err, FitsTypeOf, DecodeError{})
+	}
+
+	var item AttributeValueMap
+	err := json.Unmarshal([]byte(`{"a":{"S":"x"},"b":{"N":"abc"}}`), &item)
+	c.Check(err, ErrorMatches, `aws.dynamodb.DecodeError: b: malformed number "abc"`)
+	c.Check(err.(DecodeError).Path, Equals, "b")
+
+	// deeply nested values are parsed in a single pass
+	deep := strings.Repeat(`{"L":[`, 1000) + `{"S":"x"}` + strings.Repeat(`]}`, 1000)
+	attr, err := DecodeToAttributeValue([]byte(deep))
+	c.Assert(err, IsNil)
+	for i := 0; i < 1000; i++ {
+		c.Assert(attr.L, HasLen, 1)
+		attr = attr.L[0]
+	}
+	c.Check(*attr.S, Equals, "x")
+
+	err = json.Unmarshal([]byte(`[]`), &item)
+	c.Check(err, ErrorMatches, "aws.dynamodb.DecodeError: AttributeValueMap must be a JSON object, not an array")
+	c.Check(err, FitsTypeOf, DecodeError{})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"backflip/aws/dynamodb/internal/tags"
+	"go/types"
+	"reflect"
+	"sort"
+)
+
+// The functions in this file find the fields of a struct with the same rules
+// as typeFields in the dynamodb package, working on go/types types instead of
+// reflect types. Tags are parsed by the same package, and the example package
+// checks the generated methods against the reflective encoder.
+
+// A field represents a single field found in a struct.
+type field struct {
+	name      string
+	goName    string
+	tag       bool
+	index     []int
+	vars      []*types.Var // the embedded fields leading to the field, then the field
+	typ       types.Type
+	omitEmpty bool
+	quoted    bool
+	set       bool
+	nullEmpty bool
+	binary    bool
+	unixTime  bool
+}
+
+// byName sorts field by name, breaking ties with depth,
+// then breaking ties with "name came from json tag", then
+// breaking ties with index sequence.
+type byName []field
+
+func (x byName) Len() int { return len(x) }
+
+func (x byName) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+
+func (x byName) Less(i, j int) bool {
+	if x[i].name != x[j].name {
+		return x[i].name < x[j].name
+	}
+	if len(x[i].index) != len(x[j].index) {
+		return len(x[i].index) < len(x[j].index)
+	}
+	if x[i].tag != x[j].tag {
+		return x[i].tag
+	}
+	return byIndex(x).Less(i, j)
+}
+
+// byIndex sorts field by index sequence.
+type byIndex []field
+
+func (x byIndex) Len() int { return len(x) }
+
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+// typeFields returns the fields encoded for the struct type t, by a
+// breadth-first search of t and the structs it embeds.
+func typeFields(t types.Type, tagName string) []field {
+	// Anonymous fields to explore at the current level and the next.
+	current := []field{}
+	next := []field{{typ: t}}
+
+	// Count of queued names for current level and the next.
+	count := map[types.Type]int{}
+	nextCount := map[types.Type]int{}
+
+	// Types already visited at an earlier level.
+	visited := map[types.Type]bool{}
+
+	// Fields found.
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[types.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			st := f.typ.Underlying().(*types.Struct)
+			for i := 0; i < st.NumFields(); i++ {
+				sf := st.Field(i)
+				if !sf.Exported() {
+					continue
+				}
+				tag := tags.Lookup(reflect.StructTag(st.Tag(i)), tagName)
+				if tag == "-" {
+					continue
+				}
+				name, opts := tags.Parse(tag)
+				index := append(append([]int(nil), f.index...), i)
+				vars := append(append([]*types.Var(nil), f.vars...), sf)
+
+				ft := sf.Type()
+				if p, ok := ft.(*types.Pointer); ok {
+					// Follow pointer.
+					ft = p.Elem()
+				}
+				_, isStruct := ft.Underlying().(*types.Struct)
+
+				// Record found field and index sequence.
+				if name != "" || !sf.Embedded() || !isStruct {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name()
+					}
+					fields = append(fields, field{
+						name:      name,
+						goName:    sf.Name(),
+						tag:       tagged,
+						index:     index,
+						vars:      vars,
+						typ:       ft,
+						omitEmpty: opts.Contains("omitempty"),
+						quoted:    opts.Contains("string"),
+						set:       opts.Contains("set"),
+						nullEmpty: opts.Contains("nullempty"),
+						binary:    opts.Contains("binary"),
+						unixTime:  opts.Contains("unixtime"),
+					})
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// Record new anonymous struct to explore in next round.
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{index: index, vars: vars, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Sort(byName(fields))
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with tags are promoted.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Sort(byIndex(fields))
+	return fields
+}
+
+// dominantField returns the field that dominates the others with the same
+// name, by Go's embedding rules modified by tags, or false if there is none.
+func dominantField(fields []field) (field, bool) {
+	length := len(fields[0].index)
+	tagged := -1 // Index of first tagged field.
+	for i, f := range fields {
+		if len(f.index) > length {
+			fields = fields[:i]
+			break
+		}
+		if f.tag {
+			if tagged >= 0 {
+				return field{}, false
+			}
+			tagged = i
+		}
+	}
+	if tagged >= 0 {
+		return fields[tagged], true
+	}
+	if len(fields) > 1 {
+		return field{}, false
+	}
+	return fields[0], true
+}
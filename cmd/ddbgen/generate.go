@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	dynamodbPath = "backflip/aws/dynamodb"
+	codegenPath  = dynamodbPath + "/codegen"
+)
+
+// generatedPrefix starts the first line of the files ddbgen writes. They are
+// left out when loading a package, so methods from an earlier run don't change
+// how the types are seen.
+const generatedPrefix = "// Code generated by ddbgen"
+
+const (
+	marshalMethod   = "MarshalDynamoDBAttributeValue"
+	unmarshalMethod = "UnmarshalDynamoDBAttributeValue"
+)
+
+// generate returns the source of a file holding the methods for the named
+// struct types of the package in dir, and warnings about fields that will use
+// reflection.
+func generate(dir string, names []string, tagName string) ([]byte, []string, error) {
+	pkg, typeErr, err := loadPackage(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g := &generator{
+		pkg:     pkg,
+		typeErr: typeErr,
+		tagName: tagName,
+		listed:  map[*types.Named]bool{},
+		imports: map[string]string{dynamodbPath: "dynamodb"},
+		warned:  map[string]bool{},
+	}
+	var listed []*types.Named
+	for _, name := range names {
+		tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, nil, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+		}
+		n, ok := tn.Type().(*types.Named)
+		if _, isStruct := tn.Type().Underlying().(*types.Struct); !ok || !isStruct {
+			return nil, nil, fmt.Errorf("%s is not a struct type", name)
+		}
+		g.listed[n] = true
+		listed = append(listed, n)
+	}
+	for _, n := range listed {
+		if err := g.genType(n); err != nil {
+			return nil, g.warnings, err
+		}
+	}
+
+	header := "-type " + strings.Join(names, ",")
+	if tagName != "dynamodb" {
+		header += " -tag " + tagName
+	}
+	src, err := g.source(header)
+	return src, g.warnings, err
+}
+
+// loadPackage parses and type checks the package in dir, without its tests and
+// generated files. The package may not compile until the methods are
+// generated, so the first type error is returned separately and only reported
+// if a field's type couldn't be determined.
+func loadPackage(dir string) (*types.Package, error, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(f.Comments) > 0 && f.Comments[0].Pos() < f.Package && strings.HasPrefix(f.Comments[0].List[0].Text, generatedPrefix) {
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	var typeErr error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if typeErr == nil {
+				typeErr = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+	return pkg, typeErr, nil
+}
+
+type generator struct {
+	pkg     *types.Package
+	typeErr error
+	tagName string
+	listed  map[*types.Named]bool
+	imports map[string]string // package path to name
+	buf     bytes.Buffer
+	tmp     int
+
+	// where is the struct field being generated, for errors and warnings.
+	where    string
+	warnings []string
+	warned   map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) source(header string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s %s; DO NOT EDIT.\n\n", generatedPrefix, header)
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", g.pkg.Name())
+	if g.refersTo("codegen") {
+		g.imports[codegenPath] = "codegen"
+	}
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if name := g.imports[path]; name != filepath.Base(path) {
+			fmt.Fprintf(&out, "%s ", name)
+		}
+		fmt.Fprintf(&out, "%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %s", err)
+	}
+	return src, nil
+}
+
+// refersTo reports whether the generated code uses a name from the package
+// imported as name. Unlike a text search, it skips names in string literals,
+// such as attribute names.
+func (g *generator) refersTo(name string) bool {
+	src := g.buf.Bytes()
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile("", -1, len(src)), src, nil, 0)
+	prev := ""
+	for {
+		_, tok, lit := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return false
+		case tok == token.PERIOD && prev == name:
+			return true
+		}
+		prev = lit
+	}
+}
+
+// newVar returns a new temporary variable name.
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+func (g *generator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %s", g.where, fmt.Sprintf(format, args...))
+}
+
+func (g *generator) warn(t types.Type) {
+	w := fmt.Sprintf("%s: %s is encoded and decoded with reflection", g.where, reflectName(t))
+	if !g.warned[w] {
+		g.warned[w] = true
+		g.warnings = append(g.warnings, w)
+	}
+}
+
+// typeString returns t as written in the generated file, importing the
+// packages it refers to.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.imports[p.Path()] = p.Name()
+		return p.Name()
+	})
+}
+
+// reflectName returns the name reflect gives t, as used in error messages.
+func reflectName(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
+
+// toBasic converts x of type t to the basic type name.
+func toBasic(name string, t types.Type, x string) string {
+	if b, ok := t.(*types.Basic); ok && b.Name() == name {
+		return x
+	}
+	return name + "(" + x + ")"
+}
+
+// fromBasic converts x of the basic type name to t.
+func (g *generator) fromBasic(name string, t types.Type, x string) string {
+	if b, ok := t.(*types.Basic); ok && b.Name() == name {
+		return x
+	}
+	return g.typeString(t) + "(" + x + ")"
+}
+
+// fromBytes converts the []byte x to t.
+func (g *generator) fromBytes(t types.Type, x string) string {
+	if types.Identical(t, types.NewSlice(types.Typ[types.Uint8])) {
+		return x
+	}
+	return g.typeString(t) + "(" + x + ")"
+}
+
+// addrOf returns the address of the addressable expression x.
+func addrOf(x string) string {
+	if strings.HasPrefix(x, "(*") && strings.HasSuffix(x, ")") {
+		return x[2 : len(x)-1]
+	}
+	return "&" + x
+}
+
+// blankUnused replaces the loop variables names declared at offset start of
+// the generated code with _ if the code after them doesn't use them.
+func (g *generator) blankUnused(start int, names ...string) {
+	code := string(g.buf.Bytes()[start:])
+	decl := code[:strings.Index(code, "\n")]
+	for _, name := range names {
+		re := regexp.MustCompile(`\b` + name + `\b`)
+		if len(re.FindAllStringIndex(code, 2)) < 2 {
+			decl = re.ReplaceAllString(decl, "_")
+		}
+	}
+	decl = strings.Replace(decl, "for _, _ := range", "for range", 1)
+	decl = strings.Replace(decl, ", _ :=", " :=", 1)
+	code = decl + code[strings.Index(code, "\n"):]
+	g.buf.Truncate(start)
+	g.buf.WriteString(code)
+}
+
+// errorAt returns the expression adding path to err.
+func errorAt(path []string) string {
+	return "codegen.ErrorAt(err, " + strings.Join(path, ", ") + ")"
+}
+
+func appendPath(path []string, elem string) []string {
+	return append(append([]string(nil), path...), elem)
+}
+
+func (g *generator) zero(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		default:
+			return "0"
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		return "nil"
+	}
+	return g.typeString(t) + "{}"
+}
+
+// hasMethod reports whether values of type t have the method name, or if
+// addressable, whether pointers to them do. The listed types are treated as
+// already having the generated methods.
+func (g *generator) hasMethod(t types.Type, name string, addressable bool) bool {
+	if n, ok := t.(*types.Named); ok && g.listed[n] {
+		if name == marshalMethod || (name == unmarshalMethod && addressable) {
+			return true
+		}
+	}
+	if types.NewMethodSet(t).Lookup(nil, name) != nil {
+		return true
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface:
+		return false
+	}
+	return addressable && types.NewMethodSet(types.NewPointer(t)).Lookup(nil, name) != nil
+}
+
+func isNamed(t types.Type, path, name string) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == path && n.Obj().Name() == name
+}
+
+// isNumberType reports whether t is one of the types the dynamodb package
+// encodes as arbitrary precision numbers.
+func isNumberType(t types.Type) bool {
+	return isNamed(t, dynamodbPath, "Number") || isNamed(t, "math/big", "Int") ||
+		isNamed(t, "math/big", "Float") || isNamed(t, "math/big", "Rat")
+}
+
+func isTime(t types.Type) bool {
+	return isNamed(t, "time", "Time")
+}
+
+func isBytes(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	return ok && types.Identical(s.Elem(), types.Typ[types.Uint8])
+}
+
+func basicInfo(t types.Type) types.BasicInfo {
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		return b.Info()
+	}
+	return 0
+}
+
+func basicKind(t types.Type) types.BasicKind {
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		return b.Kind()
+	}
+	return types.Invalid
+}
+
+func isEmptyStruct(t types.Type) bool {
+	st, ok := t.Underlying().(*types.Struct)
+	return ok && st.NumFields() == 0
+}
+
+// bitSize returns the size of an integer or float kind, or 0 for the
+// platform's int size.
+func bitSize(k types.BasicKind) int {
+	switch k {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64:
+		return 64
+	}
+	return 0
+}
+
+// checkType returns an error if t couldn't be type checked.
+func (g *generator) checkType(t types.Type) error {
+	if basicKind(t) == types.Invalid {
+		if _, ok := t.Underlying().(*types.Basic); ok {
+			return g.errorf("unknown type: %v", g.typeErr)
+		}
+	}
+	return nil
+}
+
+func (g *generator) genType(n *types.Named) error {
+	name := n.Obj().Name()
+	fields := typeFields(n, g.tagName)
+
+	g.printf("\n// %s encodes v as an M.\n", marshalMethod)
+	g.printf("func (v %s) %s() (*dynamodb.AttributeValue, error) {\n", name, marshalMethod)
+	g.printf("item := make(dynamodb.AttributeValueMap, %d)\n", len(fields))
+	for _, f := range fields {
+		g.where = name + "." + f.goName
+		if err := g.encodeField(f); err != nil {
+			return err
+		}
+	}
+	g.printf("return &dynamodb.AttributeValue{M: item}, nil\n}\n")
+
+	g.printf("\n// %s decodes an M into v. NULL zeroes v, and other\n// attributes are ignored.\n", unmarshalMethod)
+	g.printf("func (v *%s) %s(attr *dynamodb.AttributeValue) error {\n", name, unmarshalMethod)
+	g.printf("if attr.M == nil {\nif attr.NULL != nil {\n*v = %s{}\n}\nreturn nil\n}\n", name)
+	if len(fields) > 0 {
+		start := g.buf.Len()
+		g.printf("for k, a := range attr.M {\nswitch k {\n")
+		for _, f := range fields {
+			g.where = name + "." + f.goName
+			if err := g.decodeField(f); err != nil {
+				return err
+			}
+		}
+		g.printf("}\n}\n")
+		g.blankUnused(start, "a")
+	}
+	g.printf("return nil\n}\n")
+	return nil
+}
+
+// fieldExpr returns the selector of f from v, and the conditions under which
+// the embedded pointers on the way to it are set.
+func fieldExpr(f field) (string, []string) {
+	x := "v"
+	var guards []string
+	for i, sf := range f.vars {
+		x += "." + sf.Name()
+		if _, ok := sf.Type().(*types.Pointer); ok && i < len(f.vars)-1 {
+			guards = append(guards, x+" != nil")
+		}
+	}
+	return x, guards
+}
+
+// emptyCond returns the condition under which x of type t is empty, as for the
+// omitempty and nullempty options, or "" if it never is.
+func emptyCond(t types.Type, x string) string {
+	switch u := t.Underlying().(type) {
+	case *types.Array, *types.Map, *types.Slice:
+		return "len(" + x + ") == 0"
+	case *types.Pointer, *types.Interface:
+		return x + " == nil"
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return "len(" + x + ") == 0"
+		case u.Info()&types.IsBoolean != 0:
+			return "!" + x
+		case u.Info()&types.IsNumeric != 0:
+			return x + " == 0"
+		}
+	}
+	return ""
+}
+
+// negate returns the opposite of a condition returned by emptyCond.
+func negate(cond string) string {
+	switch {
+	case strings.HasPrefix(cond, "!"):
+		return cond[1:]
+	case strings.HasSuffix(cond, " == 0"):
+		return strings.TrimSuffix(cond, " == 0") + " != 0"
+	default:
+		return strings.TrimSuffix(cond, " == nil") + " != nil"
+	}
+}
+
+func (g *generator) encodeField(f field) error {
+	t := f.vars[len(f.vars)-1].Type()
+	if err := g.checkType(t); err != nil {
+		return err
+	}
+	x, guards := fieldExpr(f)
+	path := []string{strconv.Quote(f.name)}
+
+	closing := len(guards)
+	if len(guards) > 0 {
+		g.printf("if %s {\n", strings.Join(guards, " && "))
+	}
+	empty := emptyCond(t, x)
+	if f.omitEmpty && empty != "" {
+		g.printf("if %s {\n", negate(empty))
+		closing++
+	}
+
+	var attr string
+	var err error
+	if f.nullEmpty && empty != "" {
+		attr = g.newVar("a")
+		g.printf("var %s *dynamodb.AttributeValue\nif %s {\n%s = codegen.NullValue()\n} else {\n", attr, empty, attr)
+		var e string
+		if e, err = g.encodeOptions(f, t, x, path); err != nil {
+			return err
+		}
+		g.printf("%s = %s\n}\n", attr, e)
+	} else if attr, err = g.encodeOptions(f, t, x, path); err != nil {
+		return err
+	}
+	g.printf("item[%q] = %s\n", f.name, attr)
+	g.printf("%s", strings.Repeat("}\n", closing))
+	return nil
+}
+
+// encodeOptions emits the encoding of field f, of type t, applying its tag
+// options, and returns the expression holding the result.
+func (g *generator) encodeOptions(f field, t types.Type, x string, path []string) (string, error) {
+	switch {
+	case f.set:
+		return g.encodeSet(t, x, path)
+	case f.unixTime:
+		return g.encodeDeref(t, x, func(t types.Type, x string) (string, error) {
+			if !isTime(t) {
+				return "", g.errorf("unixtime option requires a time.Time, not %s", reflectName(t))
+			}
+			return "codegen.UnixTimeValue(" + x + ")", nil
+		})
+	case f.binary:
+		return g.encodeDeref(t, x, g.encodeBinary)
+	case f.quoted && isQuotable(t):
+		return g.encodeDeref(t, x, func(t types.Type, x string) (string, error) {
+			return g.encodeQuoted(t, x, path)
+		})
+	}
+	return g.encodeValue(t, x, true, path)
+}
+
+// encodeDeref emits the encoding of x, or of what it points to, if it is a
+// pointer, with NULL for nil.
+func (g *generator) encodeDeref(t types.Type, x string, enc func(t types.Type, x string) (string, error)) (string, error) {
+	p, ok := t.Underlying().(*types.Pointer)
+	if !ok {
+		return enc(t, x)
+	}
+	attr := g.newVar("a")
+	g.printf("var %s *dynamodb.AttributeValue\nif %s == nil {\n%s = codegen.NullValue()\n} else {\n", attr, x, attr)
+	e, err := enc(p.Elem(), "(*"+x+")")
+	if err != nil {
+		return "", err
+	}
+	g.printf("%s = %s\n}\n", attr, e)
+	return attr, nil
+}
+
+func isBinary(t types.Type) bool {
+	return basicInfo(t)&types.IsString != 0 || isBytes(t)
+}
+
+func (g *generator) encodeBinary(t types.Type, x string) (string, error) {
+	if !isBinary(t) || g.hasMethod(t, "MarshalText", true) {
+		return "", g.errorf("binary option on %s is not supported by ddbgen, only strings and byte slices", reflectName(t))
+	}
+	if basicInfo(t)&types.IsString != 0 {
+		return "codegen.BytesValue([]byte(" + x + "))", nil
+	}
+	return "codegen.BytesValue(" + g.bytesOf(t, x) + ")", nil
+}
+
+func (g *generator) bytesOf(t types.Type, x string) string {
+	if types.Identical(t, types.NewSlice(types.Typ[types.Uint8])) {
+		return x
+	}
+	return "[]byte(" + x + ")"
+}
+
+// isQuotable reports whether the string tag option applies to t.
+func isQuotable(t types.Type) bool {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return basicInfo(t)&(types.IsBoolean|types.IsInteger|types.IsFloat) != 0
+}
+
+func (g *generator) encodeQuoted(t types.Type, x string, path []string) (string, error) {
+	g.imports["strconv"] = "strconv"
+	info := basicInfo(t)
+	switch {
+	case info&types.IsBoolean != 0:
+		return "codegen.StringValue(strconv.FormatBool(" + toBasic("bool", t, x) + "))", nil
+	case info&types.IsUnsigned != 0:
+		return "codegen.StringValue(strconv.FormatUint(" + toBasic("uint64", t, x) + ", 10))", nil
+	case info&types.IsInteger != 0:
+		return "codegen.StringValue(strconv.FormatInt(" + toBasic("int64", t, x) + ", 10))", nil
+	case info&types.IsFloat != 0:
+		s := g.newVar("s")
+		g.printf("%s, err := codegen.FormatFloat(%s, %d)\nif err != nil {\nreturn nil, %s\n}\n", s, toBasic("float64", t, x), bitSize(basicKind(t)), errorAt(path))
+		return "codegen.StringValue(" + s + ")", nil
+	}
+	return "", g.errorf("string option on %s is not supported", reflectName(t))
+}
+
+// setElement returns the set type for elements of type t, and the function
+// formatting one as a string, or "" if t can't be a set element.
+func (g *generator) setElement(t types.Type) (string, func(x string) string) {
+	if isNumberType(t) {
+		return "", nil
+	}
+	info := basicInfo(t)
+	switch {
+	case info&types.IsString != 0:
+		return "dynamodb.SS", func(x string) string { return toBasic("string", t, x) }
+	case info&types.IsUnsigned != 0:
+		g.imports["strconv"] = "strconv"
+		return "dynamodb.NS", func(x string) string { return "strconv.FormatUint(" + toBasic("uint64", t, x) + ", 10)" }
+	case info&types.IsInteger != 0:
+		g.imports["strconv"] = "strconv"
+		return "dynamodb.NS", func(x string) string { return "strconv.FormatInt(" + toBasic("int64", t, x) + ", 10)" }
+	case info&types.IsFloat != 0:
+		return "dynamodb.NS", nil
+	case isBytes(t):
+		return "dynamodb.BS", func(x string) string { return "string(" + x + ")" }
+	}
+	return "", nil
+}
+
+// encodeSet emits the encoding of x as a set, for the set tag option and maps
+// of the form map[T]struct{}.
+func (g *generator) encodeSet(t types.Type, x string, path []string) (string, error) {
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return g.encodeDeref(t, x, func(elem types.Type, x string) (string, error) {
+			return g.encodeSet(elem, x, path)
+		})
+	}
+
+	var elem types.Type
+	sorted := false
+	switch u := t.Underlying().(type) {
+	case *types.Map:
+		elem, sorted = u.Key(), true
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Array:
+		elem = u.Elem()
+	default:
+		return "", g.errorf("set option requires a slice, array or map, not %s", reflectName(t))
+	}
+	setType, format := g.setElement(elem)
+	if setType == "" {
+		return "", g.errorf("set option on %s is not supported by ddbgen", reflectName(t))
+	}
+
+	elems, e := g.newVar("elems"), g.newVar("e")
+	g.printf("%s := make([]string, 0, len(%s))\n", elems, x)
+	if sorted {
+		g.printf("for %s := range %s {\n", e, x)
+	} else {
+		g.printf("for _, %s := range %s {\n", e, x)
+	}
+	if format == nil {
+		s := g.newVar("s")
+		g.printf("%s, err := codegen.FormatFloat(%s, %d)\nif err != nil {\nreturn nil, %s\n}\n", s, toBasic("float64", elem, e), bitSize(basicKind(elem)), errorAt(path))
+		g.printf("%s = append(%s, %s)\n}\n", elems, elems, s)
+	} else {
+		g.printf("%s = append(%s, %s)\n}\n", elems, elems, format(e))
+	}
+	return fmt.Sprintf("codegen.SetValue(%s, %s, %t)", setType, elems, sorted), nil
+}
+
+// encodeValue emits the encoding of x, of type t, and returns the expression
+// holding the result. addressable is whether x can have its address taken,
+// which like the reflective encoder enables methods with pointer receivers.
+func (g *generator) encodeValue(t types.Type, x string, addressable bool, path []string) (string, error) {
+	if err := g.checkType(t); err != nil {
+		return "", err
+	}
+	if isNumberType(t) {
+		return g.encodeReflect(t, x, addressable, path), nil
+	}
+	switch t.Underlying().(type) {
+	case *types.Pointer:
+		return g.encodeDeref(t, x, func(elem types.Type, x string) (string, error) {
+			return g.encodeValue(elem, x, true, path)
+		})
+	case *types.Interface:
+		return g.encodeReflect(t, x, addressable, path), nil
+	}
+
+	switch {
+	case g.hasMethod(t, marshalMethod, addressable):
+		return g.encodeCall("codegen.MarshalerValue", x, addressable, path), nil
+	case g.hasMethod(t, "MarshalJSON", addressable):
+		return g.encodeReflect(t, x, addressable, path), nil
+	case g.hasMethod(t, "MarshalText", addressable):
+		return g.encodeCall("codegen.TextValue", x, addressable, path), nil
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return "codegen.BoolValue(" + toBasic("bool", t, x) + ")", nil
+		case info&types.IsUnsigned != 0:
+			return "codegen.UintValue(" + toBasic("uint64", t, x) + ")", nil
+		case info&types.IsInteger != 0:
+			return "codegen.IntValue(" + toBasic("int64", t, x) + ")", nil
+		case info&types.IsFloat != 0:
+			attr := g.newVar("a")
+			g.printf("%s, err := codegen.FloatValue(%s, %d)\nif err != nil {\nreturn nil, %s\n}\n", attr, toBasic("float64", t, x), bitSize(u.Kind()), errorAt(path))
+			return attr, nil
+		case info&types.IsString != 0:
+			return "codegen.StringValue(" + toBasic("string", t, x) + ")", nil
+		}
+
+	case *types.Slice:
+		if isBytes(t) {
+			return "codegen.BytesValue(" + g.bytesOf(t, x) + ")", nil
+		}
+		if basicKind(u.Elem()) == types.Uint8 {
+			// a []T of a named byte type, which can't be converted to []byte
+			return g.encodeReflect(t, x, addressable, path), nil
+		}
+		attr := g.newVar("a")
+		g.printf("var %s *dynamodb.AttributeValue\nif len(%s) == 0 {\n%s = codegen.NullValue()\n} else {\n", attr, x, attr)
+		l, err := g.encodeList(u.Elem(), x, true, path)
+		if err != nil {
+			return "", err
+		}
+		g.printf("%s = %s\n}\n", attr, l)
+		return attr, nil
+
+	case *types.Array:
+		return g.encodeList(u.Elem(), x, addressable, path)
+
+	case *types.Map:
+		if isEmptyStruct(u.Elem()) {
+			if setType, _ := g.setElement(u.Key()); setType != "" {
+				return g.encodeSet(t, x, path)
+			}
+		}
+		if basicInfo(u.Key())&types.IsString == 0 {
+			return g.encodeReflect(t, x, addressable, path), nil
+		}
+		attr, m, k, e := g.newVar("a"), g.newVar("m"), g.newVar("k"), g.newVar("e")
+		key := toBasic("string", u.Key(), k)
+		g.printf("var %s *dynamodb.AttributeValue\nif %s == nil {\n%s = codegen.NullValue()\n} else {\n", attr, x, attr)
+		g.printf("%s := make(dynamodb.AttributeValueMap, len(%s))\nfor %s, %s := range %s {\n", m, x, k, e, x)
+		ev, err := g.encodeValue(u.Elem(), e, false, appendPath(path, key))
+		if err != nil {
+			return "", err
+		}
+		g.printf("%s[%s] = %s\n}\n%s = &dynamodb.AttributeValue{M: %s}\n}\n", m, key, ev, attr, m)
+		return attr, nil
+
+	case *types.Struct:
+		if u.NumFields() == 0 {
+			return "&dynamodb.AttributeValue{M: dynamodb.AttributeValueMap{}}", nil
+		}
+		return g.encodeReflect(t, x, addressable, path), nil
+	}
+	return "", g.errorf("unsupported type %s", reflectName(t))
+}
+
+// encodeList emits the encoding of the elements of the slice or array x as an L.
+func (g *generator) encodeList(elem types.Type, x string, addressable bool, path []string) (string, error) {
+	l, i := g.newVar("l"), g.newVar("i")
+	g.printf("%s := make([]*dynamodb.AttributeValue, len(%s))\nfor %s := range %s {\n", l, x, i, x)
+	e, err := g.encodeValue(elem, x+"["+i+"]", addressable, appendPath(path, i))
+	if err != nil {
+		return "", err
+	}
+	g.printf("%s[%s] = %s\n}\n", l, i, e)
+	return "&dynamodb.AttributeValue{L: " + l + "}", nil
+}
+
+// encodeCall emits a call to the function fn encoding x, through its
+// address if it has one.
+func (g *generator) encodeCall(fn, x string, addressable bool, path []string) string {
+	if addressable {
+		x = addrOf(x)
+	}
+	attr := g.newVar("a")
+	g.printf("%s, err := %s(%s)\nif err != nil {\nreturn nil, %s\n}\n", attr, fn, x, errorAt(path))
+	return attr
+}
+
+// encodeReflect emits the encoding of x with the reflective encoder.
+func (g *generator) encodeReflect(t types.Type, x string, addressable bool, path []string) string {
+	g.warn(t)
+	if !addressable {
+		v := g.newVar("x")
+		g.printf("%s := %s\n", v, x)
+		x = v
+	}
+	return g.encodeCall("dynamodb.EncodeToAttributeValue", x, true, path)
+}
+
+func (g *generator) decodeField(f field) error {
+	sf := f.vars[len(f.vars)-1]
+	t := sf.Type()
+	if err := g.checkType(t); err != nil {
+		return err
+	}
+	g.printf("case %q:\n", f.name)
+	x := "v"
+	for _, v := range f.vars[:len(f.vars)-1] {
+		x += "." + v.Name()
+		if p, ok := v.Type().(*types.Pointer); ok {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typeString(p.Elem()))
+		}
+	}
+	x += "." + sf.Name()
+	path := []string{strconv.Quote(f.name)}
+
+	switch {
+	case f.unixTime:
+		return g.decodeUnixTime(t, "a", x, path)
+	case f.binary:
+		if err := g.decodeBinary(t, x); err != nil {
+			return err
+		}
+		if err := g.decodeValue(t, "a", x, path); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case f.quoted && isQuotable(t):
+		elem := t
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			elem = p.Elem()
+		}
+		q := g.newVar("q")
+		g.printf("if a.S != nil {\n%s, err := codegen.DecodeQuoted(*a.S, %t, %q)\nif err != nil {\nreturn %s\n}\n", q, basicInfo(elem)&types.IsBoolean != 0, reflectName(elem), errorAt(path))
+		if err := g.decodeValue(t, q, x, path); err != nil {
+			return err
+		}
+		g.printf("} else {\n")
+		if err := g.decodeValue(t, "a", x, path); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	return g.decodeValue(t, "a", x, path)
+}
+
+func (g *generator) decodeUnixTime(t types.Type, a, x string, path []string) error {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		if !isTime(p.Elem()) {
+			return g.errorf("unixtime option requires a time.Time, not %s", reflectName(p.Elem()))
+		}
+		g.printf("if %s.NULL != nil {\n%s = nil\n} else {\n%s = new(%s)\n", a, x, x, g.typeString(p.Elem()))
+		if err := g.decodeUnixTime(p.Elem(), a, "(*"+x+")", path); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	if !isTime(t) {
+		return g.errorf("unixtime option requires a time.Time, not %s", reflectName(t))
+	}
+	tm := g.newVar("t")
+	g.printf("if %s.N != nil {\n%s, err := codegen.DecodeUnixTime(*%s.N)\nif err != nil {\nreturn %s\n}\n%s = %s\n", a, tm, a, errorAt(path), x, tm)
+	g.printf("} else if %s.NULL != nil {\n%s = %s{}\n}\n", a, x, g.typeString(t))
+	return nil
+}
+
+// decodeBinary emits the start of decoding a B attribute into x, for the binary
+// tag option. Other attributes are decoded as usual in the else branch it
+// leaves open.
+func (g *generator) decodeBinary(t types.Type, x string) error {
+	elem := t
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		elem = p.Elem()
+	}
+	if !isBinary(elem) || g.hasMethod(elem, "UnmarshalText", true) {
+		return g.errorf("binary option on %s is not supported by ddbgen, only strings and byte slices", reflectName(t))
+	}
+	g.printf("if a.B != nil {\n")
+	if elem != t {
+		g.printf("%s = new(%s)\n", x, g.typeString(elem))
+		x = "(*" + x + ")"
+	}
+	if basicInfo(elem)&types.IsString != 0 {
+		g.printf("%s = %s(a.B)\n", x, g.typeString(elem))
+	} else {
+		g.printf("%s = %s\n", x, g.fromBytes(elem, "a.B"))
+	}
+	g.printf("} else {\n")
+	return nil
+}
+
+// decodeValue emits the decoding of the attribute a into x, of type t.
+func (g *generator) decodeValue(t types.Type, a, x string, path []string) error {
+	if err := g.checkType(t); err != nil {
+		return err
+	}
+	if isNumberType(t) {
+		g.decodeReflect(t, a, x, path)
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		g.printf("if %s.NULL != nil {\n%s = nil\n} else {\n%s = new(%s)\n", a, x, x, g.typeString(u.Elem()))
+		if err := g.decodeValue(u.Elem(), a, "(*"+x+")", path); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	case *types.Interface:
+		g.decodeReflect(t, a, x, path)
+		return nil
+	}
+
+	switch {
+	case g.hasMethod(t, unmarshalMethod, true):
+		g.printf("if err := codegen.DecodeUnmarshaler(%s, %s); err != nil {\nreturn %s\n}\n", a, addrOf(x), errorAt(path))
+		return nil
+	case g.hasMethod(t, "MarshalJSON", true):
+		// the reflective decoder uses json.Unmarshal for json.Marshalers
+		g.decodeReflect(t, a, x, path)
+		return nil
+	case g.hasMethod(t, "UnmarshalText", true):
+		g.printf("if %s.S != nil {\nif err := codegen.DecodeText(*%s.S, %s); err != nil {\nreturn %s\n}\n", a, a, addrOf(x), errorAt(path))
+		g.printf("} else if %s.NULL != nil {\n%s = %s\n}\n", a, x, g.zero(t))
+		return nil
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		info := u.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			g.printf("if %s.BOOL != nil {\n%s = %s\n", a, x, g.fromBasic("bool", t, "*"+a+".BOOL"))
+		case info&types.IsUnsigned != 0:
+			g.decodeNumber(t, "DecodeUint", "uint64", a, x, path)
+		case info&types.IsInteger != 0:
+			g.decodeNumber(t, "DecodeInt", "int64", a, x, path)
+		case info&types.IsFloat != 0:
+			g.decodeNumber(t, "DecodeFloat", "float64", a, x, path)
+		case info&types.IsString != 0:
+			g.printf("if %s.S != nil {\n%s = %s\n", a, x, g.fromBasic("string", t, "*"+a+".S"))
+		default:
+			return g.errorf("unsupported type %s", reflectName(t))
+		}
+		g.printf("} else if %s.NULL != nil {\n%s = %s\n}\n", a, x, g.zero(t))
+		return nil
+
+	case *types.Slice:
+		if isBytes(t) {
+			b := g.newVar("b")
+			g.printf("if %s.B != nil || %s.S != nil {\n%s, err := codegen.DecodeBytes(%s)\nif err != nil {\nreturn %s\n}\n", a, a, b, a, errorAt(path))
+			g.printf("%s = %s\n} else if %s.NULL != nil {\n%s = nil\n}\n", x, g.fromBytes(t, b), a, x)
+			return nil
+		}
+		if basicKind(u.Elem()) == types.Uint8 {
+			g.decodeReflect(t, a, x, path)
+			return nil
+		}
+		return g.decodeList(t, u.Elem(), a, x, false, path)
+
+	case *types.Array:
+		return g.decodeList(t, u.Elem(), a, x, true, path)
+
+	case *types.Map:
+		return g.decodeMap(t, u, a, x, path)
+
+	case *types.Struct:
+		if u.NumFields() == 0 {
+			// nothing to decode
+			return nil
+		}
+		g.decodeReflect(t, a, x, path)
+		return nil
+	}
+	return g.errorf("unsupported type %s", reflectName(t))
+}
+
+// decodeNumber emits the start of decoding an N into x, leaving the if
+// statement open for the NULL case.
+func (g *generator) decodeNumber(t types.Type, fn, basic, a, x string, path []string) {
+	n := g.newVar("n")
+	g.printf("if %s.N != nil {\n%s, err := codegen.%s(*%s.N, %d, %q)\nif err != nil {\nreturn %s\n}\n", a, n, fn, a, bitSize(basicKind(t)), reflectName(t), errorAt(path))
+	g.printf("%s = %s\n", x, g.fromBasic(basic, t, n))
+}
+
+// decodeList emits the decoding of an L, or a set, into the slice or array x.
+func (g *generator) decodeList(t, elem types.Type, a, x string, array bool, path []string) error {
+	l, i, e := g.newVar("l"), g.newVar("i"), g.newVar("e")
+	g.printf("%s := %s\nif %s.SS != nil || %s.NS != nil || %s.BS != nil {\n%s = codegen.SetList(%s)\n}\n", l, a, l, l, l, l, l)
+	g.printf("if %s.NULL != nil || %s.L == nil {\n%s = %s\n} else {\n", l, l, x, g.zero(t))
+	if !array {
+		g.printf("%s = make(%s, len(%s.L))\n", x, g.typeString(t), l)
+	}
+	start := g.buf.Len()
+	g.printf("for %s, %s := range %s.L {\n", i, e, l)
+	if array {
+		g.printf("if %s >= len(%s) {\nbreak\n}\n", i, x)
+	}
+	if err := g.decodeValue(elem, e, x+"["+i+"]", appendPath(path, i)); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	g.blankUnused(start, e)
+	if array {
+		j := g.newVar("i")
+		g.printf("for %s := len(%s.L); %s < len(%s); %s++ {\n%s[%s] = %s\n}\n", j, l, j, x, j, x, j, g.zero(elem))
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// decodeMap emits the decoding of an M, or a set, into the map x.
+func (g *generator) decodeMap(t types.Type, u *types.Map, a, x string, path []string) error {
+	if basicInfo(u.Key())&types.IsString == 0 || g.hasMethod(u.Key(), "UnmarshalText", true) {
+		g.decodeReflect(t, a, x, path)
+		return nil
+	}
+
+	g.printf("switch {\ncase %s.SS != nil || %s.NS != nil || %s.BS != nil:\n", a, a, a)
+	if basicInfo(u.Elem())&types.IsBoolean != 0 || isEmptyStruct(u.Elem()) {
+		member := "true"
+		if isEmptyStruct(u.Elem()) {
+			member = g.typeString(u.Elem()) + "{}"
+		}
+		i, e, k := g.newVar("i"), g.newVar("e"), g.newVar("k")
+		g.printf("if %s == nil {\n%s = make(%s)\n}\n", x, x, g.typeString(t))
+		start := g.buf.Len()
+		g.printf("for %s, %s := range codegen.SetList(%s).L {\nvar %s %s\n", i, e, a, k, g.typeString(u.Key()))
+		if err := g.decodeValue(u.Key(), e, k, appendPath(path, i)); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n", x, k, member)
+		g.blankUnused(start, i, e)
+	} else {
+		// the reflective decoder reports the error
+		g.printDecodeReflect(a, x, path)
+	}
+
+	k, e, val := g.newVar("k"), g.newVar("e"), g.newVar("v")
+	g.printf("case %s.M != nil:\nif %s == nil {\n%s = make(%s)\n}\n", a, x, x, g.typeString(t))
+	start := g.buf.Len()
+	g.printf("for %s, %s := range %s.M {\nvar %s %s\n", k, e, a, val, g.typeString(u.Elem()))
+	if err := g.decodeValue(u.Elem(), e, val, appendPath(path, k)); err != nil {
+		return err
+	}
+	g.printf("%s[%s] = %s\n}\n", x, g.fromBasic("string", u.Key(), k), val)
+	g.blankUnused(start, e)
+	g.printf("case %s.NULL != nil:\n%s = nil\n}\n", a, x)
+	return nil
+}
+
+// decodeReflect emits the decoding of a into x with the reflective decoder.
+func (g *generator) decodeReflect(t types.Type, a, x string, path []string) {
+	g.warn(t)
+	g.printDecodeReflect(a, x, path)
+}
+
+func (g *generator) printDecodeReflect(a, x string, path []string) {
+	g.printf("if err := dynamodb.DecodeAttributeValueToInterface(%s, %s); err != nil {\nreturn %s\n}\n", a, addrOf(x), errorAt(path))
+}
@@ -0,0 +1,202 @@
+package example_test
+
+import (
+	"backflip/aws/dynamodb"
+	"backflip/aws/dynamodb/cmd/ddbgen/internal/example"
+	"backflip/tools/testutils"
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestExample(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	ck.Suite(&ExampleSuite{})
+	ck.TestingT(t)
+}
+
+type ExampleSuite struct {
+}
+
+// The reflect types have the same fields as the generated types, but not their
+// methods, so they are encoded and decoded with reflection. Fields holding the
+// generated types still use the generated methods.
+type (
+	reflectOrder    example.Order
+	reflectLine     example.Line
+	reflectCustomer example.Customer
+	reflectEmbedded example.Embedded
+)
+
+func orders() []example.Order {
+	paid := false
+	expires := time.Unix(1600000000, 0)
+	return []example.Order{
+		{},
+		{
+			ID:        "o1",
+			Customer:  &example.Customer{Name: "ann", Emails: []string{"a@x", "b@x", "a@x"}, Since: 2019},
+			Lines:     []example.Line{{SKU: "s1", Qty: 2, Price: example.Money{Cents: 150, Currency: "USD"}}, {SKU: "s2", Qty: 255, Price: example.Money{Cents: 5, Currency: "EUR"}, Options: map[string]string{"gift": "yes", "wrap": ""}, Parent: &example.Line{SKU: "s1", Price: example.Money{Currency: "USD"}}}},
+			Tags:      []string{"b", "a", "b"},
+			Flags:     map[string]struct{}{"z": {}, "y": {}},
+			Scores:    []float64{1.5, -2, 1e-100},
+			Status:    "open",
+			Total:     -1234,
+			Paid:      &paid,
+			Discount:  0.1,
+			Placed:    time.Unix(1500000000, 0),
+			Expires:   &expires,
+			Updated:   time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+			Note:      "fragile",
+			Signature: []byte{0, 1, 2},
+			Attrs:     map[string]interface{}{"n": 1.5, "s": "x", "l": []interface{}{true, nil}, "nil": nil},
+			Counts:    map[string]int{"a": 1, "b": 0},
+			Window:    [2]int16{-3, 3},
+			Ref:       example.Ref{Kind: "order", ID: "o0"},
+			Internal:  "skipped",
+			Audit:     example.Audit{CreatedBy: "bob", Version: 7},
+			Extra:     &example.Extra{Gift: true},
+		},
+		{
+			ID:      "o2",
+			Lines:   []example.Line{},
+			Tags:    []string{},
+			Flags:   map[string]struct{}{},
+			Attrs:   map[string]interface{}{},
+			Counts:  map[string]int{},
+			Updated: time.Unix(0, 0).UTC(),
+			Extra:   &example.Extra{},
+		},
+	}
+}
+
+func (s *ExampleSuite) TestEncodeMatchesReflection(c *ck.C) {
+	for i, o := range orders() {
+		got, err := o.MarshalDynamoDBAttributeValue()
+		c.Assert(err, ck.IsNil)
+		want, err := dynamodb.EncodeToAttributeValue((*reflectOrder)(&o))
+		c.Assert(err, ck.IsNil)
+		c.Check(got, ck.DeepEquals, want, ck.Commentf("order %d", i))
+
+		for _, l := range o.Lines {
+			got, err := l.MarshalDynamoDBAttributeValue()
+			c.Assert(err, ck.IsNil)
+			want, err := dynamodb.EncodeToAttributeValue((*reflectLine)(&l))
+			c.Assert(err, ck.IsNil)
+			c.Check(got, ck.DeepEquals, want, ck.Commentf("order %d line %s", i, l.SKU))
+		}
+		if o.Customer != nil {
+			got, err := o.Customer.MarshalDynamoDBAttributeValue()
+			c.Assert(err, ck.IsNil)
+			want, err := dynamodb.EncodeToAttributeValue((*reflectCustomer)(o.Customer))
+			c.Assert(err, ck.IsNil)
+			c.Check(got, ck.DeepEquals, want, ck.Commentf("order %d customer", i))
+		}
+	}
+}
+
+func (s *ExampleSuite) TestRoundTrip(c *ck.C) {
+	for i, o := range orders() {
+		attr, err := o.MarshalDynamoDBAttributeValue()
+		c.Assert(err, ck.IsNil)
+
+		var got example.Order
+		c.Assert(got.UnmarshalDynamoDBAttributeValue(attr), ck.IsNil)
+		var want reflectOrder
+		c.Assert(dynamodb.DecodeAttributeValueToInterface(attr, &want), ck.IsNil)
+		c.Check(got, ck.DeepEquals, example.Order(want), ck.Commentf("order %d", i))
+	}
+}
+
+func decodeBoth(c *ck.C, input string, into example.Order) (example.Order, example.Order) {
+	attr, err := dynamodb.DecodeToAttributeValue([]byte(input))
+	c.Assert(err, ck.IsNil)
+
+	got, want := into, reflectOrder(into)
+	gotErr := got.UnmarshalDynamoDBAttributeValue(attr)
+	wantErr := dynamodb.DecodeAttributeValueToInterface(attr, &want)
+	if wantErr == nil {
+		c.Check(gotErr, ck.IsNil, ck.Commentf("%s", input))
+	} else {
+		c.Check(gotErr, ck.ErrorMatches, regexp.QuoteMeta(wantErr.Error()), ck.Commentf("%s", input))
+	}
+	return got, example.Order(want)
+}
+
+func (s *ExampleSuite) TestDecodeMatchesReflection(c *ck.C) {
+	full := orders()[1]
+	for _, input := range []string{
+		`{"NULL":true}`,
+		`{"S":"not an item"}`,
+		`{"M":{}}`,
+		`{"M":{"unknown":{"S":"x"},"id":{"N":"1"},"customer":{"NULL":true},"lines":{"NULL":true},"tags":{"NULL":true},"flags":{"NULL":true},"status":{"NULL":true},"paid":{"NULL":true},"ttl":{"NULL":true},"sig":{"NULL":true},"attrs":{"NULL":true},"window":{"NULL":true},"ref":{"NULL":true},"gift":{"NULL":true}}}`,
+		`{"M":{"tags":{"L":[{"S":"a"},{"N":"1"},{"NULL":true}]},"scores":{"NS":["1","2.5"]},"window":{"NS":["7"]},"flags":{"M":{"a":{"M":{}}}},"counts":{"M":{"a":{"N":"1"},"b":{"S":"x"}}}}}`,
+		`{"M":{"flags":{"L":[{"S":"a"}]},"tags":{"S":"a"},"window":{"L":[{"N":"1"},{"N":"2"},{"N":"3"}]}}}`,
+		`{"M":{"total":{"N":"12"},"paid":{"BOOL":true},"note":{"S":"plain"},"sig":{"S":"AAEC"},"updated":{"NULL":true},"placed":{"NULL":true},"customer":{"M":{"Emails":{"SS":["x"]},"Since":{"S":"x"}}}}}`,
+		`{"M":{"total":{"S":"12"},"paid":{"S":"true"},"lines":{"L":[{"M":{"SKU":{"S":"a"},"Parent":{"M":{"Qty":{"N":"3"}}}}},{"NULL":true}]},"gift":{"BOOL":false},"version":{"N":"3"}}}`,
+		// errors, one per input since attributes are decoded in map order
+		`{"M":{"attrs":{"SS":["a","b"]}}}`,
+		`{"M":{"lines":{"L":[{"M":{}},{"M":{"Qty":{"N":"256"}}}]}}}`,
+		`{"M":{"total":{"S":"x"}}}`,
+		`{"M":{"paid":{"S":"maybe"}}}`,
+		`{"M":{"discount":{"N":"1e100"}}}`,
+		`{"M":{"ref":{"S":"noslash"}}}`,
+		`{"M":{"placed":{"N":"1.5"}}}`,
+		`{"M":{"sig":{"S":"!!"}}}`,
+		`{"M":{"counts":{"M":{"a":{"N":"1.5"}}}}}`,
+		`{"M":{"counts":{"NS":["1"]}}}`,
+		`{"M":{"lines":{"L":[{"M":{"Price":{"S":"bad"}}}]}}}`,
+		`{"M":{"updated":{"B":"bm90IGpzb24="}}}`,
+	} {
+		got, want := decodeBoth(c, input, example.Order{})
+		c.Check(got, ck.DeepEquals, want, ck.Commentf("%s", input))
+		got, want = decodeBoth(c, input, full)
+		c.Check(got, ck.DeepEquals, want, ck.Commentf("%s into full order", input))
+	}
+}
+
+func (s *ExampleSuite) TestEncodeErrorsMatchReflection(c *ck.C) {
+	for _, o := range []example.Order{
+		{Discount: float32(math.Inf(1))},
+		{Scores: []float64{1, math.NaN()}},
+		{Scores: []float64{1e200}},
+		{Lines: []example.Line{{Price: example.Money{Currency: "USD"}}, {SKU: "s2"}}},
+		{Lines: []example.Line{{Price: example.Money{Currency: "USD"}, Parent: &example.Line{}}}},
+		{Attrs: map[string]interface{}{"f": math.Inf(-1)}},
+	} {
+		_, err := o.MarshalDynamoDBAttributeValue()
+		_, want := dynamodb.EncodeToAttributeValue((*reflectOrder)(&o))
+		c.Assert(want, ck.NotNil)
+		c.Check(err, ck.ErrorMatches, regexp.QuoteMeta(want.Error()))
+	}
+}
+
+func (s *ExampleSuite) TestEmbeddedMatchesReflection(c *ck.C) {
+	full := example.Embedded{
+		Name: "top", Override: "o", Dash: "d", Invalid: "i", JSONOnly: "j",
+		Inner:   example.Inner{Name: "hidden", Depth: 2, Label: "hidden"},
+		Labeled: &example.Labeled{Label: "shown"},
+		Left:    example.Left{Dup: "l", Tagged: "l"},
+		Right:   example.Right{Dup: "r", Other: "r"},
+	}
+	for i, e := range []example.Embedded{{}, full} {
+		got, err := e.MarshalDynamoDBAttributeValue()
+		c.Assert(err, ck.IsNil)
+		want, err := dynamodb.EncodeToAttributeValue((*reflectEmbedded)(&e))
+		c.Assert(err, ck.IsNil)
+		c.Check(got, ck.DeepEquals, want, ck.Commentf("value %d", i))
+	}
+
+	c.Check(dynamodb.AttributeNames(full), ck.DeepEquals, []string{"Name", "Override", "-", "Invalid", "json_only", "depth", "Label"})
+
+	attr, err := full.MarshalDynamoDBAttributeValue()
+	c.Assert(err, ck.IsNil)
+	var got example.Embedded
+	c.Assert(got.UnmarshalDynamoDBAttributeValue(attr), ck.IsNil)
+	var want reflectEmbedded
+	c.Assert(dynamodb.DecodeAttributeValueToInterface(attr, &want), ck.IsNil)
+	c.Check(got, ck.DeepEquals, example.Embedded(want))
+}
@@ -0,0 +1,729 @@
+// Code generated by ddbgen -type Order,Line,Customer,Embedded; DO NOT EDIT.
+
+package example
+
+import (
+	"backflip/aws/dynamodb"
+	"backflip/aws/dynamodb/codegen"
+	"strconv"
+	"time"
+)
+
+// MarshalDynamoDBAttributeValue encodes v as an M.
+func (v Order) MarshalDynamoDBAttributeValue() (*dynamodb.AttributeValue, error) {
+	item := make(dynamodb.AttributeValueMap, 22)
+	item["id"] = codegen.StringValue(v.ID)
+	var a1 *dynamodb.AttributeValue
+	if v.Customer == nil {
+		a1 = codegen.NullValue()
+	} else {
+		a2, err := codegen.MarshalerValue(v.Customer)
+		if err != nil {
+			return nil, codegen.ErrorAt(err, "customer")
+		}
+		a1 = a2
+	}
+	item["customer"] = a1
+	var a3 *dynamodb.AttributeValue
+	if len(v.Lines) == 0 {
+		a3 = codegen.NullValue()
+	} else {
+		l4 := make([]*dynamodb.AttributeValue, len(v.Lines))
+		for i5 := range v.Lines {
+			a6, err := codegen.MarshalerValue(&v.Lines[i5])
+			if err != nil {
+				return nil, codegen.ErrorAt(err, "lines", i5)
+			}
+			l4[i5] = a6
+		}
+		a3 = &dynamodb.AttributeValue{L: l4}
+	}
+	item["lines"] = a3
+	elems7 := make([]string, 0, len(v.Tags))
+	for _, e8 := range v.Tags {
+		elems7 = append(elems7, e8)
+	}
+	item["tags"] = codegen.SetValue(dynamodb.SS, elems7, false)
+	elems9 := make([]string, 0, len(v.Flags))
+	for e10 := range v.Flags {
+		elems9 = append(elems9, e10)
+	}
+	item["flags"] = codegen.SetValue(dynamodb.SS, elems9, true)
+	if len(v.Scores) != 0 {
+		elems11 := make([]string, 0, len(v.Scores))
+		for _, e12 := range v.Scores {
+			s13, err := codegen.FormatFloat(e12, 64)
+			if err != nil {
+				return nil, codegen.ErrorAt(err, "scores")
+			}
+			elems11 = append(elems11, s13)
+		}
+		item["scores"] = codegen.SetValue(dynamodb.NS, elems11, false)
+	}
+	if len(v.Status) != 0 {
+		item["status"] = codegen.StringValue(string(v.Status))
+	}
+	item["total"] = codegen.StringValue(strconv.FormatInt(int64(v.Total), 10))
+	var a14 *dynamodb.AttributeValue
+	if v.Paid == nil {
+		a14 = codegen.NullValue()
+	} else {
+		a14 = codegen.StringValue(strconv.FormatBool((*v.Paid)))
+	}
+	item["paid"] = a14
+	var a15 *dynamodb.AttributeValue
+	if v.Discount == 0 {
+		a15 = codegen.NullValue()
+	} else {
+		a16, err := codegen.FloatValue(float64(v.Discount), 32)
+		if err != nil {
+			return nil, codegen.ErrorAt(err, "discount")
+		}
+		a15 = a16
+	}
+	item["discount"] = a15
+	item["placed"] = codegen.UnixTimeValue(v.Placed)
+	if v.Expires != nil {
+		var a17 *dynamodb.AttributeValue
+		if v.Expires == nil {
+			a17 = codegen.NullValue()
+		} else {
+			a17 = codegen.UnixTimeValue((*v.Expires))
+		}
+		item["ttl"] = a17
+	}
+	a18, err := dynamodb.EncodeToAttributeValue(&v.Updated)
+	if err != nil {
+		return nil, codegen.ErrorAt(err, "updated")
+	}
+	item["updated"] = a18
+	item["note"] = codegen.BytesValue([]byte(v.Note))
+	item["sig"] = codegen.BytesValue(v.Signature)
+	var a19 *dynamodb.AttributeValue
+	if v.Attrs == nil {
+		a19 = codegen.NullValue()
+	} else {
+		m20 := make(dynamodb.AttributeValueMap, len(v.Attrs))
+		for k21, e22 := range v.Attrs {
+			x23 := e22
+			a24, err := dynamodb.EncodeToAttributeValue(&x23)
+			if err != nil {
+				return nil, codegen.ErrorAt(err, "attrs", k21)
+			}
+			m20[k21] = a24
+		}
+		a19 = &dynamodb.AttributeValue{M: m20}
+	}
+	item["attrs"] = a19
+	if len(v.Counts) != 0 {
+		var a25 *dynamodb.AttributeValue
+		if v.Counts == nil {
+			a25 = codegen.NullValue()
+		} else {
+			m26 := make(dynamodb.AttributeValueMap, len(v.Counts))
+			for k27, e28 := range v.Counts {
+				m26[k27] = codegen.IntValue(int64(e28))
+			}
+			a25 = &dynamodb.AttributeValue{M: m26}
+		}
+		item["counts"] = a25
+	}
+	l29 := make([]*dynamodb.AttributeValue, len(v.Window))
+	for i30 := range v.Window {
+		l29[i30] = codegen.IntValue(int64(v.Window[i30]))
+	}
+	item["window"] = &dynamodb.AttributeValue{L: l29}
+	a31, err := codegen.TextValue(&v.Ref)
+	if err != nil {
+		return nil, codegen.ErrorAt(err, "ref")
+	}
+	item["ref"] = a31
+	item["created_by"] = codegen.StringValue(v.Audit.CreatedBy)
+	item["version"] = codegen.UintValue(uint64(v.Audit.Version))
+	if v.Extra != nil {
+		item["gift"] = codegen.BoolValue(v.Extra.Gift)
+	}
+	return &dynamodb.AttributeValue{M: item}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue decodes an M into v. NULL zeroes v, and other
+// attributes are ignored.
+func (v *Order) UnmarshalDynamoDBAttributeValue(attr *dynamodb.AttributeValue) error {
+	if attr.M == nil {
+		if attr.NULL != nil {
+			*v = Order{}
+		}
+		return nil
+	}
+	for k, a := range attr.M {
+		switch k {
+		case "id":
+			if a.S != nil {
+				v.ID = *a.S
+			} else if a.NULL != nil {
+				v.ID = ""
+			}
+		case "customer":
+			if a.NULL != nil {
+				v.Customer = nil
+			} else {
+				v.Customer = new(Customer)
+				if err := codegen.DecodeUnmarshaler(a, v.Customer); err != nil {
+					return codegen.ErrorAt(err, "customer")
+				}
+			}
+		case "lines":
+			l32 := a
+			if l32.SS != nil || l32.NS != nil || l32.BS != nil {
+				l32 = codegen.SetList(l32)
+			}
+			if l32.NULL != nil || l32.L == nil {
+				v.Lines = nil
+			} else {
+				v.Lines = make([]Line, len(l32.L))
+				for i33, e34 := range l32.L {
+					if err := codegen.DecodeUnmarshaler(e34, &v.Lines[i33]); err != nil {
+						return codegen.ErrorAt(err, "lines", i33)
+					}
+				}
+			}
+		case "tags":
+			l35 := a
+			if l35.SS != nil || l35.NS != nil || l35.BS != nil {
+				l35 = codegen.SetList(l35)
+			}
+			if l35.NULL != nil || l35.L == nil {
+				v.Tags = nil
+			} else {
+				v.Tags = make([]string, len(l35.L))
+				for i36, e37 := range l35.L {
+					if e37.S != nil {
+						v.Tags[i36] = *e37.S
+					} else if e37.NULL != nil {
+						v.Tags[i36] = ""
+					}
+				}
+			}
+		case "flags":
+			switch {
+			case a.SS != nil || a.NS != nil || a.BS != nil:
+				if v.Flags == nil {
+					v.Flags = make(map[string]struct{})
+				}
+				for _, e39 := range codegen.SetList(a).L {
+					var k40 string
+					if e39.S != nil {
+						k40 = *e39.S
+					} else if e39.NULL != nil {
+						k40 = ""
+					}
+					v.Flags[k40] = struct{}{}
+				}
+			case a.M != nil:
+				if v.Flags == nil {
+					v.Flags = make(map[string]struct{})
+				}
+				for k41 := range a.M {
+					var v43 struct{}
+					v.Flags[k41] = v43
+				}
+			case a.NULL != nil:
+				v.Flags = nil
+			}
+		case "scores":
+			l44 := a
+			if l44.SS != nil || l44.NS != nil || l44.BS != nil {
+				l44 = codegen.SetList(l44)
+			}
+			if l44.NULL != nil || l44.L == nil {
+				v.Scores = nil
+			} else {
+				v.Scores = make([]float64, len(l44.L))
+				for i45, e46 := range l44.L {
+					if e46.N != nil {
+						n47, err := codegen.DecodeFloat(*e46.N, 64, "float64")
+						if err != nil {
+							return codegen.ErrorAt(err, "scores", i45)
+						}
+						v.Scores[i45] = n47
+					} else if e46.NULL != nil {
+						v.Scores[i45] = 0
+					}
+				}
+			}
+		case "status":
+			if a.S != nil {
+				v.Status = Status(*a.S)
+			} else if a.NULL != nil {
+				v.Status = ""
+			}
+		case "total":
+			if a.S != nil {
+				q48, err := codegen.DecodeQuoted(*a.S, false, "example.Cents")
+				if err != nil {
+					return codegen.ErrorAt(err, "total")
+				}
+				if q48.N != nil {
+					n49, err := codegen.DecodeInt(*q48.N, 64, "example.Cents")
+					if err != nil {
+						return codegen.ErrorAt(err, "total")
+					}
+					v.Total = Cents(n49)
+				} else if q48.NULL != nil {
+					v.Total = 0
+				}
+			} else {
+				if a.N != nil {
+					n50, err := codegen.DecodeInt(*a.N, 64, "example.Cents")
+					if err != nil {
+						return codegen.ErrorAt(err, "total")
+					}
+					v.Total = Cents(n50)
+				} else if a.NULL != nil {
+					v.Total = 0
+				}
+			}
+		case "paid":
+			if a.S != nil {
+				q51, err := codegen.DecodeQuoted(*a.S, true, "bool")
+				if err != nil {
+					return codegen.ErrorAt(err, "paid")
+				}
+				if q51.NULL != nil {
+					v.Paid = nil
+				} else {
+					v.Paid = new(bool)
+					if q51.BOOL != nil {
+						(*v.Paid) = *q51.BOOL
+					} else if q51.NULL != nil {
+						(*v.Paid) = false
+					}
+				}
+			} else {
+				if a.NULL != nil {
+					v.Paid = nil
+				} else {
+					v.Paid = new(bool)
+					if a.BOOL != nil {
+						(*v.Paid) = *a.BOOL
+					} else if a.NULL != nil {
+						(*v.Paid) = false
+					}
+				}
+			}
+		case "discount":
+			if a.N != nil {
+				n52, err := codegen.DecodeFloat(*a.N, 32, "float32")
+				if err != nil {
+					return codegen.ErrorAt(err, "discount")
+				}
+				v.Discount = float32(n52)
+			} else if a.NULL != nil {
+				v.Discount = 0
+			}
+		case "placed":
+			if a.N != nil {
+				t53, err := codegen.DecodeUnixTime(*a.N)
+				if err != nil {
+					return codegen.ErrorAt(err, "placed")
+				}
+				v.Placed = t53
+			} else if a.NULL != nil {
+				v.Placed = time.Time{}
+			}
+		case "ttl":
+			if a.NULL != nil {
+				v.Expires = nil
+			} else {
+				v.Expires = new(time.Time)
+				if a.N != nil {
+					t54, err := codegen.DecodeUnixTime(*a.N)
+					if err != nil {
+						return codegen.ErrorAt(err, "ttl")
+					}
+					(*v.Expires) = t54
+				} else if a.NULL != nil {
+					(*v.Expires) = time.Time{}
+				}
+			}
+		case "updated":
+			if err := dynamodb.DecodeAttributeValueToInterface(a, &v.Updated); err != nil {
+				return codegen.ErrorAt(err, "updated")
+			}
+		case "note":
+			if a.B != nil {
+				v.Note = string(a.B)
+			} else {
+				if a.S != nil {
+					v.Note = *a.S
+				} else if a.NULL != nil {
+					v.Note = ""
+				}
+			}
+		case "sig":
+			if a.B != nil || a.S != nil {
+				b55, err := codegen.DecodeBytes(a)
+				if err != nil {
+					return codegen.ErrorAt(err, "sig")
+				}
+				v.Signature = b55
+			} else if a.NULL != nil {
+				v.Signature = nil
+			}
+		case "attrs":
+			switch {
+			case a.SS != nil || a.NS != nil || a.BS != nil:
+				if err := dynamodb.DecodeAttributeValueToInterface(a, &v.Attrs); err != nil {
+					return codegen.ErrorAt(err, "attrs")
+				}
+			case a.M != nil:
+				if v.Attrs == nil {
+					v.Attrs = make(map[string]interface{})
+				}
+				for k56, e57 := range a.M {
+					var v58 interface{}
+					if err := dynamodb.DecodeAttributeValueToInterface(e57, &v58); err != nil {
+						return codegen.ErrorAt(err, "attrs", k56)
+					}
+					v.Attrs[k56] = v58
+				}
+			case a.NULL != nil:
+				v.Attrs = nil
+			}
+		case "counts":
+			switch {
+			case a.SS != nil || a.NS != nil || a.BS != nil:
+				if err := dynamodb.DecodeAttributeValueToInterface(a, &v.Counts); err != nil {
+					return codegen.ErrorAt(err, "counts")
+				}
+			case a.M != nil:
+				if v.Counts == nil {
+					v.Counts = make(map[string]int)
+				}
+				for k59, e60 := range a.M {
+					var v61 int
+					if e60.N != nil {
+						n62, err := codegen.DecodeInt(*e60.N, 0, "int")
+						if err != nil {
+							return codegen.ErrorAt(err, "counts", k59)
+						}
+						v61 = int(n62)
+					} else if e60.NULL != nil {
+						v61 = 0
+					}
+					v.Counts[k59] = v61
+				}
+			case a.NULL != nil:
+				v.Counts = nil
+			}
+		case "window":
+			l63 := a
+			if l63.SS != nil || l63.NS != nil || l63.BS != nil {
+				l63 = codegen.SetList(l63)
+			}
+			if l63.NULL != nil || l63.L == nil {
+				v.Window = [2]int16{}
+			} else {
+				for i64, e65 := range l63.L {
+					if i64 >= len(v.Window) {
+						break
+					}
+					if e65.N != nil {
+						n66, err := codegen.DecodeInt(*e65.N, 16, "int16")
+						if err != nil {
+							return codegen.ErrorAt(err, "window", i64)
+						}
+						v.Window[i64] = int16(n66)
+					} else if e65.NULL != nil {
+						v.Window[i64] = 0
+					}
+				}
+				for i67 := len(l63.L); i67 < len(v.Window); i67++ {
+					v.Window[i67] = 0
+				}
+			}
+		case "ref":
+			if a.S != nil {
+				if err := codegen.DecodeText(*a.S, &v.Ref); err != nil {
+					return codegen.ErrorAt(err, "ref")
+				}
+			} else if a.NULL != nil {
+				v.Ref = Ref{}
+			}
+		case "created_by":
+			if a.S != nil {
+				v.Audit.CreatedBy = *a.S
+			} else if a.NULL != nil {
+				v.Audit.CreatedBy = ""
+			}
+		case "version":
+			if a.N != nil {
+				n68, err := codegen.DecodeUint(*a.N, 32, "uint32")
+				if err != nil {
+					return codegen.ErrorAt(err, "version")
+				}
+				v.Audit.Version = uint32(n68)
+			} else if a.NULL != nil {
+				v.Audit.Version = 0
+			}
+		case "gift":
+			if v.Extra == nil {
+				v.Extra = new(Extra)
+			}
+			if a.BOOL != nil {
+				v.Extra.Gift = *a.BOOL
+			} else if a.NULL != nil {
+				v.Extra.Gift = false
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue encodes v as an M.
+func (v Line) MarshalDynamoDBAttributeValue() (*dynamodb.AttributeValue, error) {
+	item := make(dynamodb.AttributeValueMap, 5)
+	item["SKU"] = codegen.StringValue(v.SKU)
+	item["Qty"] = codegen.UintValue(uint64(v.Qty))
+	a69, err := codegen.MarshalerValue(&v.Price)
+	if err != nil {
+		return nil, codegen.ErrorAt(err, "Price")
+	}
+	item["Price"] = a69
+	if len(v.Options) != 0 {
+		var a70 *dynamodb.AttributeValue
+		if v.Options == nil {
+			a70 = codegen.NullValue()
+		} else {
+			m71 := make(dynamodb.AttributeValueMap, len(v.Options))
+			for k72, e73 := range v.Options {
+				m71[k72] = codegen.StringValue(e73)
+			}
+			a70 = &dynamodb.AttributeValue{M: m71}
+		}
+		item["Options"] = a70
+	}
+	var a74 *dynamodb.AttributeValue
+	if v.Parent == nil {
+		a74 = codegen.NullValue()
+	} else {
+		a75, err := codegen.MarshalerValue(v.Parent)
+		if err != nil {
+			return nil, codegen.ErrorAt(err, "Parent")
+		}
+		a74 = a75
+	}
+	item["Parent"] = a74
+	return &dynamodb.AttributeValue{M: item}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue decodes an M into v. NULL zeroes v, and other
+// attributes are ignored.
+func (v *Line) UnmarshalDynamoDBAttributeValue(attr *dynamodb.AttributeValue) error {
+	if attr.M == nil {
+		if attr.NULL != nil {
+			*v = Line{}
+		}
+		return nil
+	}
+	for k, a := range attr.M {
+		switch k {
+		case "SKU":
+			if a.S != nil {
+				v.SKU = *a.S
+			} else if a.NULL != nil {
+				v.SKU = ""
+			}
+		case "Qty":
+			if a.N != nil {
+				n76, err := codegen.DecodeUint(*a.N, 8, "uint8")
+				if err != nil {
+					return codegen.ErrorAt(err, "Qty")
+				}
+				v.Qty = uint8(n76)
+			} else if a.NULL != nil {
+				v.Qty = 0
+			}
+		case "Price":
+			if err := codegen.DecodeUnmarshaler(a, &v.Price); err != nil {
+				return codegen.ErrorAt(err, "Price")
+			}
+		case "Options":
+			switch {
+			case a.SS != nil || a.NS != nil || a.BS != nil:
+				if err := dynamodb.DecodeAttributeValueToInterface(a, &v.Options); err != nil {
+					return codegen.ErrorAt(err, "Options")
+				}
+			case a.M != nil:
+				if v.Options == nil {
+					v.Options = make(map[string]string)
+				}
+				for k77, e78 := range a.M {
+					var v79 string
+					if e78.S != nil {
+						v79 = *e78.S
+					} else if e78.NULL != nil {
+						v79 = ""
+					}
+					v.Options[k77] = v79
+				}
+			case a.NULL != nil:
+				v.Options = nil
+			}
+		case "Parent":
+			if a.NULL != nil {
+				v.Parent = nil
+			} else {
+				v.Parent = new(Line)
+				if err := codegen.DecodeUnmarshaler(a, v.Parent); err != nil {
+					return codegen.ErrorAt(err, "Parent")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue encodes v as an M.
+func (v Customer) MarshalDynamoDBAttributeValue() (*dynamodb.AttributeValue, error) {
+	item := make(dynamodb.AttributeValueMap, 3)
+	item["Name"] = codegen.StringValue(v.Name)
+	elems80 := make([]string, 0, len(v.Emails))
+	for _, e81 := range v.Emails {
+		elems80 = append(elems80, e81)
+	}
+	item["Emails"] = codegen.SetValue(dynamodb.SS, elems80, false)
+	item["Since"] = codegen.IntValue(int64(v.Since))
+	return &dynamodb.AttributeValue{M: item}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue decodes an M into v. NULL zeroes v, and other
+// attributes are ignored.
+func (v *Customer) UnmarshalDynamoDBAttributeValue(attr *dynamodb.AttributeValue) error {
+	if attr.M == nil {
+		if attr.NULL != nil {
+			*v = Customer{}
+		}
+		return nil
+	}
+	for k, a := range attr.M {
+		switch k {
+		case "Name":
+			if a.S != nil {
+				v.Name = *a.S
+			} else if a.NULL != nil {
+				v.Name = ""
+			}
+		case "Emails":
+			l82 := a
+			if l82.SS != nil || l82.NS != nil || l82.BS != nil {
+				l82 = codegen.SetList(l82)
+			}
+			if l82.NULL != nil || l82.L == nil {
+				v.Emails = nil
+			} else {
+				v.Emails = make([]string, len(l82.L))
+				for i83, e84 := range l82.L {
+					if e84.S != nil {
+						v.Emails[i83] = *e84.S
+					} else if e84.NULL != nil {
+						v.Emails[i83] = ""
+					}
+				}
+			}
+		case "Since":
+			if a.N != nil {
+				n85, err := codegen.DecodeInt(*a.N, 0, "int")
+				if err != nil {
+					return codegen.ErrorAt(err, "Since")
+				}
+				v.Since = int(n85)
+			} else if a.NULL != nil {
+				v.Since = 0
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue encodes v as an M.
+func (v Embedded) MarshalDynamoDBAttributeValue() (*dynamodb.AttributeValue, error) {
+	item := make(dynamodb.AttributeValueMap, 7)
+	item["Name"] = codegen.StringValue(v.Name)
+	item["Override"] = codegen.StringValue(v.Override)
+	item["-"] = codegen.StringValue(v.Dash)
+	item["Invalid"] = codegen.StringValue(v.Invalid)
+	if len(v.JSONOnly) != 0 {
+		item["json_only"] = codegen.StringValue(v.JSONOnly)
+	}
+	item["depth"] = codegen.IntValue(int64(v.Inner.Depth))
+	if v.Labeled != nil {
+		item["Label"] = codegen.StringValue(v.Labeled.Label)
+	}
+	return &dynamodb.AttributeValue{M: item}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue decodes an M into v. NULL zeroes v, and other
+// attributes are ignored.
+func (v *Embedded) UnmarshalDynamoDBAttributeValue(attr *dynamodb.AttributeValue) error {
+	if attr.M == nil {
+		if attr.NULL != nil {
+			*v = Embedded{}
+		}
+		return nil
+	}
+	for k, a := range attr.M {
+		switch k {
+		case "Name":
+			if a.S != nil {
+				v.Name = *a.S
+			} else if a.NULL != nil {
+				v.Name = ""
+			}
+		case "Override":
+			if a.S != nil {
+				v.Override = *a.S
+			} else if a.NULL != nil {
+				v.Override = ""
+			}
+		case "-":
+			if a.S != nil {
+				v.Dash = *a.S
+			} else if a.NULL != nil {
+				v.Dash = ""
+			}
+		case "Invalid":
+			if a.S != nil {
+				v.Invalid = *a.S
+			} else if a.NULL != nil {
+				v.Invalid = ""
+			}
+		case "json_only":
+			if a.S != nil {
+				v.JSONOnly = *a.S
+			} else if a.NULL != nil {
+				v.JSONOnly = ""
+			}
+		case "depth":
+			if a.N != nil {
+				n86, err := codegen.DecodeInt(*a.N, 0, "int")
+				if err != nil {
+					return codegen.ErrorAt(err, "depth")
+				}
+				v.Inner.Depth = int(n86)
+			} else if a.NULL != nil {
+				v.Inner.Depth = 0
+			}
+		case "Label":
+			if v.Labeled == nil {
+				v.Labeled = new(Labeled)
+			}
+			if a.S != nil {
+				v.Labeled.Label = *a.S
+			} else if a.NULL != nil {
+				v.Labeled.Label = ""
+			}
+		}
+	}
+	return nil
+}
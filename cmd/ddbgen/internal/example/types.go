@@ -0,0 +1,150 @@
+// Package example holds types whose generated methods are tested against the
+// reflective encoder and decoder.
+package example
+
+import (
+	"backflip/aws/dynamodb"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//go:generate go run backflip/aws/dynamodb/cmd/ddbgen -type Order,Line,Customer,Embedded
+
+type Status string
+
+type Cents int64
+
+type Order struct {
+	ID        string                 `dynamodb:"id,hashkey"`
+	Customer  *Customer              `dynamodb:"customer"`
+	Lines     []Line                 `dynamodb:"lines"`
+	Tags      []string               `dynamodb:"tags,set"`
+	Flags     map[string]struct{}    `dynamodb:"flags"`
+	Scores    []float64              `dynamodb:"scores,set,omitempty"`
+	Status    Status                 `dynamodb:"status,omitempty"`
+	Total     Cents                  `dynamodb:"total,string"`
+	Paid      *bool                  `dynamodb:"paid,string"`
+	Discount  float32                `dynamodb:"discount,nullempty"`
+	Placed    time.Time              `dynamodb:"placed,unixtime"`
+	Expires   *time.Time             `dynamodb:"ttl,unixtime,omitempty"`
+	Updated   time.Time              `json:"updated"`
+	Note      string                 `dynamodb:"note,binary"`
+	Signature []byte                 `dynamodb:"sig"`
+	Attrs     map[string]interface{} `dynamodb:"attrs"`
+	Counts    map[string]int         `json:"counts,omitempty"`
+	Window    [2]int16               `dynamodb:"window"`
+	Ref       Ref                    `dynamodb:"ref"`
+	Internal  string                 `dynamodb:"-"`
+	Audit
+	*Extra
+
+	private int
+}
+
+type Audit struct {
+	CreatedBy string `dynamodb:"created_by"`
+	Version   uint32 `dynamodb:"version"`
+}
+
+type Extra struct {
+	Gift bool `dynamodb:"gift"`
+}
+
+type Line struct {
+	SKU     string
+	Qty     uint8
+	Price   Money
+	Options map[string]string `dynamodb:",omitempty"`
+	Parent  *Line
+}
+
+type Customer struct {
+	Name   string
+	Emails []string `dynamodb:",set"`
+	Since  int
+}
+
+// Embedded has fields named by the embedding and tag rules: those nearer the
+// top hide deeper ones, tagged fields win at the same depth, and otherwise
+// fields with the same name at the same depth are all left out.
+type Embedded struct {
+	Name     string
+	Override string `json:"ignored" dynamodb:""`
+	Dash     string `dynamodb:"-,"`
+	Invalid  string `dynamodb:"a'b"`
+	JSONOnly string `json:"json_only,omitempty"`
+	Inner
+	*Labeled
+	Left
+	Right
+}
+
+type Inner struct {
+	Name  string
+	Depth int `dynamodb:"depth"`
+	Label string
+}
+
+type Labeled struct {
+	Label string `dynamodb:"Label"`
+}
+
+type Left struct {
+	Dup    string
+	Tagged string `dynamodb:"tagged"`
+}
+
+type Right struct {
+	Dup   string
+	Other string `dynamodb:"tagged"`
+}
+
+// Money is stored as an S such as "1.50 USD".
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m Money) MarshalDynamoDBAttributeValue() (*dynamodb.AttributeValue, error) {
+	if m.Currency == "" {
+		return nil, errors.New("missing currency")
+	}
+	s := fmt.Sprintf("%d.%02d %s", m.Cents/100, m.Cents%100, m.Currency)
+	return &dynamodb.AttributeValue{S: &s}, nil
+}
+
+func (m *Money) UnmarshalDynamoDBAttributeValue(attr *dynamodb.AttributeValue) error {
+	if attr.S == nil {
+		*m = Money{}
+		return nil
+	}
+	var units, cents int64
+	if _, err := fmt.Sscanf(*attr.S, "%d.%d %s", &units, &cents, &m.Currency); err != nil {
+		return err
+	}
+	m.Cents = units*100 + cents
+	return nil
+}
+
+// Ref is stored as text such as "order/o1".
+type Ref struct {
+	Kind, ID string
+}
+
+func (r Ref) MarshalText() ([]byte, error) {
+	if r == (Ref{}) {
+		return nil, nil
+	}
+	return []byte(r.Kind + "/" + r.ID), nil
+}
+
+func (r *Ref) UnmarshalText(b []byte) error {
+	i := strings.IndexByte(string(b), '/')
+	if i < 0 {
+		return fmt.Errorf("bad ref %q", b)
+	}
+	r.Kind, r.ID = string(b[:i]), string(b[i+1:])
+	return nil
+}
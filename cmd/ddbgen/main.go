@@ -0,0 +1,74 @@
+// Command ddbgen generates MarshalDynamoDBAttributeValue and
+// UnmarshalDynamoDBAttributeValue methods for struct types, so they can be
+// encoded and decoded without reflection.
+//
+// Usage:
+//
+//	ddbgen -type T[,T...] [flags] [dir]
+//
+// It is meant to be run by go generate, from a comment in the package
+// declaring the types:
+//
+//	//go:generate go run backflip/aws/dynamodb/cmd/ddbgen -type Order,Line
+//
+// The package in dir, or the current directory, is type checked, and the
+// methods are written to a file beside it. Fields are found with the same
+// rules and tag options as the reflective encoder, and the generated methods
+// behave like the default Encoder and Decoder. Fields of types the generator
+// can't handle directly, such as interfaces, time.Time and structs not listed
+// in -type, are encoded and decoded with reflection, and reported on stderr.
+//
+// Flags:
+//
+//	-type names   comma-separated struct types to generate methods for
+//	-output file  output file name (default <first type>_ddb.go, lower case)
+//	-tag name     struct tag naming fields (default dynamodb)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated struct types to generate methods for")
+	output := flag.String("output", "", "output file name (default <first type>_ddb.go, lower case)")
+	tagName := flag.String("tag", "dynamodb", "struct tag naming fields")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ddbgen -type T[,T...] [flags] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	names := strings.Split(*typeNames, ",")
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		*output = strings.ToLower(names[0]) + "_ddb.go"
+	}
+	if !filepath.IsAbs(*output) {
+		*output = filepath.Join(dir, *output)
+	}
+
+	src, warnings, err := generate(dir, names, *tagName)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "ddbgen: %s\n", w)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ddbgen: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "ddbgen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"backflip/tools/testutils"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestGenerate(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	ck.Suite(&GenerateSuite{})
+	ck.TestingT(t)
+}
+
+type GenerateSuite struct {
+}
+
+// TestExample checks the checked in example is up to date, since its tests
+// compare the generated methods to the reflective encoder.
+func (s *GenerateSuite) TestExample(c *ck.C) {
+	src, warnings, err := generate("internal/example", []string{"Order", "Line", "Customer", "Embedded"}, "dynamodb")
+	c.Assert(err, ck.IsNil)
+	want, err := os.ReadFile("internal/example/order_ddb.go")
+	c.Assert(err, ck.IsNil)
+	c.Check(string(src), ck.Equals, string(want), ck.Commentf("run go generate in internal/example"))
+	c.Check(warnings, ck.DeepEquals, []string{
+		"Order.Updated: time.Time is encoded and decoded with reflection",
+		"Order.Attrs: interface{} is encoded and decoded with reflection",
+	})
+}
+
+func writePackage(c *ck.C, src string) string {
+	dir := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\n"+src), 0644), ck.IsNil)
+	return dir
+}
+
+func (s *GenerateSuite) TestTags(c *ck.C) {
+	dir := writePackage(c, "type T struct {\n"+
+		"\tA string `ddb:\"a\" json:\"x\"`\n"+
+		"\tB string `json:\"b\"`\n"+
+		"\tC string `ddb:\"-\"`\n"+
+		"\td string\n"+
+		"}\n")
+	src, warnings, err := generate(dir, []string{"T"}, "ddb")
+	c.Assert(err, ck.IsNil)
+	c.Check(warnings, ck.HasLen, 0)
+	c.Check(string(src), ck.Matches, "// Code generated by ddbgen -type T -tag ddb; DO NOT EDIT\\.\n(.|\n)*")
+	c.Check(string(src), ck.Matches, `(?s).*item\["a"\] = codegen.StringValue\(v.A\)\n\titem\["b"\] = codegen.StringValue\(v.B\)\n\treturn.*`)
+	c.Check(string(src), ck.Matches, `(?s).*case "a":.*case "b":.*`)
+}
+
+func (s *GenerateSuite) TestImports(c *ck.C) {
+	// the attribute name isn't a use of the codegen package
+	dir := writePackage(c, "type T struct {\n\tA struct{} `json:\"codegen.x\"`\n}\n")
+	src, _, err := generate(dir, []string{"T"}, "dynamodb")
+	c.Assert(err, ck.IsNil)
+	c.Check(string(src), ck.Matches, `(?s).*import \(\n\t"backflip/aws/dynamodb"\n\)\n.*`)
+
+	dir = writePackage(c, "type T struct{ A int }\n")
+	src, _, err = generate(dir, []string{"T"}, "dynamodb")
+	c.Assert(err, ck.IsNil)
+	c.Check(string(src), ck.Matches, `(?s).*import \(\n\t"backflip/aws/dynamodb"\n\t"backflip/aws/dynamodb/codegen"\n\)\n.*`)
+}
+
+func (s *GenerateSuite) TestIgnoresGeneratedFiles(c *ck.C) {
+	dir := writePackage(c, "type T struct{ A int }\n")
+	src, _, err := generate(dir, []string{"T"}, "dynamodb")
+	c.Assert(err, ck.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "t_ddb.go"), src, 0644), ck.IsNil)
+
+	again, _, err := generate(dir, []string{"T"}, "dynamodb")
+	c.Assert(err, ck.IsNil)
+	c.Check(string(again), ck.Equals, string(src))
+}
+
+func (s *GenerateSuite) TestErrors(c *ck.C) {
+	for _, t := range []struct {
+		src string
+		err string
+	}{
+		{"type T struct{}", "type U not found in package p"},
+		{"type U int", "U is not a struct type"},
+		{"type U struct{ A int `dynamodb:\",unixtime\"` }", "U.A: unixtime option requires a time.Time, not int"},
+		{"type U struct{ A int `dynamodb:\",binary\"` }", "U.A: binary option on int is not supported by ddbgen.*"},
+		{"type U struct{ A []bool `dynamodb:\",set\"` }", "U.A: set option on \\[\\]bool is not supported by ddbgen"},
+		{"type U struct{ A chan int }", "U.A: unsupported type chan int"},
+		{"type U struct{ A missing.T }", "U.A: unknown type: .*undefined: missing"},
+	} {
+		_, _, err := generate(writePackage(c, t.src), []string{"U"}, "dynamodb")
+		c.Check(err, ck.ErrorMatches, t.err, ck.Commentf("%s", t.src))
+	}
+}
@@ -0,0 +1,229 @@
+// Command ddbjson converts items between dynamo's JSON format, plain JSON and
+// the format of DynamoDB exports to S3.
+//
+// Usage:
+//
+//	ddbjson [flags] [file ...]
+//
+// Items are read from the files, or stdin if there are none, as a sequence of
+// JSON values such as newline-delimited JSON, and written to stdout one per
+// line. Items that fail to parse are reported on stderr with their position,
+// and skipped. With -validate, every item is instead checked against the rules
+// dynamo applies to stored values, and each one that breaks them is reported.
+// The exit status is 1 if any item was reported.
+//
+// Formats:
+//
+//	ddb     {"id":{"S":"a"},"n":{"N":"1"}}
+//	plain   {"id":"a","n":1}
+//	export  {"Item":{"id":{"S":"a"},"n":{"N":"1"}}}
+//
+// Flags:
+//
+//	-from format         format of the input (default ddb)
+//	-to format           format of the output (default plain)
+//	-pretty              indent the output
+//	-validate            check the items against dynamo's rules, writing
+//	                     nothing to stdout
+//	-numbers-as-strings  write plain JSON numbers as strings
+//	-tagged              represent binary values and sets in plain JSON as
+//	                     {"$binary":...} and {"$set":[...]}, in both directions
+package main
+
+import (
+	"backflip/aws/dynamodb"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.from, "from", "ddb", "format of the input: ddb, plain or export")
+	flag.StringVar(&opts.to, "to", "plain", "format of the output: ddb, plain or export")
+	flag.BoolVar(&opts.pretty, "pretty", false, "indent the output")
+	flag.BoolVar(&opts.validate, "validate", false, "check the items against dynamo's rules, writing nothing to stdout")
+	numbersAsStrings := flag.Bool("numbers-as-strings", false, "write plain JSON numbers as strings")
+	tagged := flag.Bool("tagged", false, "represent binary values and sets in plain JSON as {\"$binary\":...} and {\"$set\":[...]}")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ddbjson [flags] [file ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *numbersAsStrings {
+		opts.plain = append(opts.plain, dynamodb.PlainNumbers(dynamodb.NumbersAsStrings))
+	}
+	if *tagged {
+		opts.plain = append(opts.plain, dynamodb.PlainBinary(dynamodb.BinaryAsTagged), dynamodb.PlainSets(dynamodb.SetsAsTagged))
+	}
+	if !validFormat(opts.from) || !validFormat(opts.to) {
+		fmt.Fprintf(os.Stderr, "ddbjson: formats must be ddb, plain or export\n")
+		os.Exit(2)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	failed := false
+	if flag.NArg() == 0 {
+		failed = !convert("stdin", os.Stdin, out, os.Stderr, opts)
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ddbjson: %s\n", err)
+			failed = true
+			continue
+		}
+		if !convert(name, f, out, os.Stderr, opts) {
+			failed = true
+		}
+		f.Close()
+	}
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "ddbjson: %s\n", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	from, to string
+	pretty   bool
+	validate bool
+	plain    []dynamodb.PlainJSONOption
+}
+
+func validFormat(f string) bool {
+	return f == "ddb" || f == "plain" || f == "export"
+}
+
+// convert converts every item read from r, writing them to w and reporting
+// errors to errs. It returns false if any item failed.
+func convert(name string, r io.Reader, w io.Writer, errs io.Writer, opts options) bool {
+	dec := json.NewDecoder(r)
+	ok := true
+	for n := 1; ; n++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return ok
+		} else if err != nil {
+			// the decoder can't resynchronize after a syntax error
+			fmt.Fprintf(errs, "%s: item %d: %s\n", name, n, err)
+			return false
+		}
+
+		var b []byte
+		item, err := readItem(raw, opts)
+		if err == nil {
+			if opts.validate {
+				err = validateItem(item)
+			} else {
+				b, err = writeItem(item, opts)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(errs, "%s: item %d: %s\n", name, n, err)
+			ok = false
+			continue
+		}
+		if opts.validate {
+			continue
+		}
+		if opts.pretty {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, b, "", "  "); err != nil {
+				fmt.Fprintf(errs, "%s: item %d: %s\n", name, n, err)
+				ok = false
+				continue
+			}
+			b = buf.Bytes()
+		}
+		w.Write(b)
+		w.Write([]byte("\n"))
+	}
+}
+
+// validateItem returns an error listing every violation of dynamo's rules in
+// item.
+func validateItem(item dynamodb.AttributeValueMap) error {
+	vs := dynamodb.ValidateItem(item)
+	if len(vs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(vs))
+	for i, v := range vs {
+		msgs[i] = v.String()
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// writeItem encodes an item in the output format.
+func writeItem(item dynamodb.AttributeValueMap, opts options) ([]byte, error) {
+	switch opts.to {
+	case "plain":
+		return dynamodb.ToPlainJSON(&dynamodb.AttributeValue{M: item}, opts.plain...)
+	case "export":
+		return json.Marshal(exportItem{item})
+	default:
+		return json.Marshal(item)
+	}
+}
+
+// exportItem is a line of a DynamoDB JSON export to S3.
+type exportItem struct {
+	Item dynamodb.AttributeValueMap
+}
+
+func readItem(raw []byte, opts options) (dynamodb.AttributeValueMap, error) {
+	switch opts.from {
+	case "plain":
+		attr, err := dynamodb.FromPlainJSON(raw, opts.plain...)
+		if err != nil {
+			return nil, err
+		}
+		if attr.M == nil {
+			return nil, fmt.Errorf("item is a JSON %s, not an object", plainKind(attr))
+		}
+		return attr.M, nil
+	case "export":
+		var line exportItem
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return nil, err
+		}
+		if line.Item == nil {
+			return nil, fmt.Errorf("export line has no Item")
+		}
+		return line.Item, nil
+	default:
+		var item dynamodb.AttributeValueMap
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return nil, fmt.Errorf("item is null")
+		}
+		return item, nil
+	}
+}
+
+func plainKind(attr *dynamodb.AttributeValue) string {
+	switch attr.Type() {
+	case dynamodb.L:
+		return "array"
+	case dynamodb.S:
+		return "string"
+	case dynamodb.N:
+		return "number"
+	case dynamodb.BOOL:
+		return "boolean"
+	}
+	return "null"
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"bytes"
+	"strings"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestConvert(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	ck.Suite(&ConvertSuite{})
+	ck.TestingT(t)
+}
+
+type ConvertSuite struct {
+}
+
+func run(input string, opts options) (string, string, bool) {
+	var out, errs bytes.Buffer
+	ok := convert("in", strings.NewReader(input), &out, &errs, opts)
+	return out.String(), errs.String(), ok
+}
+
+func (s *ConvertSuite) TestFormats(c *ck.C) {
+	ddb := `{"id":{"S":"a"},"n":{"N":"1.5"},"tags":{"SS":["x"]}}`
+	plain := `{"id":"a","n":1.5,"tags":["x"]}`
+	export := `{"Item":{"id":{"S":"a"},"n":{"N":"1.5"},"tags":{"SS":["x"]}}}`
+
+	out, errs, ok := run(ddb+"\n"+ddb+"\n", options{from: "ddb", to: "plain"})
+	c.Check(ok, ck.Equals, true)
+	c.Check(errs, ck.Equals, "")
+	c.Check(out, ck.Equals, plain+"\n"+plain+"\n")
+
+	out, _, ok = run(export, options{from: "export", to: "ddb"})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, ddb+"\n")
+
+	out, _, ok = run(ddb, options{from: "ddb", to: "export"})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, export+"\n")
+
+	// plain JSON loses the set, unless it's tagged
+	out, _, ok = run(plain, options{from: "plain", to: "ddb"})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, `{"id":{"S":"a"},"n":{"N":"1.5"},"tags":{"L":[{"S":"x"}]}}`+"\n")
+
+	tagged := []dynamodb.PlainJSONOption{dynamodb.PlainBinary(dynamodb.BinaryAsTagged), dynamodb.PlainSets(dynamodb.SetsAsTagged)}
+	out, _, ok = run(ddb, options{from: "ddb", to: "plain", plain: tagged})
+	c.Check(ok, ck.Equals, true)
+	out, _, ok = run(out, options{from: "plain", to: "ddb", plain: tagged})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, ddb+"\n")
+}
+
+func (s *ConvertSuite) TestPretty(c *ck.C) {
+	out, _, ok := run(`{"a": {"L": [{"N": "1"}]}}`, options{from: "ddb", to: "plain", pretty: true})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, "{\n  \"a\": [\n    1\n  ]\n}\n")
+
+	// pretty printed input is read as well
+	out, _, ok = run(out, options{from: "plain", to: "ddb"})
+	c.Check(ok, ck.Equals, true)
+	c.Check(out, ck.Equals, `{"a":{"L":[{"N":"1"}]}}`+"\n")
+}
+
+func (s *ConvertSuite) TestValidate(c *ck.C) {
+	input := strings.Join([]string{
+		`{"a":{"N":"1"}}`,
+		`{"a":{"N":"1e400"}}`,
+		`{"a":{}}`,
+		`{"b":{"SS":["x","x"]},"c":{"NS":["1","1.0"]}}`,
+		`[1]`,
+		`null`,
+		`{"a":{"S":"fine"}}`,
+	}, "\n")
+	out, errs, ok := run(input, options{from: "ddb", to: "plain", validate: true})
+	c.Check(ok, ck.Equals, false)
+	c.Check(out, ck.Equals, "")
+	c.Check(errs, ck.Matches, `in: item 2: aws.dynamodb.DecodeError: a: number 1e400 is out of range
+in: item 3: aws.dynamodb.DecodeError: a: AttributeValue has no type descriptor
+in: item 4: b\[1\]: duplicate of set element 0; c\[1\]: duplicate of set element 0
+in: item 5: aws.dynamodb.DecodeError: AttributeValueMap must be a JSON object, not an array
+in: item 6: item is null
+`)
+
+	// without -validate, items are only parsed and converted
+	out, errs, ok = run(input, options{from: "ddb", to: "ddb"})
+	c.Check(ok, ck.Equals, false)
+	c.Check(out, ck.Equals, `{"a":{"N":"1"}}
+{"b":{"SS":["x","x"]},"c":{"NS":["1","1.0"]}}
+{"a":{"S":"fine"}}
+`)
+	c.Check(errs, ck.Matches, `in: item 2: .*
+in: item 3: .*
+in: item 5: .*
+in: item 6: item is null
+`)
+
+	_, errs, ok = run(`[1]`, options{from: "plain", to: "ddb"})
+	c.Check(ok, ck.Equals, false)
+	c.Check(errs, ck.Equals, "in: item 1: item is a JSON array, not an object\n")
+
+	_, errs, ok = run(`{"Other":{}}`, options{from: "export", to: "ddb"})
+	c.Check(ok, ck.Equals, false)
+	c.Check(errs, ck.Equals, "in: item 1: export line has no Item\n")
+
+	// syntax errors stop the conversion
+	out, errs, ok = run(`{"a":{"N":"1"}} {"a": `+"\n"+`{"a":{"N":"2"}}`, options{from: "ddb", to: "plain"})
+	c.Check(ok, ck.Equals, false)
+	c.Check(out, ck.Equals, `{"a":1}`+"\n")
+	c.Check(errs, ck.Matches, "in: item 2: .*\n")
+}
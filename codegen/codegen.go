@@ -0,0 +1,320 @@
+// Package codegen holds the functions called by the methods cmd/ddbgen
+// generates. They encode and decode single values the way the default Encoder
+// and Decoder of the dynamodb package do, so generated code behaves like the
+// reflective code without reflecting.
+//
+// The functions exist for generated code and should not be called directly.
+// They follow its conventions, such as encoding empty strings as NULL, so they
+// are surprising as general API, and may change with the generator.
+package codegen
+
+import (
+	"backflip/aws/dynamodb"
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NullValue returns a NULL AttributeValue.
+func NullValue() *dynamodb.AttributeValue {
+	b := true
+	return &dynamodb.AttributeValue{NULL: &b}
+}
+
+// StringValue returns s as an S, or NULL if it is empty.
+func StringValue(s string) *dynamodb.AttributeValue {
+	if s == "" {
+		return NullValue()
+	}
+	return &dynamodb.AttributeValue{S: &s}
+}
+
+// BoolValue returns b as a BOOL.
+func BoolValue(b bool) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{BOOL: &b}
+}
+
+// IntValue returns n as an N.
+func IntValue(n int64) *dynamodb.AttributeValue {
+	s := strconv.FormatInt(n, 10)
+	return &dynamodb.AttributeValue{N: &s}
+}
+
+// UintValue returns n as an N.
+func UintValue(n uint64) *dynamodb.AttributeValue {
+	s := strconv.FormatUint(n, 10)
+	return &dynamodb.AttributeValue{N: &s}
+}
+
+// FloatValue returns f as an N, or an error if dynamo can't store it.
+func FloatValue(f float64, bitSize int) (*dynamodb.AttributeValue, error) {
+	s, err := FormatFloat(f, bitSize)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.AttributeValue{N: &s}, nil
+}
+
+// FormatFloat formats f as a number, or returns an error if dynamo can't store
+// it.
+func FormatFloat(f float64, bitSize int) (string, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", dynamodb.EncodeError{Message: "NaN and infinite floats not supported", AttributeType: dynamodb.N}
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if err := checkNumber(s); err != nil {
+		return "", dynamodb.EncodeError{Message: err.Error(), AttributeType: dynamodb.N}
+	}
+	return s, nil
+}
+
+// BytesValue returns b as a B, or NULL if it is empty.
+func BytesValue(b []byte) *dynamodb.AttributeValue {
+	if len(b) == 0 {
+		return NullValue()
+	}
+	return &dynamodb.AttributeValue{B: b}
+}
+
+// UnixTimeValue returns t as an N of seconds since the epoch.
+func UnixTimeValue(t time.Time) *dynamodb.AttributeValue {
+	return IntValue(t.Unix())
+}
+
+// SetValue returns the elements of a set as an SS, NS or BS, without
+// duplicates, or NULL if there are none. Elements of a BS are the strings of
+// their bytes. sorted sorts the elements, for sets read from a map.
+func SetValue(setType dynamodb.AttributeValueType, elems []string, sorted bool) *dynamodb.AttributeValue {
+	if len(elems) == 0 {
+		return NullValue()
+	}
+	strs := make([]string, 0, len(elems))
+	seen := make(map[string]bool, len(elems))
+	for _, s := range elems {
+		if !seen[s] {
+			seen[s] = true
+			strs = append(strs, s)
+		}
+	}
+	if sorted {
+		sort.Strings(strs)
+	}
+
+	switch setType {
+	case dynamodb.BS:
+		out := make([][]byte, len(strs))
+		for i := range strs {
+			out[i] = []byte(strs[i])
+		}
+		return &dynamodb.AttributeValue{BS: out}
+	default:
+		out := make([]*string, len(strs))
+		for i := range strs {
+			out[i] = &strs[i]
+		}
+		if setType == dynamodb.NS {
+			return &dynamodb.AttributeValue{NS: out}
+		}
+		return &dynamodb.AttributeValue{SS: out}
+	}
+}
+
+// SetList returns the elements of an SS, NS or BS as an L of S, N or B
+// attributes, the way sets are decoded into slices, arrays and maps.
+func SetList(attr *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{L: setElements(attr)}
+}
+
+// MarshalerValue encodes m with its MarshalDynamoDBAttributeValue method.
+func MarshalerValue(m dynamodb.Marshaler) (*dynamodb.AttributeValue, error) {
+	attr, err := m.MarshalDynamoDBAttributeValue()
+	if err != nil {
+		if _, ok := err.(dynamodb.EncodeError); ok {
+			return nil, err
+		}
+		return nil, dynamodb.EncodeError{Message: fmt.Sprintf("error encoding custom value type %T: %s", m, err.Error())}
+	}
+	if attr == nil || !attr.IsValid() {
+		return NullValue(), nil
+	}
+	return attr, nil
+}
+
+// TextValue encodes m with its MarshalText method as an S, or NULL if the text
+// is empty.
+func TextValue(m encoding.TextMarshaler) (*dynamodb.AttributeValue, error) {
+	b, err := m.MarshalText()
+	if err != nil {
+		return nil, dynamodb.EncodeError{Message: fmt.Sprintf("error encoding opaque value type: %s", err.Error())}
+	}
+	return StringValue(string(b)), nil
+}
+
+// DecodeInt parses the N n into an integer of bitSize bits. typeName names the
+// type being decoded, for errors.
+func DecodeInt(n string, bitSize int, typeName string) (int64, error) {
+	i, err := strconv.ParseInt(n, 10, bitSize)
+	if err != nil {
+		return 0, overflowError(n, typeName)
+	}
+	return i, nil
+}
+
+// DecodeUint parses the N n into an unsigned integer of bitSize bits.
+func DecodeUint(n string, bitSize int, typeName string) (uint64, error) {
+	i, err := strconv.ParseUint(n, 10, bitSize)
+	if err != nil {
+		return 0, overflowError(n, typeName)
+	}
+	return i, nil
+}
+
+// DecodeFloat parses the N n into a float of bitSize bits.
+func DecodeFloat(n string, bitSize int, typeName string) (float64, error) {
+	f, err := strconv.ParseFloat(n, bitSize)
+	if err != nil {
+		return 0, overflowError(n, typeName)
+	}
+	return f, nil
+}
+
+func overflowError(n, typeName string) error {
+	return dynamodb.DecodeError{Message: fmt.Sprintf("overflow number %s for type %s", n, typeName), IsNumericOverflow: true, AttributeType: dynamodb.N}
+}
+
+// DecodeBytes returns the bytes of a B, or of an S holding base64. Any other
+// attribute is an error.
+func DecodeBytes(attr *dynamodb.AttributeValue) ([]byte, error) {
+	if attr.B != nil {
+		return attr.B, nil
+	}
+	if attr.S == nil {
+		return nil, dynamodb.DecodeError{Message: fmt.Sprintf("cannot decode %s attribute into bytes", attr.Type().String()), AttributeType: attr.Type()}
+	}
+	b, err := base64.StdEncoding.DecodeString(*attr.S)
+	if err != nil {
+		return nil, dynamodb.DecodeError{Message: fmt.Sprintf("cannot base64 decode string: %s", err.Error()), AttributeType: dynamodb.S}
+	}
+	return b, nil
+}
+
+// DecodeUnixTime parses the N n as a number of seconds since the epoch.
+func DecodeUnixTime(n string) (time.Time, error) {
+	i, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return time.Time{}, dynamodb.DecodeError{Message: fmt.Sprintf("cannot parse unix time %s", n), AttributeType: dynamodb.N}
+	}
+	return time.Unix(i, 0), nil
+}
+
+// DecodeQuoted converts the S s of a field with the string tag option back into
+// a BOOL, if isBool, or an N.
+func DecodeQuoted(s string, isBool bool, typeName string) (*dynamodb.AttributeValue, error) {
+	if isBool {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, dynamodb.DecodeError{Message: fmt.Sprintf("cannot parse %q into type %s", s, typeName), AttributeType: dynamodb.S}
+		}
+		return &dynamodb.AttributeValue{BOOL: &b}, nil
+	}
+	if err := checkNumber(s); err != nil {
+		return nil, dynamodb.DecodeError{Message: fmt.Sprintf("cannot parse %q into type %s", s, typeName), AttributeType: dynamodb.S}
+	}
+	return &dynamodb.AttributeValue{N: &s}, nil
+}
+
+// DecodeUnmarshaler decodes attr with u's UnmarshalDynamoDBAttributeValue
+// method.
+func DecodeUnmarshaler(attr *dynamodb.AttributeValue, u dynamodb.Unmarshaler) error {
+	if err := u.UnmarshalDynamoDBAttributeValue(attr); err != nil {
+		if _, ok := err.(dynamodb.DecodeError); ok {
+			return err
+		}
+		return dynamodb.DecodeError{Message: fmt.Sprintf("error decoding custom value type %T: %s", u, err.Error()), AttributeType: attr.Type()}
+	}
+	return nil
+}
+
+// DecodeText decodes the S s with u's UnmarshalText method.
+func DecodeText(s string, u encoding.TextUnmarshaler) error {
+	if err := u.UnmarshalText([]byte(s)); err != nil {
+		return dynamodb.DecodeError{Message: fmt.Sprintf("error decoding text value type: %s", err.Error()), AttributeType: dynamodb.S}
+	}
+	return nil
+}
+
+// ErrorAt prepends path to the path of err if it is an EncodeError or
+// DecodeError. Each element of path is an attribute name or a list index.
+func ErrorAt(err error, path ...interface{}) error {
+	for i := len(path) - 1; i >= 0; i-- {
+		var elem string
+		switch p := path[i].(type) {
+		case int:
+			elem = indexPath(p)
+		default:
+			elem = fmt.Sprint(p)
+		}
+		switch e := err.(type) {
+		case dynamodb.EncodeError:
+			e.Path = joinPath(elem, e.Path)
+			err = e
+		case dynamodb.DecodeError:
+			e.Path = joinPath(elem, e.Path)
+			err = e
+		default:
+			return err
+		}
+	}
+	return err
+}
+
+// checkNumber returns an error if s isn't a number dynamo can store.
+func checkNumber(s string) error {
+	if vs := (&dynamodb.AttributeValue{N: &s}).Validate(); len(vs) > 0 {
+		return errors.New(vs[0].Message)
+	}
+	return nil
+}
+
+// setElements returns the elements of a set as S, N or B attributes.
+func setElements(attr *dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	var out []*dynamodb.AttributeValue
+	switch {
+	case attr.SS != nil:
+		out = make([]*dynamodb.AttributeValue, len(attr.SS))
+		for i, s := range attr.SS {
+			out[i] = &dynamodb.AttributeValue{S: s}
+		}
+	case attr.NS != nil:
+		out = make([]*dynamodb.AttributeValue, len(attr.NS))
+		for i, n := range attr.NS {
+			out[i] = &dynamodb.AttributeValue{N: n}
+		}
+	case attr.BS != nil:
+		out = make([]*dynamodb.AttributeValue, len(attr.BS))
+		for i, b := range attr.BS {
+			out[i] = &dynamodb.AttributeValue{B: b}
+		}
+	}
+	return out
+}
+
+// joinPath prepends an attribute name or list index to an attribute path.
+func joinPath(elem, path string) string {
+	if path == "" {
+		return elem
+	}
+	if path[0] == '[' {
+		return elem + path
+	}
+	return elem + "." + path
+}
+
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
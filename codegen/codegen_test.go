@@ -0,0 +1,40 @@
+package codegen_test
+
+import (
+	"backflip/aws/dynamodb"
+	. "backflip/aws/dynamodb/codegen"
+	"backflip/tools/testutils"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestCodegen(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	ck.Suite(&GeneratedSuite{})
+	ck.TestingT(t)
+}
+
+type GeneratedSuite struct {
+}
+
+func (s *GeneratedSuite) TestDecodeBytes(c *ck.C) {
+	b, err := DecodeBytes(&dynamodb.AttributeValue{B: []byte{1, 2}})
+	c.Assert(err, ck.IsNil)
+	c.Check(b, ck.DeepEquals, []byte{1, 2})
+
+	str := "AQI="
+	b, err = DecodeBytes(&dynamodb.AttributeValue{S: &str})
+	c.Assert(err, ck.IsNil)
+	c.Check(b, ck.DeepEquals, []byte{1, 2})
+
+	str = "!!"
+	_, err = DecodeBytes(&dynamodb.AttributeValue{S: &str})
+	c.Check(err, ck.ErrorMatches, "aws.dynamodb.DecodeError: cannot base64 decode string: .*")
+
+	n := "1"
+	_, err = DecodeBytes(&dynamodb.AttributeValue{N: &n})
+	c.Check(err, ck.ErrorMatches, "aws.dynamodb.DecodeError: cannot decode N attribute into bytes")
+	_, err = DecodeBytes(NullValue())
+	c.Check(err, ck.FitsTypeOf, dynamodb.DecodeError{})
+}
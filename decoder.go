@@ -6,376 +6,1025 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 func Decode(data []byte, item interface{}) error {
-	root, err := DecodeToAttributeValue(data)
-	if err != nil {
-		return err
-	}
-	err = DecodeAttributeValueToInterface(root, item)
-	return err
+	return defaultDecoder.Decode(data, item)
 }
 
 func DecodeToAttributeValue(data []byte) (*AttributeValue, error) {
 	root := &AttributeValue{}
 	if err := json.Unmarshal(data, root); err != nil {
-		return nil, DecodeError{err.Error(), false}
+		if de, ok := err.(DecodeError); ok {
+			return nil, de
+		}
+		return nil, DecodeError{Message: err.Error()}
 	}
 	return root, nil
 }
 
 func DecodeAttributeValueToInterface(attr *AttributeValue, item interface{}) error {
+	return defaultDecoder.DecodeAttributeValueToInterface(attr, item)
+}
+
+// A Decoder decodes AttributeValues into Go values. By default decoding is
+// lenient: attributes without a matching struct field are skipped and
+// attributes whose type doesn't fit the target are ignored. A Decoder is safe
+// for concurrent use, and should be reused since it caches the decoders it
+// builds for each type.
+type Decoder struct {
+	config
+	disallowUnknownFields bool
+	strictTypes           bool
+	numbers               NumberHandling
+
+	codecs *codecSet
+}
+
+func NewDecoder(opts ...DecoderOption) *Decoder {
+	dec := &Decoder{}
+	for _, opt := range opts {
+		opt.applyDecoder(dec)
+	}
+	dec.codecs = newCodecSet(dec.config)
+	return dec
+}
+
+var defaultDecoder = NewDecoder()
+
+func (dec *Decoder) Decode(data []byte, item interface{}) error {
+	root, err := DecodeToAttributeValue(data)
+	if err != nil {
+		return err
+	}
+	return dec.DecodeAttributeValueToInterface(root, item)
+}
+
+// DecodeAttributeValueToInterface decodes attr into item. Decoding stops at the
+// first hard error, such as a numeric overflow. Mismatches reported because of
+// DisallowUnknownFields or StrictTypes don't stop decoding; they are all
+// returned together in a single DecodeError, listed by its Errors method.
+func (dec *Decoder) DecodeAttributeValueToInterface(attr *AttributeValue, item interface{}) error {
+	d := &decodeState{Decoder: dec}
 	v := reflect.ValueOf(item)
 	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
-		return decodeAttribute(attr, v.Elem())
-	} else {
-		return decodeAttribute(attr, v)
+		v = v.Elem()
+	}
+	if err := dec.codecs.typeDecoder(v.Type())(d, attr, v); err != nil {
+		return err
 	}
+	return d.mismatchError()
+}
+
+// AttributeNames returns the names of the attributes decoded into the fields of
+// a struct, in field order. v may be a struct, a pointer to one or its
+// reflect.Type. It returns nil for any other type.
+func AttributeNames(v interface{}) []string {
+	return defaultDecoder.AttributeNames(v)
+}
+
+// AttributeNames is like the package function of the same name, but applies the
+// Decoder's TagName option.
+func (dec *Decoder) AttributeNames(v interface{}) []string {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := cachedTypeFields(t, dec.tagName)
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// decodeState holds the state of a single decode call.
+type decodeState struct {
+	*Decoder
+
+	// path to the value being decoded, only tracked when mismatches are
+	// reported since it isn't needed otherwise.
+	path       []pathElem
+	mismatches []DecodeError
+}
+
+type pathElem struct {
+	name      string
+	st        reflect.Type
+	fieldName string
+}
+
+func (d *decodeState) tracking() bool {
+	return d.disallowUnknownFields || d.strictTypes
+}
+
+func (d *decodeState) push(name string, st reflect.Type, fieldName string) {
+	d.path = append(d.path, pathElem{name, st, fieldName})
+}
+
+func (d *decodeState) pop() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+// addMismatch records de at the current path.
+func (d *decodeState) addMismatch(de DecodeError) {
+	for i := len(d.path) - 1; i >= 0; i-- {
+		e := d.path[i]
+		de.Path = joinPath(e.name, de.Path)
+		if de.Struct == nil && e.st != nil {
+			de.Struct = e.st
+			de.Field = e.fieldName
+		}
+	}
+	d.mismatches = append(d.mismatches, de)
+}
+
+// typeMismatch records that attr can't be decoded into a value of type t.
+func (d *decodeState) typeMismatch(attr *AttributeValue, t reflect.Type) {
+	if d.strictTypes {
+		d.addMismatch(DecodeError{Message: fmt.Sprintf("cannot decode %s attribute into type %s", attr.Type().String(), t.String()), AttributeType: attr.Type()})
+	}
+}
+
+func (d *decodeState) mismatchError() error {
+	if len(d.mismatches) == 0 {
+		return nil
+	}
+	sort.Slice(d.mismatches, func(i, j int) bool { return d.mismatches[i].Path < d.mismatches[j].Path })
+	msgs := make([]string, len(d.mismatches))
+	for i, de := range d.mismatches {
+		msgs[i] = de.Path + ": " + de.Message
+	}
+	return DecodeError{
+		Message:    fmt.Sprintf("%d attributes could not be decoded: %s", len(msgs), strings.Join(msgs, "; ")),
+		mismatches: &d.mismatches,
+	}
+}
+
+// Unmarshaler is the interface implemented by types that can decode an
+// AttributeValue into themselves. It takes precedence over json.Unmarshaler and
+// encoding.TextUnmarshaler.
+type Unmarshaler interface {
+	UnmarshalDynamoDBAttributeValue(*AttributeValue) error
 }
 
 type DecodeError struct {
 	Message           string
 	IsNumericOverflow bool
+
+	// Path is the attribute path of the value that failed to decode, such as
+	// Orders[3].Lines.sku, or empty if the root value failed.
+	Path string
+	// Struct and Field identify the innermost struct field being decoded, if any.
+	Struct reflect.Type
+	Field  string
+	// AttributeType is the type of the attribute that failed to decode.
+	AttributeType AttributeValueType
+
+	// mismatches is a pointer so that DecodeError stays comparable.
+	mismatches *[]DecodeError
 }
 
 func (e DecodeError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("aws.dynamodb.DecodeError: %s: %s", e.Path, e.Message)
+	}
 	return fmt.Sprintf("aws.dynamodb.DecodeError: %s", e.Message)
 }
 
-// private
-func decodeStruct(attrs AttributeValueMap, v reflect.Value) error {
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	fields := cachedTypeFields(v.Type())
-	for k, attr := range attrs {
-		// find actual key name since a json specifier can override the
-		// go structure's field name.
-		var fv reflect.Value
-		for _, f := range fields {
-			if f.name == k {
-				subv := v
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
-				}
-				fv = subv
-				break
-			}
-		}
-		if !fv.IsValid() {
-			continue
-		}
-		if err := decodeAttribute(attr, fv); err != nil {
-			return err
-		}
+// Errors lists every mismatch found by a Decoder using DisallowUnknownFields
+// or StrictTypes, sorted by path. It is nil for other errors.
+func (e DecodeError) Errors() []DecodeError {
+	if e.mismatches == nil {
+		return nil
 	}
-	return nil
+	return *e.mismatches
 }
 
-func decodeMap(attr AttributeValueMap, v reflect.Value) error {
-	// map must have string kind
-	if !v.IsValid() {
-		v.Set(reflect.MakeMap(v.Type()))
+// decodeErrorAt prepends a path element to err if it is a DecodeError, and
+// records the struct field it occurred in if that isn't already known.
+func decodeErrorAt(err error, elem string, st reflect.Type, fieldName string) error {
+	de, ok := err.(DecodeError)
+	if !ok {
+		return err
 	}
-	elemType := v.Type().Elem()
-	for key, subAttr := range attr {
-		value := reflect.New(elemType).Elem()
-		if err := decodeAttribute(subAttr, value); err != nil {
-			return err
-		}
-		kv := reflect.ValueOf(key).Convert(v.Type().Key())
-		v.SetMapIndex(kv, value)
+	de.Path = joinPath(elem, de.Path)
+	if de.Struct == nil && st != nil {
+		de.Struct = st
+		de.Field = fieldName
 	}
-	return nil
+	return de
 }
 
-func decodeArray(attr *AttributeValue, v reflect.Value) error {
-	t := v.Type()
+// A decoderFunc decodes an AttributeValue into a settable value of a single
+// type. Like encoders, decoders are built once per type and cached.
+type decoderFunc func(d *decodeState, attr *AttributeValue, v reflect.Value) error
 
-	if attr.NULL != nil || attr.L == nil {
-		v.Set(reflect.Zero(t))
-		return nil
+// typeDecoder returns the cached decoder for a type, building it if needed.
+func (c *codecSet) typeDecoder(t reflect.Type) decoderFunc {
+	if fi, ok := c.decoders.Load(t); ok {
+		return fi.(decoderFunc)
 	}
 
-	if t.Kind() == reflect.Slice {
-		v.Set(reflect.MakeSlice(v.Type(), len(attr.L), len(attr.L)))
+	// See typeEncoder for how recursive types are handled.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := c.decoders.LoadOrStore(t, decoderFunc(func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+		wg.Wait()
+		return f(d, attr, v)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
 	}
 
-	vlen := v.Len()
+	f = c.newTypeDecoder(t, true)
+	wg.Done()
+	c.decoders.Store(t, f)
+	return f
+}
 
-	if vlen == 0 {
-		return nil
+// newTypeDecoder builds a decoder for a type. If allowAddr is true and the
+// type's pointer implements one of the unmarshaling interfaces, addressable
+// values are decoded through their address.
+func (c *codecSet) newTypeDecoder(t reflect.Type, allowAddr bool) decoderFunc {
+	if codec, ok := c.codecs[t]; ok {
+		return newCodecDecoder(codec)
+	}
+	if t.Kind() == reflect.Ptr {
+		return c.newPtrDecoder(t)
+	}
+	if t == timeType && c.timeFormat != "" {
+		return c.timeDecoder
+	}
+	if t == numberType {
+		return numberDecoder
+	}
+	if isNumberType(t) {
+		return bigNumberDecoder
 	}
 
-	switch t.Elem().Kind() {
-	case reflect.Interface:
-		if t.Elem().NumMethod() != 0 {
-			// TODO: If custom decoding hooks can be provided, support this
-			return DecodeError{fmt.Sprintf("cannot decode into array of non-empty interface types: %s", v.Type().String()), false}
+	if t.Kind() != reflect.Interface {
+		for _, m := range []struct {
+			typ reflect.Type
+			dec decoderFunc
+		}{
+			{UnmarshalerType, decodeUnmarshalerValue},
+			{JSONUnmarshalerType, decodeJSONValue},
+			{TextUnmarshalerType, decodeTextValue},
+		} {
+			if allowAddr && reflect.PtrTo(t).Implements(m.typ) {
+				return newCondAddrDecoder(m.dec, c.newTypeDecoder(t, false))
+			}
+			if t.Implements(m.typ) {
+				return m.dec
+			}
 		}
+	}
 
-		i := 0
-		alen := len(attr.L)
-		for ; i < vlen && i < alen; i++ {
-			if err := decodeAttribute(attr.L[i], v.Index(i)); err != nil {
-				return err
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolDecoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intDecoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintDecoder
+	case reflect.Float32, reflect.Float64:
+		return floatDecoder
+	case reflect.String:
+		return stringDecoder
+	case reflect.Struct:
+		return c.newStructDecoder(t)
+	case reflect.Map:
+		return c.newMapDecoder(t)
+	case reflect.Slice:
+		// []byte handling
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bytesDecoder
+		}
+		return c.newArrayDecoder(t)
+	case reflect.Array:
+		return c.newArrayDecoder(t)
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			// TODO: Might be worth adding a custom demarshalling hook
+			return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+				return DecodeError{Message: fmt.Sprintf("cannot decode into non-empty interface type: %s", v.Type().String()), AttributeType: attr.Type()}
 			}
 		}
-
-		// zero out the rest
-		for ; i < vlen; i++ {
-			v.Index(i).Set(reflect.Zero(t.Elem()))
+		return c.interfaceDecoder
+	default:
+		return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+			return DecodeError{Message: fmt.Sprintf("aws.dynamodb.EncodeError: unsupported type for field: %#v", v.Type()), AttributeType: attr.Type()}
 		}
+	}
+}
 
-		return nil
+// newCondAddrDecoder returns a decoder that uses addrDec on addressable
+// values, and elseDec otherwise.
+func newCondAddrDecoder(addrDec, elseDec decoderFunc) decoderFunc {
+	return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+		if v.CanAddr() {
+			return addrDec(d, attr, v)
+		}
+		return elseDec(d, attr, v)
+	}
+}
 
-	default:
-		i := 0
-		alen := len(attr.L)
-		for ; i < vlen && i < alen; i++ {
-			av := v.Index(i)
-			if err := decodeAttribute(attr.L[i], av); err != nil {
+func newCodecDecoder(codec Codec) decoderFunc {
+	return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+		pv := reflect.New(v.Type())
+		if err := codec.Decode(attr, pv.Interface()); err != nil {
+			if _, ok := err.(DecodeError); ok {
 				return err
 			}
-			if !av.IsValid() || !av.Type().AssignableTo(t.Elem()) {
-				return DecodeError{fmt.Sprintf("could not assign list value %s to array element type %s", v.Type().String(), t.Elem().String()), false}
-			}
+			return DecodeError{Message: fmt.Sprintf("error decoding custom value type %s: %s", v.Type().String(), err.Error()), AttributeType: attr.Type()}
 		}
+		v.Set(pv.Elem())
+		return nil
+	}
+}
 
-		for ; i < vlen; i++ {
-			v.Index(i).Set(reflect.Zero(t.Elem()))
+// timeDecoder decodes time.Time values encoded with the TimeFormat option, and
+// falls back to JSON for B attributes written without it.
+func (c *codecSet) timeDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	switch {
+	case attr.S != nil:
+		t, err := time.Parse(c.timeFormat, *attr.S)
+		if err != nil {
+			return DecodeError{Message: fmt.Sprintf("cannot parse time %q: %s", *attr.S, err.Error()), AttributeType: attr.Type()}
 		}
+		v.Set(reflect.ValueOf(t))
+	case attr.B != nil:
+		return decodeJSONValue(d, attr, v)
+	case attr.NULL != nil:
+		v.Set(reflect.Zero(v.Type()))
+	default:
+		d.typeMismatch(attr, v.Type())
 	}
 	return nil
 }
 
-var imapType = reflect.TypeOf(map[string]interface{}{})
-var ilistType = reflect.TypeOf([]interface{}{})
-
-func decodeJSONValue(attr *AttributeValue, v reflect.Value) error {
-	if attr.B != nil {
-		if v.Kind() != reflect.Ptr && v.CanAddr() {
-			v = v.Addr()
+func (c *codecSet) newPtrDecoder(t reflect.Type) decoderFunc {
+	elemDec := c.typeDecoder(t.Elem())
+	return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+		if attr.NULL != nil {
+			v.Set(reflect.Zero(t))
+			return nil
 		}
-		if err := json.Unmarshal(attr.B, v.Interface()); err != nil {
-			return DecodeError{fmt.Sprintf("error decoding json value type: %s", err.Error()), false}
+		v.Set(reflect.New(t.Elem()))
+		return elemDec(d, attr, v.Elem())
+	}
+}
+
+func boolDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.BOOL != nil {
+		v.SetBool(*attr.BOOL)
+	} else if attr.NULL != nil {
+		v.SetBool(false)
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
+
+func intDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.N != nil {
+		n, err := strconv.ParseInt(*attr.N, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return DecodeError{Message: fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), IsNumericOverflow: true, AttributeType: attr.Type()}
 		}
+		v.SetInt(n)
 	} else if attr.NULL != nil {
 		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
 	}
 	return nil
 }
 
-func decodeTextValue(attr *AttributeValue, v reflect.Value) error {
-	if attr.S != nil {
-		if v.Kind() != reflect.Ptr && v.CanAddr() {
-			v = v.Addr()
+func uintDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.N != nil {
+		n, err := strconv.ParseUint(*attr.N, 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			return DecodeError{Message: fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), IsNumericOverflow: true, AttributeType: attr.Type()}
 		}
-		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(*attr.S)); err != nil {
-			return DecodeError{fmt.Sprintf("error decoding text value type: %s", err.Error()), false}
+		v.SetUint(n)
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
+
+func floatDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.N != nil {
+		n, err := strconv.ParseFloat(*attr.N, v.Type().Bits())
+		if err != nil || v.OverflowFloat(n) {
+			return DecodeError{Message: fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), IsNumericOverflow: true, AttributeType: attr.Type()}
 		}
+		v.SetFloat(n)
 	} else if attr.NULL != nil {
 		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
 	}
 	return nil
 }
 
-var JSONUnmarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
-var TextUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+func stringDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.S != nil {
+		v.SetString(*attr.S)
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
 
-func decodeAttribute(attr *AttributeValue, v reflect.Value) error {
-	if v.Kind() == reflect.Ptr {
+func bytesDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	switch {
+	case attr.B != nil:
+		v.SetBytes(attr.B[0:len(attr.B)])
+
+	case attr.S != nil:
+		b, err := base64.StdEncoding.DecodeString(*attr.S)
+		if err != nil {
+			return DecodeError{Message: fmt.Sprintf("cannot base64 decode string: %s", err.Error()), AttributeType: attr.Type()}
+		}
+		v.SetBytes(b)
+
+	case attr.NULL != nil:
+		v.Set(reflect.Zero(v.Type()))
+
+	default:
+		// silently ignore failed coercion unless types are strict
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
+
+type structDecoder struct {
+	typ      reflect.Type
+	fields   []field
+	byName   map[string]int
+	decoders []decoderFunc
+}
+
+func (c *codecSet) newStructDecoder(t reflect.Type) decoderFunc {
+	fields := cachedTypeFields(t, c.tagName)
+	sd := structDecoder{
+		typ:      t,
+		fields:   fields,
+		byName:   make(map[string]int, len(fields)),
+		decoders: make([]decoderFunc, len(fields)),
+	}
+	for i, f := range fields {
+		// find actual key name since a tag can override the
+		// go structure's field name.
+		sd.byName[f.name] = i
+		sd.decoders[i] = c.newFieldDecoder(f, typeByIndex(t, f.index))
+	}
+	return sd.decode
+}
+
+func (sd structDecoder) decode(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.M == nil {
 		if attr.NULL != nil {
 			v.Set(reflect.Zero(v.Type()))
-			return nil
 		} else {
-			v.Set(reflect.New(v.Type().Elem()))
-			v = v.Elem()
+			d.typeMismatch(attr, v.Type())
 		}
+		return nil
 	}
 
-	if v.Type().NumMethod() > 0 {
-		if v.Type().Implements(JSONUnmarshalerType) {
-			return decodeJSONValue(attr, v)
-		} else if v.Type().Implements(TextUnmarshalerType) {
-			return decodeTextValue(attr, v)
+	tracking := d.tracking()
+	for k, subAttr := range attr.M {
+		i, ok := sd.byName[k]
+		if !ok {
+			if d.disallowUnknownFields {
+				d.push(k, nil, "")
+				d.addMismatch(DecodeError{Message: fmt.Sprintf("unknown attribute for type %s", sd.typ.String()), Struct: sd.typ, AttributeType: subAttr.Type()})
+				d.pop()
+			}
+			continue
+		}
+		f := &sd.fields[i]
+		fv := settableField(v, f.index)
+		if tracking {
+			d.push(k, fieldOwner(sd.typ, f.index), f.goName)
+		}
+		err := sd.decoders[i](d, subAttr, fv)
+		if tracking {
+			d.pop()
+		}
+		if err != nil {
+			return decodeErrorAt(err, k, fieldOwner(v.Type(), f.index), f.goName)
 		}
 	}
+	return nil
+}
 
-	if v.Kind() != reflect.Ptr && v.CanAddr() {
-		addr := v.Addr()
-		if addr.Type().NumMethod() > 0 {
-			if addr.Type().Implements(JSONUnmarshalerType) {
-				return decodeJSONValue(attr, addr)
-			} else if addr.Type().Implements(TextUnmarshalerType) {
-				return decodeTextValue(attr, v)
+// settableField returns the field of the struct v at index, allocating any
+// nil embedded struct pointers on the way.
+func settableField(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
 			}
+			v = v.Elem()
 		}
+		v = v.Field(i)
 	}
+	return v
+}
 
-	switch v.Kind() {
-	case reflect.Bool:
-		if attr.BOOL != nil {
-			v.Set(reflect.ValueOf(*attr.BOOL))
-		} else if attr.NULL != nil {
-			v.Set(reflect.ValueOf(false))
+// newFieldDecoder builds the decoder for a struct field of type t, applying
+// any dynamodb tag options.
+func (c *codecSet) newFieldDecoder(f field, t reflect.Type) decoderFunc {
+	switch {
+	case f.unixTime:
+		return decodeUnixTime
+	case f.binary:
+		dec := c.typeDecoder(t)
+		return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+			if attr.B != nil {
+				return decodeBinary(d, attr, v)
+			}
+			return dec(d, attr, v)
+		}
+	case f.quoted && isQuotable(t):
+		return newQuotedDecoder(c.typeDecoder(t), t)
+	default:
+		return c.typeDecoder(t)
+	}
+}
+
+// newQuotedDecoder decodes booleans and numbers stored as S attributes by the
+// string tag option. Other attributes are passed to dec unchanged, so fields
+// still decode from BOOL and N attributes written before the option was added.
+func newQuotedDecoder(dec decoderFunc, t reflect.Type) decoderFunc {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return func(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+		if attr.S == nil {
+			return dec(d, attr, v)
 		}
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if attr.N != nil {
-			n, err := strconv.ParseInt(*attr.N, 10, 64)
-			if err != nil || v.OverflowInt(n) {
-				return DecodeError{fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), true}
+		s := *attr.S
+		if t.Kind() == reflect.Bool {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return DecodeError{Message: fmt.Sprintf("cannot parse %q into type %s", s, t.String()), AttributeType: attr.Type()}
 			}
-			v.SetInt(n)
-		} else if attr.NULL != nil {
-			v.Set(reflect.Zero(v.Type()))
+			return dec(d, &AttributeValue{BOOL: &b}, v)
+		}
+		if err := checkNumber(s); err != nil {
+			return DecodeError{Message: fmt.Sprintf("cannot parse %q into type %s", s, t.String()), AttributeType: attr.Type()}
 		}
+		return dec(d, &AttributeValue{N: &s}, v)
+	}
+}
 
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		if attr.N != nil {
-			n, err := strconv.ParseUint(*attr.N, 10, 64)
-			if err != nil || v.OverflowUint(n) {
-				return DecodeError{fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), true}
-			}
-			v.SetUint(n)
-		} else if attr.NULL != nil {
+func decodeUnixTime(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if attr.NULL != nil {
 			v.Set(reflect.Zero(v.Type()))
+			return nil
 		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if v.Type() != timeType {
+		return DecodeError{Message: fmt.Sprintf("unixtime option requires a time.Time, not %s", v.Type().String()), AttributeType: attr.Type()}
+	}
 
-	case reflect.Float32, reflect.Float64:
-		if attr.N != nil {
-			n, err := strconv.ParseFloat(*attr.N, v.Type().Bits())
-			if err != nil || v.OverflowFloat(n) {
-				return DecodeError{fmt.Sprintf("overflow number %s for type %s", *attr.N, v.Type().String()), true}
-			}
-			v.SetFloat(n)
-		} else if attr.NULL != nil {
-			v.Set(reflect.Zero(v.Type()))
+	if attr.N != nil {
+		n, err := strconv.ParseInt(*attr.N, 10, 64)
+		if err != nil {
+			return DecodeError{Message: fmt.Sprintf("cannot parse unix time %s", *attr.N), AttributeType: attr.Type()}
 		}
+		v.Set(reflect.ValueOf(time.Unix(n, 0)))
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
 
-	case reflect.String:
-		if attr.S != nil {
-			v.SetString(*attr.S)
-		} else if attr.NULL != nil {
-			v.Set(reflect.Zero(v.Type()))
+// decodeBinary decodes a B attribute into a string, []byte or encoding.TextUnmarshaler.
+func decodeBinary(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(TextUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(attr.B); err != nil {
+			return DecodeError{Message: fmt.Sprintf("error decoding text value type: %s", err.Error()), AttributeType: attr.Type()}
 		}
+		return nil
+	}
 
-	case reflect.Struct:
-		if attr.M != nil {
-			if err := decodeStruct(attr.M, v); err != nil {
-				return err
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(attr.B))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(attr.B[0:len(attr.B)])
+	default:
+		return DecodeError{Message: fmt.Sprintf("binary option requires a string, []byte or encoding.TextUnmarshaler, not %s", v.Type().String()), AttributeType: attr.Type()}
+	}
+	return nil
+}
+
+type mapDecoder struct {
+	keyDec  decoderFunc
+	nameDec func(name string) (reflect.Value, error)
+	elemDec decoderFunc
+}
+
+func (c *codecSet) newMapDecoder(t reflect.Type) decoderFunc {
+	md := mapDecoder{c.typeDecoder(t.Key()), newMapKeyDecoder(t.Key()), c.typeDecoder(t.Elem())}
+	return md.decode
+}
+
+// newMapKeyDecoder returns a func converting attribute names to map keys of
+// type t, or nil if t isn't supported. It is the inverse of newMapKeyEncoder,
+// except that as in encoding/json, encoding.TextUnmarshaler takes precedence
+// over string kinds.
+func newMapKeyDecoder(t reflect.Type) func(name string) (reflect.Value, error) {
+	keyError := func(name string) error {
+		return DecodeError{Message: fmt.Sprintf("cannot decode map key %q into type %s", name, t.String()), AttributeType: M}
+	}
+
+	switch {
+	case reflect.PtrTo(t).Implements(TextUnmarshalerType):
+		return func(name string) (reflect.Value, error) {
+			kv := reflect.New(t)
+			if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+				return reflect.Value{}, DecodeError{Message: fmt.Sprintf("error decoding map key %q into type %s: %s", name, t.String(), err.Error()), AttributeType: M}
 			}
-		} else if attr.NULL != nil {
-			v.Set(reflect.Zero(v.Type()))
+			return kv.Elem(), nil
+		}
+	case t.Kind() == reflect.String:
+		return func(name string) (reflect.Value, error) {
+			return reflect.ValueOf(name).Convert(t), nil
 		}
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(name string) (reflect.Value, error) {
+			n, err := strconv.ParseInt(name, 10, 64)
+			kv := reflect.New(t).Elem()
+			if err != nil || kv.OverflowInt(n) {
+				return reflect.Value{}, keyError(name)
+			}
+			kv.SetInt(n)
+			return kv, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(name string) (reflect.Value, error) {
+			n, err := strconv.ParseUint(name, 10, 64)
+			kv := reflect.New(t).Elem()
+			if err != nil || kv.OverflowUint(n) {
+				return reflect.Value{}, keyError(name)
+			}
+			kv.SetUint(n)
+			return kv, nil
+		}
+	}
+	return nil
+}
 
-	case reflect.Map:
-		if attr.M != nil {
-			// map must have string kind
-			t := v.Type()
-			if t.Key().Kind() != reflect.String {
-				return DecodeError{fmt.Sprintf("cannot decode a map with a non-string key: %s", t.Key().String()), false}
+func (md mapDecoder) decode(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	t := v.Type()
+	switch {
+	case isSet(attr):
+		return md.decodeSet(d, attr, v)
+
+	case attr.M != nil:
+		if md.nameDec == nil {
+			return DecodeError{Message: fmt.Sprintf("unsupported map key type %s", t.Key().String()), AttributeType: attr.Type()}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		elemType := t.Elem()
+		tracking := d.tracking()
+		for key, subAttr := range attr.M {
+			kv, err := md.nameDec(key)
+			if err != nil {
+				return decodeErrorAt(err, key, nil, "")
 			}
-			if v.IsNil() {
-				v.Set(reflect.MakeMap(t))
+			value := reflect.New(elemType).Elem()
+			if tracking {
+				d.push(key, nil, "")
 			}
-			if err := decodeMap(attr.M, v); err != nil {
-				return err
+			err = md.elemDec(d, subAttr, value)
+			if tracking {
+				d.pop()
 			}
-		} else if attr.NULL != nil {
-			v.Set(reflect.Zero(v.Type()))
+			if err != nil {
+				return decodeErrorAt(err, key, nil, "")
+			}
+			v.SetMapIndex(kv, value)
 		}
 
-	case reflect.Slice:
-		// []byte handling
-		if v.Type().Elem().Kind() == reflect.Uint8 {
-			switch {
-			case attr.B != nil:
-				v.Set(reflect.ValueOf(attr.B[0:len(attr.B)]))
+	case attr.NULL != nil:
+		v.Set(reflect.Zero(t))
 
-			case attr.S != nil:
-				d, err := base64.StdEncoding.DecodeString(*attr.S)
-				if err != nil {
-					return DecodeError{fmt.Sprintf("cannot base64 decode string: %s", err.Error()), false}
-				}
-				v.Set(reflect.ValueOf(d))
+	default:
+		d.typeMismatch(attr, t)
+	}
+	return nil
+}
 
-			case attr.NULL != nil:
-				v.Set(reflect.Zero(v.Type()))
+// setElements expands a set into the equivalent list of scalar attributes so
+// it can be decoded by the list and map code paths.
+func setElements(attr *AttributeValue) []*AttributeValue {
+	var out []*AttributeValue
+	switch {
+	case attr.SS != nil:
+		out = make([]*AttributeValue, len(attr.SS))
+		for i, s := range attr.SS {
+			out[i] = &AttributeValue{S: s}
+		}
+	case attr.NS != nil:
+		out = make([]*AttributeValue, len(attr.NS))
+		for i, n := range attr.NS {
+			out[i] = &AttributeValue{N: n}
+		}
+	case attr.BS != nil:
+		out = make([]*AttributeValue, len(attr.BS))
+		for i, b := range attr.BS {
+			out[i] = &AttributeValue{B: b}
+		}
+	}
+	return out
+}
 
-			default:
-				// nothing to do, silently ignore failed coercion
-			}
-			return nil
+func isSet(attr *AttributeValue) bool {
+	return attr.SS != nil || attr.NS != nil || attr.BS != nil
+}
+
+// decodeSet decodes a set into a map[T]struct{} or map[T]bool.
+func (md mapDecoder) decodeSet(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	t := v.Type()
+	elemType := t.Elem()
+	isEmptyStruct := elemType.Kind() == reflect.Struct && elemType.NumField() == 0
+	if !isEmptyStruct && elemType.Kind() != reflect.Bool {
+		return DecodeError{Message: fmt.Sprintf("cannot decode set %s into map type %s", attr.Type().String(), t.String()), AttributeType: attr.Type()}
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	elemValue := reflect.New(elemType).Elem()
+	if elemType.Kind() == reflect.Bool {
+		elemValue.SetBool(true)
+	}
+	tracking := d.tracking()
+	for i, elem := range setElements(attr) {
+		key := reflect.New(t.Key()).Elem()
+		if tracking {
+			d.push(indexPath(i), nil, "")
+		}
+		err := md.keyDec(d, elem, key)
+		if tracking {
+			d.pop()
 		}
+		if err != nil {
+			return decodeErrorAt(err, indexPath(i), nil, "")
+		}
+		v.SetMapIndex(key, elemValue)
+	}
+	return nil
+}
 
-		fallthrough
+type arrayDecoder struct {
+	elemDec decoderFunc
+}
 
-	case reflect.Array:
-		return decodeArray(attr, v)
+func (c *codecSet) newArrayDecoder(t reflect.Type) decoderFunc {
+	ad := arrayDecoder{c.typeDecoder(t.Elem())}
+	return ad.decode
+}
 
-	case reflect.Interface:
-		if v.NumMethod() != 0 {
-			// TODO: Might be worth adding a custom demarshalling hook
-			return DecodeError{fmt.Sprintf("cannot decode into non-empty interface type: %s", v.Type().String()), false}
+func (ad arrayDecoder) decode(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	t := v.Type()
+
+	if isSet(attr) {
+		attr = &AttributeValue{L: setElements(attr)}
+	}
+
+	if attr.NULL != nil || attr.L == nil {
+		if attr.NULL == nil {
+			d.typeMismatch(attr, t)
 		}
+		v.Set(reflect.Zero(t))
+		return nil
+	}
 
-		switch {
-		case attr.B != nil:
-			v.Set(reflect.ValueOf(attr.B[0:len(attr.B)]))
-			break
+	if t.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(t, len(attr.L), len(attr.L)))
+	}
 
-		case attr.BOOL != nil:
-			v.Set(reflect.ValueOf(*attr.BOOL))
+	vlen := v.Len()
 
-		case attr.S != nil:
-			v.Set(reflect.ValueOf(*attr.S))
+	if vlen == 0 {
+		return nil
+	}
 
-		case attr.N != nil:
-			var n float64
-			var err error
-			n, err = strconv.ParseFloat(*attr.N, 64)
-			if err != nil {
-				return DecodeError{fmt.Sprintf("error parsing number %s into type float64", *attr.N), false}
-			}
-			v.Set(reflect.ValueOf(n))
+	i := 0
+	alen := len(attr.L)
+	tracking := d.tracking()
+	for ; i < vlen && i < alen; i++ {
+		if tracking {
+			d.push(indexPath(i), nil, "")
+		}
+		err := ad.elemDec(d, attr.L[i], v.Index(i))
+		if tracking {
+			d.pop()
+		}
+		if err != nil {
+			return decodeErrorAt(err, indexPath(i), nil, "")
+		}
+	}
 
-		case attr.NULL != nil:
-			v.Set(reflect.Zero(v.Type()))
-			break
+	// zero out the rest
+	for ; i < vlen; i++ {
+		v.Index(i).Set(reflect.Zero(t.Elem()))
+	}
+	return nil
+}
 
-		case attr.M != nil:
-			m := reflect.MakeMap(imapType)
-			if err := decodeMap(attr.M, m); err != nil {
-				return err
-			}
-			v.Set(m)
+var imapType = reflect.TypeOf(map[string]interface{}{})
+var ilistType = reflect.TypeOf([]interface{}{})
 
-		case attr.L != nil:
-			l := reflect.New(ilistType)
-			if err := decodeArray(attr, l.Elem()); err != nil {
-				return err
-			}
-			v.Set(l.Elem())
+func (c *codecSet) interfaceDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	switch {
+	case attr.B != nil:
+		v.Set(reflect.ValueOf(attr.B[0:len(attr.B)]))
+
+	case attr.BOOL != nil:
+		v.Set(reflect.ValueOf(*attr.BOOL))
+
+	case attr.S != nil:
+		v.Set(reflect.ValueOf(*attr.S))
+
+	case attr.N != nil:
+		n, err := d.interfaceNumber(attr, *attr.N)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(n))
 
+	case attr.NULL != nil:
+		v.Set(reflect.Zero(v.Type()))
+
+	case attr.M != nil:
+		m := reflect.New(imapType).Elem()
+		if err := c.typeDecoder(imapType)(d, attr, m); err != nil {
+			return err
+		}
+		v.Set(m)
+
+	case attr.L != nil:
+		l := reflect.New(ilistType).Elem()
+		if err := c.typeDecoder(ilistType)(d, attr, l); err != nil {
+			return err
+		}
+		v.Set(l)
+
+	case attr.SS != nil:
+		ss := make([]string, len(attr.SS))
+		for i, s := range attr.SS {
+			ss[i] = *s
+		}
+		v.Set(reflect.ValueOf(ss))
+
+	case attr.NS != nil:
+		switch d.numbers {
+		case NumberValues:
+			ns := make([]Number, len(attr.NS))
+			for i, n := range attr.NS {
+				ns[i] = Number(*n)
+			}
+			v.Set(reflect.ValueOf(ns))
+		case Int64Numbers:
+			ns := make([]interface{}, len(attr.NS))
+			for i, n := range attr.NS {
+				num, err := d.interfaceNumber(attr, *n)
+				if err != nil {
+					return err
+				}
+				ns[i] = num
+			}
+			v.Set(reflect.ValueOf(ns))
 		default:
-			panic(DecodeError{"unknown error decoding interface value", false})
+			ns := make([]float64, len(attr.NS))
+			for i, n := range attr.NS {
+				num, err := d.interfaceNumber(attr, *n)
+				if err != nil {
+					return err
+				}
+				ns[i] = num.(float64)
+			}
+			v.Set(reflect.ValueOf(ns))
 		}
 
-	case reflect.Ptr:
-		// should have had indirection taken care of above
-		panic(DecodeError{fmt.Sprintf("could not handle multiple layers of pointers"), false})
+	case attr.BS != nil:
+		bs := make([][]byte, len(attr.BS))
+		copy(bs, attr.BS)
+		v.Set(reflect.ValueOf(bs))
+
 	default:
-		return DecodeError{fmt.Sprintf("aws.dynamodb.EncodeError: unsupported type for field: %#v", v.Type()), false}
+		panic(DecodeError{Message: "unknown error decoding interface value"})
+	}
+	return nil
+}
+
+// interfaceNumber parses a number being decoded into an interface{} according
+// to the decoder's NumberHandling.
+func (d *decodeState) interfaceNumber(attr *AttributeValue, s string) (interface{}, error) {
+	if d.numbers == NumberValues {
+		return Number(s), nil
+	}
+	if d.numbers == Int64Numbers {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, DecodeError{Message: fmt.Sprintf("error parsing number %s into type float64", s), AttributeType: attr.Type()}
+	}
+	return n, nil
+}
+
+func decodeUnmarshalerValue(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+	if err := v.Interface().(Unmarshaler).UnmarshalDynamoDBAttributeValue(attr); err != nil {
+		if _, ok := err.(DecodeError); ok {
+			return err
+		}
+		return DecodeError{Message: fmt.Sprintf("error decoding custom value type %s: %s", v.Type().String(), err.Error()), AttributeType: attr.Type()}
+	}
+	return nil
+}
+
+func decodeJSONValue(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.B != nil {
+		if v.Kind() != reflect.Ptr && v.CanAddr() {
+			v = v.Addr()
+		}
+		if err := json.Unmarshal(attr.B, v.Interface()); err != nil {
+			return DecodeError{Message: fmt.Sprintf("error decoding json value type: %s", err.Error()), AttributeType: attr.Type()}
+		}
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
 	}
 	return nil
 }
+
+func decodeTextValue(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.S != nil {
+		if v.Kind() != reflect.Ptr && v.CanAddr() {
+			v = v.Addr()
+		}
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(*attr.S)); err != nil {
+			return DecodeError{Message: fmt.Sprintf("error decoding text value type: %s", err.Error()), AttributeType: attr.Type()}
+		}
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
+
+var UnmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+var JSONUnmarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var TextUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
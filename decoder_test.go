@@ -4,8 +4,11 @@ import (
 	. "backflip/aws/dynamodb"
 	"backflip/tools/testutils"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -361,10 +364,15 @@ func (s *DecoderSuite) TestMaps(c *ck.C) {
 	err = Decode([]byte(`{"M":{"C":{"N":"40.2"}}}`), &x1)
 	c.Assert(err, ErrorMatches, ".*overflow number.*for type int.*")
 
-	// no maps with non-string keys
+	// integer keys must parse
 	x4 := map[int]int{}
 	err = Decode([]byte(`{"M":{"C":{"N":"40.2"}}}`), &x4)
-	c.Assert(err, ErrorMatches, ".*cannot decode.*non-string key.*")
+	c.Assert(err, ErrorMatches, `.*C: cannot decode map key "C" into type int`)
+
+	// no maps with other key types
+	x5 := map[float64]int{}
+	err = Decode([]byte(`{"M":{"1":{"N":"1"}}}`), &x5)
+	c.Assert(err, ErrorMatches, ".*unsupported map key type float64")
 }
 
 func (s *DecoderSuite) TestByteArrayLike(c *ck.C) {
@@ -561,3 +569,414 @@ func (s *DecoderSuite) TestInterface(c *ck.C) {
 	err = Decode([]byte(`{"M":{"A":{"BOOL":true}}}`), &x2)
 	c.Assert(err, ErrorMatches, ".*cannot decode.*non-empty interface.*")
 }
+
+func (s *DecoderSuite) TestSets(c *ck.C) {
+	type x struct {
+		A map[string]struct{}
+		B map[int]bool
+		C []string
+		D []float64
+		E [][]byte
+		F interface{}
+		G interface{}
+	}
+	x1 := x{}
+	err := Decode([]byte(`{"M":{
+		"A":{"SS":["foo","bar"]},
+		"B":{"NS":["1","2"]},
+		"C":{"SS":["baz"]},
+		"D":{"NS":["1.5"]},
+		"E":{"BS":["Zm9v"]},
+		"F":{"SS":["a","b"]},
+		"G":{"NS":["3"]}
+	}}`), &x1)
+	c.Assert(err, IsNil)
+	c.Assert(x1.A, DeepEquals, map[string]struct{}{"foo": {}, "bar": {}})
+	c.Assert(x1.B, DeepEquals, map[int]bool{1: true, 2: true})
+	c.Assert(x1.C, DeepEquals, []string{"baz"})
+	c.Assert(x1.D, DeepEquals, []float64{1.5})
+	c.Assert(x1.E, DeepEquals, [][]byte{[]byte("foo")})
+	c.Assert(x1.F, DeepEquals, []string{"a", "b"})
+	c.Assert(x1.G, DeepEquals, []float64{3})
+
+	// element type mismatch
+	type y struct {
+		A map[int]struct{}
+	}
+	err = Decode([]byte(`{"M":{"A":{"NS":["1.5"]}}}`), &y{})
+	c.Assert(err, ErrorMatches, ".*overflow number.*")
+
+	// round trip
+	type z struct {
+		A map[string]struct{}
+		B []int `json:",set"`
+	}
+	z1 := z{A: map[string]struct{}{"foo": {}}, B: []int{3, 1, 2}}
+	d, err := Encode(&z1)
+	c.Assert(err, IsNil)
+	z2 := z{}
+	c.Assert(Decode(d, &z2), IsNil)
+	c.Assert(z2, DeepEquals, z1)
+}
+
+func (s *DecoderSuite) TestDynamoDBTag(c *ck.C) {
+	type x struct {
+		Renamed string        `json:"api_name" dynamodb:"db_name"`
+		Skipped string        `dynamodb:"-"`
+		Binary  string        `dynamodb:",binary"`
+		Text    AliasedString `dynamodb:",binary"`
+		Legacy  string        `dynamodb:",binary"`
+		Expires time.Time     `dynamodb:",unixtime"`
+		Pointer *time.Time    `dynamodb:",unixtime"`
+	}
+	x1 := x{Skipped: "keep"}
+	err := Decode([]byte(`{"M":{
+		"db_name":{"S":"a"},
+		"api_name":{"S":"ignored"},
+		"Skipped":{"S":"ignored"},
+		"Binary":{"B":"Zm9v"},
+		"Text":{"B":"YmFyLWFsaWFzZWQ="},
+		"Legacy":{"S":"baz"},
+		"Expires":{"N":"1500000000"},
+		"Pointer":{"N":"1600000000"}
+	}}`), &x1)
+	c.Assert(err, IsNil)
+	c.Assert(x1.Renamed, Equals, "a")
+	c.Assert(x1.Skipped, Equals, "keep")
+	c.Assert(x1.Binary, Equals, "foo")
+	c.Assert(x1.Text, Equals, AliasedString("bar-unaliased"))
+	c.Assert(x1.Legacy, Equals, "baz")
+	c.Assert(x1.Expires.Equal(time.Unix(1500000000, 0)), Equals, true)
+	c.Assert(x1.Pointer, NotNil)
+	c.Assert(x1.Pointer.Equal(time.Unix(1600000000, 0)), Equals, true)
+
+	err = Decode([]byte(`{"M":{"Pointer":{"NULL":true}}}`), &x1)
+	c.Assert(err, IsNil)
+	c.Assert(x1.Pointer, IsNil)
+
+	err = Decode([]byte(`{"M":{"Expires":{"N":"1.5"}}}`), &x1)
+	c.Assert(err, ErrorMatches, ".*cannot parse unix time 1.5.*")
+}
+
+func (s *DecoderSuite) TestUnmarshaler(c *ck.C) {
+	type x struct {
+		Price money
+		Ptr   *money
+		List  []money
+	}
+	x1 := x{}
+	err := Decode([]byte(`{"M":{
+		"Price":{"M":{"c":{"N":"150"},"cur":{"S":"USD"}}},
+		"Ptr":{"M":{"c":{"N":"200"},"cur":{"S":"EUR"}}},
+		"List":{"L":[{"M":{"c":{"N":"1"},"cur":{"S":"GBP"}}}]}
+	}}`), &x1)
+	c.Assert(err, IsNil)
+	c.Assert(x1.Price, Equals, money{150, "USD"})
+	c.Assert(x1.Ptr, NotNil)
+	c.Assert(*x1.Ptr, Equals, money{200, "EUR"})
+	c.Assert(x1.List, DeepEquals, []money{{1, "GBP"}})
+
+	err = Decode([]byte(`{"M":{"Ptr":{"NULL":true}}}`), &x1)
+	c.Assert(err, IsNil)
+	c.Assert(x1.Ptr, IsNil)
+
+	err = Decode([]byte(`{"M":{"Price":{"S":"foo"}}}`), &x1)
+	c.Assert(err, ErrorMatches, ".*error decoding custom value type.*money: malformed money value.*")
+}
+
+func (s *DecoderSuite) TestEmbeddedPointer(c *ck.C) {
+	type Inner struct {
+		X int
+	}
+	type Outer struct {
+		*Inner
+		Y int
+	}
+	x := Outer{}
+	err := Decode([]byte(`{"M":{"X":{"N":"1"},"Y":{"N":"2"}}}`), &x)
+	c.Assert(err, IsNil)
+	c.Assert(x.Inner, NotNil)
+	c.Assert(x.X, Equals, 1)
+	c.Assert(x.Y, Equals, 2)
+}
+
+func (s *DecoderSuite) BenchmarkDecode(c *ck.C) {
+	d := []byte(`{"M":{
+		"Int1":{"N":"10"},
+		"String1":{"S":"foo"},
+		"Map1":{"M":{"a":{"M":{"Int1":{"N":"1"}}},"b":{"M":{"Int1":{"N":"2"}}}}},
+		"Slice1":{"L":[{"M":{"Int1":{"N":"3"}}},{"M":{"Int1":{"N":"4"}}}]}
+	}}`)
+	attr, err := DecodeToAttributeValue(d)
+	c.Assert(err, IsNil)
+	for i := 0; i < c.N; i++ {
+		root := Root{}
+		if err := DecodeAttributeValueToInterface(attr, &root); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *DecoderSuite) TestErrorPath(c *ck.C) {
+	type line struct {
+		SKU  int8 `json:"sku"`
+		Note string
+	}
+	type order struct {
+		Lines map[string]line
+	}
+	type root struct {
+		Orders []order
+	}
+	x := root{}
+	err := Decode([]byte(`{"M":{"Orders":{"L":[
+		{"M":{}},
+		{"M":{"Lines":{"M":{"first":{"M":{"sku":{"N":"300"}}}}}}}
+	]}}}`), &x)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: Orders\[1\].Lines.first.sku: overflow number 300 for type int8`)
+
+	var de DecodeError
+	c.Assert(errors.As(err, &de), Equals, true)
+	c.Assert(de.Path, Equals, "Orders[1].Lines.first.sku")
+	c.Assert(de.Struct, Equals, reflect.TypeOf(line{}))
+	c.Assert(de.Field, Equals, "SKU")
+	c.Assert(de.AttributeType, Equals, N)
+	c.Assert(de.IsNumericOverflow, Equals, true)
+
+	// fields promoted from embedded structs report the declaring struct
+	type Embedded struct {
+		Value int
+	}
+	type outer struct {
+		Embedded
+	}
+	err = Decode([]byte(`{"M":{"Value":{"N":"1.5"}}}`), &outer{})
+	c.Assert(errors.As(err, &de), Equals, true)
+	c.Assert(de.Path, Equals, "Value")
+	c.Assert(de.Struct, Equals, reflect.TypeOf(Embedded{}))
+	c.Assert(de.Field, Equals, "Value")
+
+	// root values have no path
+	var n int
+	err = Decode([]byte(`{"N":"1.5"}`), &n)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: overflow number 1.5 for type int`)
+	c.Assert(errors.As(err, &de), Equals, true)
+	c.Assert(de.Path, Equals, "")
+	c.Assert(de.Struct, IsNil)
+}
+
+func (s *DecoderSuite) TestStrictDecoding(c *ck.C) {
+	type inner struct {
+		N int
+	}
+	type x struct {
+		A int
+		B string
+		C []inner
+		D map[string]bool
+		E inner
+	}
+	data := []byte(`{"M":{
+		"A":{"S":"1"},
+		"B":{"BOOL":true},
+		"C":{"L":[{"M":{"N":{"N":"1"}}},{"M":{"N":{"S":"2"},"Extra":{"S":"x"}}}]},
+		"D":{"M":{"k":{"N":"1"}}},
+		"E":{"S":"not a map"},
+		"Unknown":{"N":"1"}
+	}}`)
+
+	// the default decoder is lenient
+	x1 := x{}
+	c.Assert(Decode(data, &x1), IsNil)
+	c.Assert(x1.C, HasLen, 2)
+	c.Assert(x1.C[0].N, Equals, 1)
+
+	x1 = x{}
+	err := NewDecoder(StrictTypes()).Decode(data, &x1)
+	c.Assert(err, NotNil)
+	de, ok := err.(DecodeError)
+	c.Assert(ok, Equals, true)
+	des := de.Errors()
+	c.Assert(des, HasLen, 5)
+	c.Assert(err == error(DecodeError{Message: de.Message}), Equals, false)
+	c.Assert(des[0].Path, Equals, "A")
+	c.Assert(des[0].Message, Equals, "cannot decode S attribute into type int")
+	c.Assert(des[0].AttributeType, Equals, S)
+	c.Assert(des[0].Field, Equals, "A")
+	c.Assert(des[1].Path, Equals, "B")
+	c.Assert(des[2].Path, Equals, "C[1].N")
+	c.Assert(des[2].Struct, Equals, reflect.TypeOf(inner{}))
+	c.Assert(des[2].Field, Equals, "N")
+	c.Assert(des[3].Path, Equals, "D.k")
+	c.Assert(des[4].Path, Equals, "E")
+	c.Assert(err, ErrorMatches, "aws.dynamodb.DecodeError: 5 attributes could not be decoded: A: cannot decode S attribute into type int; .*")
+	// decoding continues past mismatches
+	c.Assert(x1.C[0].N, Equals, 1)
+
+	x1 = x{}
+	err = NewDecoder(DisallowUnknownFields()).Decode(data, &x1)
+	c.Assert(errors.As(err, &de), Equals, true)
+	des = de.Errors()
+	c.Assert(des, HasLen, 2)
+	c.Assert(des[0].Path, Equals, "C[1].Extra")
+	c.Assert(des[0].Message, Matches, "unknown attribute for type .*inner")
+	c.Assert(des[1].Path, Equals, "Unknown")
+	c.Assert(des[1].Struct, Equals, reflect.TypeOf(x{}))
+
+	err = NewDecoder(DisallowUnknownFields(), StrictTypes()).Decode(data, &x1)
+	c.Assert(errors.As(err, &de), Equals, true)
+	c.Assert(de.Errors(), HasLen, 7)
+
+	// hard errors still stop decoding
+	err = NewDecoder(StrictTypes()).Decode([]byte(`{"M":{"A":{"N":"1.5"},"B":{"N":"1"}}}`), &x1)
+	de = DecodeError{}
+	c.Assert(errors.As(err, &de), Equals, true)
+	c.Assert(de.Errors(), IsNil)
+	c.Assert(de.Path, Equals, "A")
+	c.Assert(de.IsNumericOverflow, Equals, true)
+	// DecodeError stays comparable
+	c.Assert(de == DecodeError{Message: de.Message, IsNumericOverflow: true, Path: "A", Struct: reflect.TypeOf(x{}), Field: "A", AttributeType: N}, Equals, true)
+
+	// well formed data passes
+	err = NewDecoder(DisallowUnknownFields(), StrictTypes()).Decode([]byte(`{"M":{"A":{"N":"1"},"B":{"NULL":true},"E":{"M":{"N":{"N":"2"}}}}}`), &x1)
+	c.Assert(err, IsNil)
+}
+
+func (s *DecoderSuite) TestPreserveEmpty(c *ck.C) {
+	type X struct {
+		S    *string
+		B    []byte
+		NilB []byte
+		L    []int
+		NilL []int
+		M    map[string]int
+		NilM map[string]int
+		I    interface{}
+	}
+	empty := ""
+	x := X{S: &empty, B: []byte{}, L: []int{}, M: map[string]int{}, I: []interface{}{}}
+
+	d, err := NewEncoder(PreserveEmpty()).Encode(&x)
+	c.Assert(err, IsNil)
+
+	var out X
+	c.Assert(Decode(d, &out), IsNil)
+	c.Assert(out, DeepEquals, x)
+	c.Assert(out.B, NotNil)
+	c.Assert(out.NilB, IsNil)
+	c.Assert(out.L, NotNil)
+	c.Assert(out.NilL, IsNil)
+	c.Assert(out.M, NotNil)
+	c.Assert(out.NilM, IsNil)
+
+	// without the option empty values collapse into nil
+	d, err = Encode(&x)
+	c.Assert(err, IsNil)
+	out = X{}
+	c.Assert(Decode(d, &out), IsNil)
+	c.Assert(out.S, IsNil)
+	c.Assert(out.L, IsNil)
+	c.Assert(out.I, IsNil)
+}
+
+type keyID struct {
+	Kind string
+	N    int
+}
+
+func (k keyID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s-%d", k.Kind, k.N)), nil
+}
+
+func (k *keyID) UnmarshalText(text []byte) error {
+	i := strings.LastIndexByte(string(text), '-')
+	if i < 0 {
+		return fmt.Errorf("malformed id %q", text)
+	}
+	n, err := strconv.Atoi(string(text[i+1:]))
+	k.Kind, k.N = string(text[:i]), n
+	return err
+}
+
+func (s *DecoderSuite) TestMapKeys(c *ck.C) {
+	type X struct {
+		Ints  map[int]string
+		Uints map[uint8]bool
+		IDs   map[keyID]int
+	}
+	x := X{
+		Ints:  map[int]string{-1: "a", 20: "b"},
+		Uints: map[uint8]bool{255: true},
+		IDs:   map[keyID]int{{"user", 1}: 10, {"team", 2}: 20},
+	}
+
+	attr, err := EncodeToAttributeValue(&x)
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["Ints"].M["-1"].S, Equals, "a")
+	c.Assert(*attr.M["IDs"].M["user-1"].N, Equals, "10")
+
+	var out X
+	c.Assert(DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out, DeepEquals, x)
+
+	_, err = EncodeToAttributeValue(map[float64]int{1: 1})
+	c.Assert(err, ErrorMatches, "aws.dynamodb.EncodeError: unsupported map key type float64")
+
+	var overflow map[int8]int
+	err = Decode([]byte(`{"M":{"300":{"N":"1"}}}`), &overflow)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: 300: cannot decode map key "300" into type int8`)
+
+	var bad map[keyID]int
+	err = Decode([]byte(`{"M":{"x":{"N":"1"}}}`), &bad)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: x: error decoding map key "x" into type dynamodb_test.keyID: malformed id "x"`)
+}
+
+func (s *DecoderSuite) TestStringOption(c *ck.C) {
+	type X struct {
+		ID     int64   `dynamodb:"id,string"`
+		Score  float64 `dynamodb:",string"`
+		Active bool    `dynamodb:",string"`
+		Ptr    *uint   `dynamodb:",string"`
+	}
+	u := uint(7)
+	in := X{ID: 1234567890123, Score: 1.5, Active: true, Ptr: &u}
+	d, err := Encode(&in)
+	c.Assert(err, IsNil)
+
+	var out X
+	c.Assert(Decode(d, &out), IsNil)
+	c.Assert(out, DeepEquals, in)
+
+	// values stored before the option was added still decode
+	out = X{}
+	c.Assert(Decode([]byte(`{"M":{"id":{"N":"12"},"Active":{"BOOL":true},"Ptr":{"NULL":true}}}`), &out), IsNil)
+	c.Assert(out, DeepEquals, X{ID: 12, Active: true})
+
+	err = Decode([]byte(`{"M":{"id":{"S":"12x"}}}`), &out)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: id: cannot parse "12x" into type int64`)
+	err = Decode([]byte(`{"M":{"Active":{"S":"yes"}}}`), &out)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: Active: cannot parse "yes" into type bool`)
+	err = Decode([]byte(`{"M":{"Ptr":{"S":"-1"}}}`), &out)
+	c.Assert(err, ErrorMatches, `aws.dynamodb.DecodeError: Ptr: overflow number -1 for type uint`)
+}
+
+func (s *DecoderSuite) TestAttributeNames(c *ck.C) {
+	type Inner struct {
+		C string
+		D string `dynamodb:"d"`
+	}
+	type X struct {
+		A string `json:"a"`
+		B string `dynamodb:"-"`
+		*Inner
+		E string `ddb:"e"`
+		f string
+	}
+	names := []string{"a", "C", "d", "E"}
+	c.Assert(AttributeNames(X{}), DeepEquals, names)
+	c.Assert(AttributeNames(&X{}), DeepEquals, names)
+	c.Assert(AttributeNames(reflect.TypeOf(X{})), DeepEquals, names)
+	c.Assert(NewDecoder(TagName("ddb")).AttributeNames(X{}), DeepEquals, []string{"a", "B", "C", "D", "e"})
+	c.Assert(AttributeNames(1), IsNil)
+	c.Assert(AttributeNames(nil), IsNil)
+}
@@ -6,266 +6,867 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 )
 
 func Encode(item interface{}) ([]byte, error) {
-	attr, err := EncodeToAttributeValue(item)
+	return defaultEncoder.Encode(item)
+}
+
+func EncodeToAttributeValue(item interface{}) (*AttributeValue, error) {
+	return defaultEncoder.EncodeToAttributeValue(item)
+}
+
+func MustEncodeToAttributeValue(item interface{}) *AttributeValue {
+	if av, err := EncodeToAttributeValue(item); err != nil {
+		panic(err)
+	} else {
+		return av
+	}
+}
+
+// An Encoder encodes Go values into AttributeValues. The package level Encode
+// functions use an Encoder with the default options. An Encoder is safe for
+// concurrent use, and should be reused since it caches the encoders it builds
+// for each type.
+type Encoder struct {
+	config
+	emptyStrings     EmptyPolicy
+	emptyCollections EmptyPolicy
+
+	codecs *codecSet
+}
+
+func NewEncoder(opts ...EncoderOption) *Encoder {
+	enc := &Encoder{}
+	for _, opt := range opts {
+		opt.applyEncoder(enc)
+	}
+	enc.codecs = newCodecSet(enc.config)
+	return enc
+}
+
+var defaultEncoder = NewEncoder()
+
+// Encode returns the JSON encoding of item as an AttributeValue. It writes the
+// JSON directly from item, without building the AttributeValue first.
+func (enc *Encoder) Encode(item interface{}) ([]byte, error) {
+	bp := encodeBufferPool.Get().(*[]byte)
+	defer putEncodeBuffer(bp)
+
+	b, err := enc.appendValue((*bp)[:0], item)
 	if err != nil {
 		return nil, err
 	}
+	*bp = b
+	return append([]byte(nil), b...), nil
+}
 
-	if d, err := json.Marshal(attr); err != nil {
-		return nil, EncodeError{fmt.Sprintf("%s", err.Error())}
-	} else {
-		return d, nil
+// maxPooledBuffer is the largest buffer Encode returns to the pool, so one
+// huge item doesn't stay pinned in memory.
+const maxPooledBuffer = 64 * 1024
+
+var encodeBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+func putEncodeBuffer(bp *[]byte) {
+	if cap(*bp) <= maxPooledBuffer {
+		encodeBufferPool.Put(bp)
 	}
 }
 
-func EncodeToAttributeValue(item interface{}) (*AttributeValue, error) {
+func (enc *Encoder) EncodeToAttributeValue(item interface{}) (*AttributeValue, error) {
 	if av, ok := item.(*AttributeValue); ok {
 		return av, nil
 	}
 
-	attr, err := convertToAttribute(reflect.ValueOf(item))
+	v := reflect.ValueOf(item)
+	if !v.IsValid() {
+		return nullAttribute(), nil
+	}
+
+	e := &encodeState{Encoder: enc}
+	attr, err := enc.codecs.typeEncoder(v.Type())(e, v)
 	if err != nil {
 		return nil, err
 	}
-
+	if attr == nil {
+		// an omitted empty value
+		return nullAttribute(), nil
+	}
 	return attr, nil
 }
 
-func MustEncodeToAttributeValue(item interface{}) *AttributeValue {
-	if av, err := EncodeToAttributeValue(item); err != nil {
-		panic(err)
-	} else {
-		return av
+// encodeState holds the state of a single encode call.
+type encodeState struct {
+	*Encoder
+}
+
+// emptyString returns the encoding of an empty string or binary value, or nil
+// if it should be omitted. preserved is the encoding used by EmptyPreserved.
+func (e *encodeState) emptyString(preserved *AttributeValue) *AttributeValue {
+	return emptyValue(e.emptyStrings, preserved)
+}
+
+// emptyCollection returns the encoding of a nil or empty list, map or set, or
+// nil if it should be omitted. preserved is the encoding used by EmptyPreserved.
+func (e *encodeState) emptyCollection(preserved *AttributeValue) *AttributeValue {
+	return emptyValue(e.emptyCollections, preserved)
+}
+
+func emptyValue(p EmptyPolicy, preserved *AttributeValue) *AttributeValue {
+	switch p {
+	case EmptyOmitted:
+		return nil
+	case EmptyPreserved:
+		return preserved
+	default:
+		return nullAttribute()
 	}
 }
 
+// Marshaler is the interface implemented by types that can encode themselves
+// into an AttributeValue. It takes precedence over json.Marshaler and
+// encoding.TextMarshaler, so types can choose a native M, L or N shape.
+type Marshaler interface {
+	MarshalDynamoDBAttributeValue() (*AttributeValue, error)
+}
+
 type EncodeError struct {
 	Message string
+
+	// Path is the attribute path of the value that failed to encode, such as
+	// Orders[3].Lines.sku, or empty if the root value failed.
+	Path string
+	// Struct and Field identify the innermost struct field being encoded, if any.
+	Struct reflect.Type
+	Field  string
+	// AttributeType is the type of attribute being produced, if known.
+	AttributeType AttributeValueType
 }
 
 func (e EncodeError) Error() string {
+	if e.Path != "" {
+		return fmt.Sprintf("aws.dynamodb.EncodeError: %s: %s", e.Path, e.Message)
+	}
 	return fmt.Sprintf("aws.dynamodb.EncodeError: %s", e.Message)
 }
 
+// encodeErrorAt prepends a path element to err if it is an EncodeError, and
+// records the struct field it occurred in if that isn't already known.
+func encodeErrorAt(err error, elem string, st reflect.Type, fieldName string) error {
+	ee, ok := err.(EncodeError)
+	if !ok {
+		return err
+	}
+	ee.Path = joinPath(elem, ee.Path)
+	if ee.Struct == nil && st != nil {
+		ee.Struct = st
+		ee.Field = fieldName
+	}
+	return ee
+}
+
+// joinPath prepends an attribute name or list index to an attribute path.
+func joinPath(elem, path string) string {
+	if path == "" {
+		return elem
+	}
+	if path[0] == '[' {
+		return elem + path
+	}
+	return elem + "." + path
+}
+
+func indexPath(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
 // private
-func encodeJSONValue(v reflect.Value) (*AttributeValue, error) {
+func nullAttribute() *AttributeValue {
+	b := true
+	return &AttributeValue{NULL: &b}
+}
+
+func isNilValue(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+func encodeMarshalerValue(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if isNilValue(v) {
+		return nullAttribute(), nil
+	}
+	attr, err := v.Interface().(Marshaler).MarshalDynamoDBAttributeValue()
+	if err != nil {
+		if _, ok := err.(EncodeError); ok {
+			return nil, err
+		}
+		return nil, EncodeError{Message: fmt.Sprintf("error encoding custom value type %s: %s", v.Type().String(), err.Error())}
+	}
+	if attr == nil || !attr.IsValid() {
+		return nullAttribute(), nil
+	}
+	return attr, nil
+}
+
+func encodeJSONValue(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if isNilValue(v) {
+		return nullAttribute(), nil
+	}
 	d, err := json.Marshal(v.Interface())
 	if err != nil {
-		return nil, EncodeError{fmt.Sprintf("error encoding json value type: %s", err.Error())}
+		return nil, EncodeError{Message: fmt.Sprintf("error encoding json value type: %s", err.Error())}
 	}
 	if len(d) > 0 {
 		return &AttributeValue{B: d}, nil
 	} else {
-		t := true
-		return &AttributeValue{NULL: &t}, nil
+		return nullAttribute(), nil
 	}
 }
 
-func encodeTextValue(v reflect.Value) (*AttributeValue, error) {
+func encodeTextValue(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if isNilValue(v) {
+		return nullAttribute(), nil
+	}
 	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
 	if err != nil {
-		return nil, EncodeError{fmt.Sprintf("error encoding opaque value type: %s", err.Error())}
+		return nil, EncodeError{Message: fmt.Sprintf("error encoding opaque value type: %s", err.Error())}
 	}
+	b2 := string(b)
 	if len(b) > 0 {
-		b2 := string(b)
 		return &AttributeValue{S: &b2}, nil
 	} else {
-		t := true
-		return &AttributeValue{NULL: &t}, nil
+		return e.emptyString(&AttributeValue{S: &b2}), nil
 	}
 }
 
+var MarshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
 var JSONMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
 var TextMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 
-func convertToAttribute(v reflect.Value) (*AttributeValue, error) {
-	vt := v.Type()
-	if vt.NumMethod() > 0 {
-		if vt.Implements(JSONMarshalerType) {
-			if v.Kind() == reflect.Ptr && v.IsNil() {
-				b := true
-				return &AttributeValue{NULL: &b}, nil
+// An encoderFunc encodes values of a single type. Encoders are built once per
+// type, in the style of encoding/json, so that the reflection needed to pick
+// an encoding for a type isn't repeated for every value. A nil AttributeValue
+// with a nil error means the value should be omitted.
+type encoderFunc func(e *encodeState, v reflect.Value) (*AttributeValue, error)
+
+// typeEncoder returns the cached encoder for a type, building it if needed.
+func (c *codecSet) typeEncoder(t reflect.Type) encoderFunc {
+	if fi, ok := c.encoders.Load(t); ok {
+		return fi.(encoderFunc)
+	}
+
+	// To deal with recursive types, populate the cache with an indirect func
+	// before building the real encoder. It waits on the real func to be ready
+	// and then calls it. The indirect func is only used for recursive types.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := c.encoders.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+		wg.Wait()
+		return f(e, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = c.newTypeEncoder(t, true)
+	wg.Done()
+	c.encoders.Store(t, f)
+	return f
+}
+
+// newTypeEncoder builds an encoder for a type. If allowAddr is true and the
+// type's pointer implements one of the marshaling interfaces, addressable
+// values are encoded through their address.
+func (c *codecSet) newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+	if codec, ok := c.codecs[t]; ok {
+		return newCodecEncoder(codec)
+	}
+	if t == timeType && c.timeFormat != "" {
+		return c.timeEncoder
+	}
+	if isNumberType(t) {
+		return numberEncoder
+	}
+	if t.Kind() == reflect.Ptr && (c.overrides(t.Elem()) || isNumberType(t.Elem())) {
+		// don't let the pointer's marshaling methods bypass the override
+		return c.newPtrEncoder(t)
+	}
+
+	for _, m := range []struct {
+		typ reflect.Type
+		enc encoderFunc
+	}{
+		{MarshalerType, encodeMarshalerValue},
+		{JSONMarshalerType, encodeJSONValue},
+		{TextMarshalerType, encodeTextValue},
+	} {
+		if t.Kind() != reflect.Ptr && allowAddr && reflect.PtrTo(t).Implements(m.typ) {
+			return newCondAddrEncoder(m.enc, c.newTypeEncoder(t, false))
+		}
+		if t.Implements(m.typ) {
+			return m.enc
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return numberEncoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return c.interfaceEncoder
+	case reflect.Struct:
+		return c.newStructEncoder(t)
+	case reflect.Map:
+		return c.newMapEncoder(t)
+	case reflect.Slice:
+		return c.newSliceEncoder(t)
+	case reflect.Array:
+		return c.newArrayEncoder(t)
+	case reflect.Ptr:
+		return c.newPtrEncoder(t)
+	default:
+		return unsupportedTypeEncoder
+	}
+}
+
+// newCondAddrEncoder returns an encoder that uses addrEnc on the address of
+// addressable values, and elseEnc otherwise.
+func newCondAddrEncoder(addrEnc, elseEnc encoderFunc) encoderFunc {
+	return func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+		if v.CanAddr() {
+			return addrEnc(e, v.Addr())
+		}
+		return elseEnc(e, v)
+	}
+}
+
+func newCodecEncoder(codec Codec) encoderFunc {
+	return func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+		attr, err := codec.Encode(v.Interface())
+		if err != nil {
+			if _, ok := err.(EncodeError); ok {
+				return nil, err
 			}
-			return encodeJSONValue(v)
-		} else if vt.Implements(TextMarshalerType) {
-			if v.Kind() == reflect.Ptr && v.IsNil() {
-				b := true
-				return &AttributeValue{NULL: &b}, nil
+			return nil, EncodeError{Message: fmt.Sprintf("error encoding custom value type %s: %s", v.Type().String(), err.Error())}
+		}
+		if attr == nil || !attr.IsValid() {
+			return nullAttribute(), nil
+		}
+		return attr, nil
+	}
+}
+
+func (c *codecSet) timeEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	s := v.Interface().(time.Time).Format(c.timeFormat)
+	return &AttributeValue{S: &s}, nil
+}
+
+func boolEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	b := v.Bool()
+	return &AttributeValue{BOOL: &b}, nil
+}
+
+func numberEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	n, err := convertToNumericString(v)
+	if err != nil {
+		return nil, err
+	}
+	return &AttributeValue{N: &n}, nil
+}
+
+func stringEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	s := v.String()
+	if len(s) == 0 {
+		return e.emptyString(&AttributeValue{S: &s}), nil
+	}
+	return &AttributeValue{S: &s}, nil
+}
+
+func (c *codecSet) interfaceEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if v.IsNil() {
+		return nullAttribute(), nil
+	}
+	// the dynamic type may implement one of the marshaling interfaces
+	ev := v.Elem()
+	return c.typeEncoder(ev.Type())(e, ev)
+}
+
+func unsupportedTypeEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	return nil, EncodeError{Message: fmt.Sprintf("aws.dynamodb.EncodeError: unsupported type for field: %#v", v.Type())}
+}
+
+type structEncoder struct {
+	fields   []field
+	encoders []encoderFunc
+}
+
+func (c *codecSet) newStructEncoder(t reflect.Type) encoderFunc {
+	fields := cachedTypeFields(t, c.tagName)
+	se := structEncoder{fields: fields, encoders: make([]encoderFunc, len(fields))}
+	for i, f := range fields {
+		se.encoders[i] = c.newFieldEncoder(f, typeByIndex(t, f.index))
+	}
+	return se.encode
+}
+
+func (se structEncoder) encode(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	out := make(AttributeValueMap, len(se.fields))
+	for i, f := range se.fields { // loop on each field
+		fv := fieldByIndex(v, f.index)
+		if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		attr, err := se.encoders[i](e, fv)
+		if err != nil {
+			return nil, encodeErrorAt(err, f.name, fieldOwner(v.Type(), f.index), f.goName)
+		}
+
+		if attr != nil {
+			out[f.name] = attr
+		}
+	}
+	return &AttributeValue{M: out}, nil
+}
+
+type mapEncoder struct {
+	keyEnc  func(k reflect.Value) (string, error)
+	elemEnc encoderFunc
+}
+
+func (c *codecSet) newMapEncoder(t reflect.Type) encoderFunc {
+	// map[T]struct{} is the idiomatic Go set
+	if isSetMapType(t) {
+		return encodeSet
+	}
+	keyEnc := newMapKeyEncoder(t.Key())
+	if keyEnc == nil {
+		return func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+			if v.IsNil() {
+				return e.emptyCollection(nullAttribute()), nil
+			}
+			return nil, EncodeError{Message: fmt.Sprintf("unsupported map key type %s", t.Key().String()), AttributeType: M}
+		}
+	}
+	me := mapEncoder{keyEnc, c.typeEncoder(t.Elem())}
+	return me.encode
+}
+
+// newMapKeyEncoder returns a func converting map keys of type t to attribute
+// names, or nil if t isn't supported. As in encoding/json, string keys are used
+// directly, encoding.TextMarshalers are marshaled, and integers are formatted
+// in decimal.
+func newMapKeyEncoder(t reflect.Type) func(k reflect.Value) (string, error) {
+	switch {
+	case t.Kind() == reflect.String:
+		return func(k reflect.Value) (string, error) {
+			return k.String(), nil
+		}
+	case t.Implements(TextMarshalerType):
+		return func(k reflect.Value) (string, error) {
+			if k.Kind() == reflect.Ptr && k.IsNil() {
+				return "", nil
 			}
-			return encodeTextValue(v)
-		}
-	}
-
-	if v.Kind() != reflect.Ptr && v.CanAddr() {
-		addr := v.Addr()
-		if addr.Type().NumMethod() > 0 {
-			if addr.Type().Implements(JSONMarshalerType) {
-				if addr.IsNil() {
-					b := true
-					return &AttributeValue{NULL: &b}, nil
-				}
-				return encodeJSONValue(addr)
-			} else if addr.Type().Implements(TextMarshalerType) {
-				if addr.IsNil() {
-					b := true
-					return &AttributeValue{NULL: &b}, nil
-				}
-				return encodeTextValue(addr)
+			b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return "", EncodeError{Message: fmt.Sprintf("error encoding map key type %s: %s", t.String(), err.Error()), AttributeType: M}
 			}
+			return string(b), nil
 		}
 	}
-	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(k reflect.Value) (string, error) {
+			return strconv.FormatInt(k.Int(), 10), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(k reflect.Value) (string, error) {
+			return strconv.FormatUint(k.Uint(), 10), nil
+		}
+	}
+	return nil
+}
+
+func (me mapEncoder) encode(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if v.IsNil() {
+		return e.emptyCollection(nullAttribute()), nil
+	}
+	if v.Len() == 0 && e.emptyCollections == EmptyOmitted {
+		return nil, nil
+	}
+
+	containerOut := make(AttributeValueMap, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := me.keyEnc(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		v2, err := me.elemEnc(e, iter.Value())
+		if err != nil {
+			return nil, encodeErrorAt(err, key, nil, "")
+		}
+		if v2 != nil {
+			containerOut[key] = v2
+		}
+	}
+	return &AttributeValue{M: containerOut}, nil
+}
+
+func (c *codecSet) newSliceEncoder(t reflect.Type) encoderFunc {
+	// Special-case, byte blob, binary can't be nil...
+	if t.Elem().Kind() == reflect.Uint8 {
+		return bytesEncoder
+	}
+	arrayEnc := c.newArrayEncoder(t)
+	return func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+		// empty lists weren't supported in dynamo, and by default we
+		// don't differentiate nil slices from empty slices
 		if v.IsNil() {
-			b := true
-			return &AttributeValue{NULL: &b}, nil
+			return e.emptyCollection(nullAttribute()), nil
 		}
-		v = v.Elem()
+		if v.Len() == 0 {
+			return e.emptyCollection(&AttributeValue{L: []*AttributeValue{}}), nil
+		}
+		return arrayEnc(e, v)
 	}
+}
 
-	switch v.Kind() {
-	case reflect.Bool:
-		b := v.Bool()
-		return &AttributeValue{BOOL: &b}, nil
+func bytesEncoder(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if v.IsNil() {
+		return e.emptyString(nullAttribute()), nil
+	}
+	if v.Len() == 0 {
+		return e.emptyString(&AttributeValue{B: []byte{}}), nil
+	}
+	return &AttributeValue{B: v.Bytes()}, nil
+}
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
-		n := convertToNumericString(v)
-		return &AttributeValue{N: &n}, nil
+type arrayEncoder struct {
+	elemEnc encoderFunc
+}
 
-	case reflect.String:
-		s := v.String()
-		if len(s) == 0 {
-			b := true
-			return &AttributeValue{NULL: &b}, nil
+func (c *codecSet) newArrayEncoder(t reflect.Type) encoderFunc {
+	ae := arrayEncoder{c.typeEncoder(t.Elem())}
+	return ae.encode
+}
+
+func (ae arrayEncoder) encode(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	arrayLength := v.Len()
+	containerOut := make([]*AttributeValue, arrayLength)
+	for i := 0; i < arrayLength; i++ {
+		v2, err := ae.elemEnc(e, v.Index(i))
+		if err != nil {
+			return nil, encodeErrorAt(err, indexPath(i), nil, "")
+		}
+		if v2 != nil {
+			containerOut[i] = v2
 		} else {
-			return &AttributeValue{S: &s}, nil
+			containerOut[i] = nullAttribute()
 		}
+	}
+	return &AttributeValue{L: containerOut}, nil
+}
 
-	case reflect.Struct:
+type ptrEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (c *codecSet) newPtrEncoder(t reflect.Type) encoderFunc {
+	pe := ptrEncoder{c.typeEncoder(t.Elem())}
+	return pe.encode
+}
+
+func (pe ptrEncoder) encode(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if v.IsNil() {
+		return nullAttribute(), nil
+	}
+	return pe.elemEnc(e, v.Elem())
+}
+
+// newFieldEncoder builds the encoder for a struct field of type t, applying
+// any dynamodb tag options.
+func (c *codecSet) newFieldEncoder(f field, t reflect.Type) encoderFunc {
+	var enc encoderFunc
+	switch {
+	case f.set:
+		enc = encodeSet
+	case f.unixTime:
+		enc = encodeUnixTime
+	case f.binary:
+		enc = encodeBinary
+	case f.quoted && isQuotable(t):
+		enc = encodeQuoted
+	default:
+		enc = c.typeEncoder(t)
+	}
+
+	if f.nullEmpty {
+		return func(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+			if isEmptyValue(v) {
+				return nullAttribute(), nil
+			}
+			return enc(e, v)
+		}
+	}
+	return enc
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isQuotable reports whether the string tag option applies to a field of type
+// t, which like encoding/json is limited to booleans and numbers.
+func isQuotable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// encodeQuoted encodes a boolean or number as an S attribute, for fields with
+// the string tag option.
+func encodeQuoted(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nullAttribute(), nil
+		}
+		v = v.Elem()
+	}
+
+	var s string
+	if v.Kind() == reflect.Bool {
+		s = strconv.FormatBool(v.Bool())
+	} else {
 		var err error
-		var out AttributeValueMap
-		if out, err = encodeStruct(v); err != nil {
+		if s, err = convertToNumericString(v); err != nil {
 			return nil, err
 		}
-		return &AttributeValue{M: out}, nil
+	}
+	return &AttributeValue{S: &s}, nil
+}
 
-	case reflect.Map:
+// encodeUnixTime encodes a time.Time as a number of seconds since the epoch,
+// the format dynamo requires for TTL attributes.
+func encodeUnixTime(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 		if v.IsNil() {
-			b := true
-			return &AttributeValue{NULL: &b}, nil
+			return nullAttribute(), nil
 		}
+		v = v.Elem()
+	}
+	if v.Type() != timeType {
+		return nil, EncodeError{Message: fmt.Sprintf("unixtime option requires a time.Time, not %s", v.Type().String()), AttributeType: N}
+	}
+	n := strconv.FormatInt(v.Interface().(time.Time).Unix(), 10)
+	return &AttributeValue{N: &n}, nil
+}
 
-		if v.Type().Key().Kind() != reflect.String {
-			return nil, EncodeError{fmt.Sprintf("only maps with string keys are supported")}
+// encodeBinary encodes strings, byte slices and encoding.TextMarshalers as B.
+func encodeBinary(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nullAttribute(), nil
+		}
+		if v.Type().Implements(TextMarshalerType) {
+			break
 		}
+		v = v.Elem()
+	}
 
-		containerOut := AttributeValueMap{}
-		for _, key := range v.MapKeys() {
-			v2, err := convertToAttribute(v.MapIndex(key))
-			if err != nil {
-				return nil, err
-			}
-			if v2 != nil {
-				containerOut[key.String()] = v2
-			} else {
-				b := true
-				containerOut[key.String()] = &AttributeValue{NULL: &b}
-			}
+	var d []byte
+	switch {
+	case v.Type().Implements(TextMarshalerType):
+		var err error
+		if d, err = v.Interface().(encoding.TextMarshaler).MarshalText(); err != nil {
+			return nil, EncodeError{Message: fmt.Sprintf("error encoding opaque value type: %s", err.Error())}
 		}
-		return &AttributeValue{M: containerOut}, nil
+	case v.Kind() == reflect.String:
+		d = []byte(v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		d = v.Bytes()
+	default:
+		return nil, EncodeError{Message: fmt.Sprintf("binary option requires a string, []byte or encoding.TextMarshaler, not %s", v.Type().String()), AttributeType: B}
+	}
 
+	if d == nil {
+		return e.emptyString(nullAttribute()), nil
+	}
+	if len(d) == 0 {
+		return e.emptyString(&AttributeValue{B: []byte{}}), nil
+	}
+	return &AttributeValue{B: d}, nil
+}
+
+// setElementType returns the set type whose elements can hold values of type t,
+// or INVALID_ATTRIBUTEVALUE_TYPE if t can't be a set element.
+func setElementType(t reflect.Type) AttributeValueType {
+	if isNumberType(t) {
+		return NS
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return SS
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return NS
 	case reflect.Slice:
-		// empty lists are not supported in dynamo, kinda sucks we can't
-		// differentiate nil slices from empty slices...
-		if v.IsNil() || v.Len() == 0 {
-			b := true
-			return &AttributeValue{NULL: &b}, nil
-		}
-
-		// Special-case, byte blob, binary can't be nil...
-		if v.Type().Elem().Kind() == reflect.Uint8 {
-			if v.Len() == 0 {
-				b := true
-				return &AttributeValue{NULL: &b}, nil
-			} else {
-				return &AttributeValue{B: v.Bytes()}, nil
-			}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return BS
 		}
+	}
+	return INVALID_ATTRIBUTEVALUE_TYPE
+}
 
-		fallthrough
+func isSetMapType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0 &&
+		setElementType(t.Key()) != INVALID_ATTRIBUTEVALUE_TYPE
+}
 
-	case reflect.Array:
-		arrayLength := v.Len()
-		containerOut := make([]*AttributeValue, arrayLength)
-		for i := 0; i < arrayLength; i++ {
-			v2, err := convertToAttribute(v.Index(i))
-			if err != nil {
-				return nil, err
-			}
-			if v2 != nil {
-				containerOut[i] = v2
-			} else {
-				b := true
-				containerOut[i] = &AttributeValue{NULL: &b}
-			}
+// encodeSet encodes a map used as a set, or a slice or array of strings, numbers
+// or byte slices, as one of the dynamodb set types. Dynamo doesn't allow empty
+// sets or duplicate elements, so empty sets are encoded like other empty
+// collections, and duplicates are dropped.
+func encodeSet(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nullAttribute(), nil
 		}
-		return &AttributeValue{L: containerOut}, nil
-
-	case reflect.Ptr, reflect.Interface:
-		// should have had indirection taken care of above
-		panic(EncodeError{fmt.Sprintf("could not handle multiple layers of pointers")})
+		v = v.Elem()
+	}
 
+	var elems []reflect.Value
+	var elemType reflect.Type
+	sorted := false
+	switch v.Kind() {
+	case reflect.Map:
+		elemType = v.Type().Key()
+		elems = v.MapKeys()
+		// map iteration order is random, sort for stable output
+		sorted = true
+	case reflect.Slice, reflect.Array:
+		elemType = v.Type().Elem()
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, v.Index(i))
+		}
 	default:
-		return nil, EncodeError{fmt.Sprintf("aws.dynamodb.EncodeError: unsupported type for field: %#v", v.Type())}
+		return nil, EncodeError{Message: fmt.Sprintf("cannot encode type %s as a set", v.Type().String())}
 	}
-}
 
-func encodeStruct(v reflect.Value) (AttributeValueMap, error) {
-	out := AttributeValueMap{}
-	for _, f := range cachedTypeFields(v.Type()) { // loop on each field
-		fv := fieldByIndex(v, f.index)
-		if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
-			continue
-		}
+	setType := setElementType(elemType)
+	if setType == INVALID_ATTRIBUTEVALUE_TYPE {
+		return nil, EncodeError{Message: fmt.Sprintf("unsupported set element type %s", elemType.String())}
+	}
 
-		attr, err := convertToAttribute(fv)
-		if err != nil {
-			return nil, err
+	if len(elems) == 0 {
+		// dynamo doesn't allow empty sets, even when empty values are preserved
+		return e.emptyCollection(nullAttribute()), nil
+	}
+
+	strs := make([]string, 0, len(elems))
+	seen := make(map[string]bool, len(elems))
+	for _, elem := range elems {
+		var s string
+		switch setType {
+		case SS:
+			s = elem.String()
+		case NS:
+			var err error
+			if s, err = convertToNumericString(elem); err != nil {
+				return nil, err
+			}
+		case BS:
+			s = string(elem.Bytes())
 		}
+		if !seen[s] {
+			seen[s] = true
+			strs = append(strs, s)
+		}
+	}
+	if sorted {
+		sort.Strings(strs)
+	}
 
-		if attr != nil {
-			out[f.name] = attr
+	switch setType {
+	case SS:
+		out := make([]*string, len(strs))
+		for i := range strs {
+			out[i] = &strs[i]
 		}
+		return &AttributeValue{SS: out}, nil
+	case NS:
+		out := make([]*string, len(strs))
+		for i := range strs {
+			out[i] = &strs[i]
+		}
+		return &AttributeValue{NS: out}, nil
+	default:
+		out := make([][]byte, len(strs))
+		for i := range strs {
+			out[i] = []byte(strs[i])
+		}
+		return &AttributeValue{BS: out}, nil
 	}
-	return out, nil
 }
 
-func convertToNumericString(v reflect.Value) string {
+func convertToNumericString(v reflect.Value) (string, error) {
+	var s string
 	switch v.Kind() {
 	case reflect.Bool:
 		x := v.Bool()
 		if x {
-			return "1"
+			return "1", nil
 		} else {
-			return "0"
+			return "0", nil
 		}
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(v.Int(), 10)
+		return strconv.FormatInt(v.Int(), 10), nil
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return strconv.FormatUint(v.Uint(), 10)
+		return strconv.FormatUint(v.Uint(), 10), nil
 
 	case reflect.Float32, reflect.Float64:
 		f := v.Float()
 		if math.IsInf(f, 0) || math.IsNaN(f) {
-			panic(fmt.Errorf("aws.dynamodb.convertToNumericString: NaN and infinite floats not supported"))
+			return "", EncodeError{Message: fmt.Sprintf("NaN and infinite floats not supported"), AttributeType: N}
+		}
+		s = strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+
+	case reflect.String:
+		// Number, which like json.Number encodes an empty string as 0
+		if s = v.String(); s == "" {
+			s = "0"
 		}
-		return strconv.FormatFloat(f, 'g', -1, v.Type().Bits())
+
+	case reflect.Struct:
+		var err error
+		if s, err = formatBigNumber(v); err != nil {
+			return "", EncodeError{Message: err.Error(), AttributeType: N}
+		}
+
 	default:
 		panic(fmt.Errorf("aws.dynamodb.convertToNumericString: unsupported type %#v", v.Type()))
 	}
+
+	if err := checkNumber(s); err != nil {
+		return "", EncodeError{Message: err.Error(), AttributeType: N}
+	}
+	return s, nil
 }
@@ -5,8 +5,11 @@ import (
 	"backflip/tools/testutils"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -375,3 +378,355 @@ func (s *EncoderSuite) TestEncodeValue(c *ck.C) {
 
 	ch.Run()
 }
+
+func (s *EncoderSuite) TestSets(c *ck.C) {
+	type X struct {
+		Strings  map[string]struct{}
+		Numbers  map[int]struct{}
+		Tagged   []string  `json:",set"`
+		Floats   []float64 `json:",set"`
+		Binary   [][]byte  `json:",set"`
+		NotSet   []string
+		EmptySet []string `json:",set"`
+	}
+	x := X{
+		Strings: map[string]struct{}{"b": {}, "a": {}},
+		Numbers: map[int]struct{}{10: {}, 2: {}},
+		Tagged:  []string{"z", "y", "z"},
+		Floats:  []float64{1.5, 2},
+		Binary:  [][]byte{[]byte("foo")},
+		NotSet:  []string{"a"},
+	}
+	d, err := Encode(&x)
+	c.Assert(err, IsNil)
+	result := decodeJSON(c, d)
+
+	c.Assert(value(c, "Strings", result, 7), DeepEquals, rmap("SS", []interface{}{"a", "b"}))
+	c.Assert(value(c, "Numbers", result, 7), DeepEquals, rmap("NS", []interface{}{"10", "2"}))
+	c.Assert(value(c, "Tagged", result, 7), DeepEquals, rmap("SS", []interface{}{"z", "y"}))
+	c.Assert(value(c, "Floats", result, 7), DeepEquals, rmap("NS", []interface{}{"1.5", "2"}))
+	c.Assert(value(c, "Binary", result, 7), DeepEquals, rmap("BS", []interface{}{base64.StdEncoding.EncodeToString([]byte("foo"))}))
+	c.Assert(value(c, "NotSet", result, 7), DeepEquals, rmap("L", []interface{}{rmap("S", "a")}))
+	c.Assert(value(c, "EmptySet", result, 7), DeepEquals, rmap("NULL", true))
+
+	type Y struct {
+		Bad []bool `json:",set"`
+	}
+	_, err = Encode(&Y{[]bool{true}})
+	c.Assert(err, ErrorMatches, ".*unsupported set element type bool.*")
+}
+
+func (s *EncoderSuite) TestDynamoDBTag(c *ck.C) {
+	type X struct {
+		Renamed   string        `json:"api_name" dynamodb:"db_name"`
+		JSONOnly  string        `json:"json_name"`
+		Skipped   string        `json:"skipped" dynamodb:"-"`
+		Omitted   int           `dynamodb:",omitempty"`
+		NotOmit   int           `json:",omitempty" dynamodb:"not_omit"`
+		NullEmpty int           `dynamodb:",nullempty"`
+		Binary    string        `dynamodb:",binary"`
+		Text      AliasedString `dynamodb:",binary"`
+		Expires   time.Time     `dynamodb:",unixtime"`
+		NoExpiry  *time.Time    `dynamodb:",unixtime"`
+		Set       []string      `dynamodb:",set"`
+	}
+	x := X{
+		Renamed:  "a",
+		JSONOnly: "b",
+		Skipped:  "c",
+		Binary:   "foo",
+		Text:     "bar",
+		Expires:  time.Unix(1500000000, 0),
+		Set:      []string{"x"},
+	}
+	d, err := Encode(&x)
+	c.Assert(err, IsNil)
+	result := decodeJSON(c, d)
+
+	c.Assert(value(c, "db_name", result, 9), DeepEquals, rmap("S", "a"))
+	c.Assert(value(c, "json_name", result, 9), DeepEquals, rmap("S", "b"))
+	c.Assert(value(c, "not_omit", result, 9), DeepEquals, rmap("N", "0"))
+	c.Assert(value(c, "NullEmpty", result, 9), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "Binary", result, 9), DeepEquals, rmap("B", base64.StdEncoding.EncodeToString([]byte("foo"))))
+	c.Assert(value(c, "Text", result, 9), DeepEquals, rmap("B", base64.StdEncoding.EncodeToString([]byte("bar-aliased"))))
+	c.Assert(value(c, "Expires", result, 9), DeepEquals, rmap("N", "1500000000"))
+	c.Assert(value(c, "NoExpiry", result, 9), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "Set", result, 9), DeepEquals, rmap("SS", []interface{}{"x"}))
+
+	type Y struct {
+		Bad int `dynamodb:",unixtime"`
+	}
+	_, err = Encode(&Y{})
+	c.Assert(err, ErrorMatches, ".*unixtime option requires a time.Time.*")
+}
+
+type money struct {
+	Cents    int64
+	Currency string
+}
+
+func (m money) MarshalDynamoDBAttributeValue() (*AttributeValue, error) {
+	if m.Currency == "" {
+		return nil, fmt.Errorf("missing currency")
+	}
+	n := strconv.FormatInt(m.Cents, 10)
+	cur := m.Currency
+	return &AttributeValue{M: AttributeValueMap{
+		"c":   &AttributeValue{N: &n},
+		"cur": &AttributeValue{S: &cur},
+	}}, nil
+}
+
+func (m *money) UnmarshalDynamoDBAttributeValue(attr *AttributeValue) error {
+	if attr.M == nil || attr.M["c"] == nil || attr.M["cur"] == nil {
+		return fmt.Errorf("malformed money value")
+	}
+	n, err := strconv.ParseInt(*attr.M["c"].N, 10, 64)
+	if err != nil {
+		return err
+	}
+	m.Cents = n
+	m.Currency = *attr.M["cur"].S
+	return nil
+}
+
+// counter only implements Marshaler on its pointer, and takes precedence over
+// its json.Marshaler implementation.
+type counter struct {
+	n int
+}
+
+func (c *counter) MarshalDynamoDBAttributeValue() (*AttributeValue, error) {
+	n := strconv.Itoa(c.n)
+	return &AttributeValue{N: &n}, nil
+}
+
+func (c *counter) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(c.n)), nil
+}
+
+func (s *EncoderSuite) TestMarshaler(c *ck.C) {
+	type X struct {
+		Price   money
+		Ptr     *money
+		Nil     *money
+		Count   counter
+		Generic interface{}
+	}
+	x := X{
+		Price:   money{150, "USD"},
+		Ptr:     &money{200, "EUR"},
+		Count:   counter{7},
+		Generic: money{1, "GBP"},
+	}
+	d, err := Encode(&x)
+	c.Assert(err, IsNil)
+	result := decodeJSON(c, d)
+
+	c.Assert(value(c, "Price", result, 5), DeepEquals, rmap("M", map[string]interface{}{
+		"c":   rmap("N", "150"),
+		"cur": rmap("S", "USD"),
+	}))
+	c.Assert(value(c, "Ptr", result, 5), DeepEquals, rmap("M", map[string]interface{}{
+		"c":   rmap("N", "200"),
+		"cur": rmap("S", "EUR"),
+	}))
+	c.Assert(value(c, "Nil", result, 5), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "Count", result, 5), DeepEquals, rmap("N", "7"))
+	c.Assert(value(c, "Generic", result, 5), DeepEquals, rmap("M", map[string]interface{}{
+		"c":   rmap("N", "1"),
+		"cur": rmap("S", "GBP"),
+	}))
+
+	_, err = Encode(&X{})
+	c.Assert(err, ErrorMatches, ".*error encoding custom value type.*money: missing currency.*")
+}
+
+type tree struct {
+	Name     string
+	Children []*tree `json:",omitempty"`
+}
+
+func (s *EncoderSuite) TestRecursiveType(c *ck.C) {
+	t := &tree{Name: "root", Children: []*tree{{Name: "a"}, {Name: "b", Children: []*tree{{Name: "c"}}}}}
+	d, err := Encode(t)
+	c.Assert(err, IsNil)
+	c.Assert(string(d), Equals, `{"M":{"Children":{"L":[`+
+		`{"M":{"Name":{"S":"a"}}},`+
+		`{"M":{"Children":{"L":[{"M":{"Name":{"S":"c"}}}]},"Name":{"S":"b"}}}`+
+		`]},"Name":{"S":"root"}}}`)
+
+	t2 := &tree{}
+	c.Assert(Decode(d, t2), IsNil)
+	c.Assert(t2, DeepEquals, t)
+}
+
+func (s *EncoderSuite) TestConcurrentEncode(c *ck.C) {
+	type X struct {
+		A int
+		B []string
+		C map[string]Foo
+	}
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			_, err := Encode(&X{A: i, B: []string{"b"}, C: map[string]Foo{"c": {i}}})
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		c.Assert(<-errs, IsNil)
+	}
+}
+
+var benchRoot = Root{
+	Int1:    10,
+	String1: "foo",
+	Map1:    map[string]Foo{"a": {1}, "b": {2}},
+	Slice1:  []Foo{{3}, {4}, {5}},
+}
+
+func (s *EncoderSuite) BenchmarkEncode(c *ck.C) {
+	for i := 0; i < c.N; i++ {
+		if _, err := Encode(&benchRoot); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncodeAttributeValue encodes through an AttributeValue, as Encode
+// used to, for comparison.
+func (s *EncoderSuite) BenchmarkEncodeAttributeValue(c *ck.C) {
+	for i := 0; i < c.N; i++ {
+		attr, err := EncodeToAttributeValue(&benchRoot)
+		if err != nil {
+			c.Fatal(err)
+		}
+		if _, err := json.Marshal(attr); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *EncoderSuite) TestEncodeMatchesAttributeValue(c *ck.C) {
+	for _, enc := range []*Encoder{
+		NewEncoder(),
+		NewEncoder(PreserveEmpty()),
+		NewEncoder(EmptyStrings(EmptyOmitted), EmptyCollections(EmptyOmitted)),
+		NewEncoder(TimeFormat(time.RFC3339), WithCodec(reflect.TypeOf(money{}), Codec{
+			Encode: func(v interface{}) (*AttributeValue, error) {
+				m := v.(money)
+				s := fmt.Sprintf("%d %s", m.Cents, m.Currency)
+				return &AttributeValue{S: &s}, nil
+			},
+		})),
+	} {
+		for _, v := range append(streamValues(), &benchRoot, map[string]interface{}{"when": time.Unix(0, 0).UTC()}) {
+			attr, err := enc.EncodeToAttributeValue(v)
+			c.Assert(err, IsNil)
+			want, err := json.Marshal(attr)
+			c.Assert(err, IsNil)
+
+			d, err := enc.Encode(v)
+			c.Assert(err, IsNil)
+			c.Assert(string(d), Equals, string(want))
+		}
+	}
+
+	_, err := Encode(map[string]float64{"x": math.Inf(1)})
+	c.Assert(err, ErrorMatches, ".*x.*NaN and infinite floats not supported.*")
+	_, err = Encode([]float64{1e200})
+	c.Assert(err, ErrorMatches, `.*\[0\].*`)
+	_, err = EncodeToAttributeValue([]float64{1e200})
+	c.Assert(err, ErrorMatches, `.*\[0\].*`)
+}
+
+func (s *EncoderSuite) TestErrorPath(c *ck.C) {
+	type line struct {
+		Price money `dynamodb:"price"`
+	}
+	type root struct {
+		Lines []map[string]line
+	}
+	_, err := Encode(&root{Lines: []map[string]line{{"a": {money{1, "USD"}}}, {"b": {}}}})
+	c.Assert(err, ErrorMatches, `aws.dynamodb.EncodeError: Lines\[1\].b.price: error encoding custom value type .*`)
+
+	var ee EncodeError
+	c.Assert(errors.As(err, &ee), Equals, true)
+	c.Assert(ee.Path, Equals, "Lines[1].b.price")
+	c.Assert(ee.Struct, Equals, reflect.TypeOf(line{}))
+	c.Assert(ee.Field, Equals, "Price")
+
+	type sets struct {
+		Bad []bool `json:",set"`
+	}
+	_, err = Encode(&sets{[]bool{true}})
+	c.Assert(errors.As(err, &ee), Equals, true)
+	c.Assert(ee.Path, Equals, "Bad")
+	c.Assert(ee.AttributeType, Equals, INVALID_ATTRIBUTEVALUE_TYPE)
+}
+
+func (s *EncoderSuite) TestPreserveEmpty(c *ck.C) {
+	type X struct {
+		S    string
+		B    []byte
+		NilB []byte
+		L    []int
+		NilL []int
+		M    map[string]int
+		NilM map[string]int
+		Bin  string   `dynamodb:",binary"`
+		Set  []string `dynamodb:",set"`
+		Null string   `dynamodb:",nullempty"`
+	}
+	x := X{B: []byte{}, L: []int{}, M: map[string]int{}, Set: []string{}}
+
+	d, err := NewEncoder(PreserveEmpty()).Encode(&x)
+	c.Assert(err, IsNil)
+	result := decodeJSON(c, d)
+	c.Assert(value(c, "S", result, 10), DeepEquals, rmap("S", ""))
+	c.Assert(value(c, "B", result, 10), DeepEquals, rmap("B", ""))
+	c.Assert(value(c, "NilB", result, 10), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "L", result, 10), DeepEquals, rmap("L", []interface{}{}))
+	c.Assert(value(c, "NilL", result, 10), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "M", result, 10), DeepEquals, rmap("M", map[string]interface{}{}))
+	c.Assert(value(c, "NilM", result, 10), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "Bin", result, 10), DeepEquals, rmap("B", ""))
+	c.Assert(value(c, "Set", result, 10), DeepEquals, rmap("NULL", true))
+	c.Assert(value(c, "Null", result, 10), DeepEquals, rmap("NULL", true))
+
+	// the policies can be set separately
+	attr, err := NewEncoder(EmptyStrings(EmptyPreserved)).EncodeToAttributeValue(&x)
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["S"].S, Equals, "")
+	c.Assert(attr.M["L"].NULL, NotNil)
+
+	attr, err = NewEncoder(EmptyCollections(EmptyPreserved)).EncodeToAttributeValue(&x)
+	c.Assert(err, IsNil)
+	c.Assert(attr.M["S"].NULL, NotNil)
+	c.Assert(attr.M["L"].L, HasLen, 0)
+}
+
+func (s *EncoderSuite) TestStringOption(c *ck.C) {
+	type X struct {
+		ID     int64    `dynamodb:"id,string"`
+		Score  float64  `dynamodb:",string"`
+		Active bool     `dynamodb:",string"`
+		Ptr    *uint    `dynamodb:",string"`
+		Nil    *int     `dynamodb:",string"`
+		Name   string   `dynamodb:",string"`
+		Tags   []string `dynamodb:",string"`
+	}
+	u := uint(7)
+	d, err := Encode(&X{ID: 1234567890123, Score: 1.5, Active: true, Ptr: &u, Name: "a", Tags: []string{"t"}})
+	c.Assert(err, IsNil)
+	result := decodeJSON(c, d)
+	c.Assert(value(c, "id", result, 7), DeepEquals, rmap("S", "1234567890123"))
+	c.Assert(value(c, "Score", result, 7), DeepEquals, rmap("S", "1.5"))
+	c.Assert(value(c, "Active", result, 7), DeepEquals, rmap("S", "true"))
+	c.Assert(value(c, "Ptr", result, 7), DeepEquals, rmap("S", "7"))
+	c.Assert(value(c, "Nil", result, 7), DeepEquals, rmap("NULL", true))
+	// the option is ignored for other types
+	c.Assert(value(c, "Name", result, 7), DeepEquals, rmap("S", "a"))
+	c.Assert(value(c, "Tags", result, 7), DeepEquals, rmap("L", []interface{}{rmap("S", "t")}))
+}
@@ -0,0 +1,147 @@
+package expression
+
+import (
+	"backflip/aws/dynamodb"
+	"fmt"
+	"strings"
+)
+
+// A Condition is a boolean expression over attributes, used as a
+// ConditionExpression or FilterExpression. The zero Condition is invalid.
+type Condition struct {
+	build func(st *buildState) (string, error)
+}
+
+// IsSet reports whether c was returned by one of the condition functions.
+func (c Condition) IsSet() bool {
+	return c.build != nil
+}
+
+func buildCondition(c Condition, st *buildState) (string, error) {
+	if c.build == nil {
+		return "", fmt.Errorf("aws.dynamodb.expression: condition is not set")
+	}
+	return c.build(st)
+}
+
+// format renders a condition from a format string and operands, each of which
+// replaces a %s verb.
+func format(f string, operands ...Operand) Condition {
+	return Condition{func(st *buildState) (string, error) {
+		return buildOperands(st, f, operands...)
+	}}
+}
+
+func Equal(left, right Operand) Condition {
+	return format("%s = %s", left, right)
+}
+
+func NotEqual(left, right Operand) Condition {
+	return format("%s <> %s", left, right)
+}
+
+func LessThan(left, right Operand) Condition {
+	return format("%s < %s", left, right)
+}
+
+func LessThanEqual(left, right Operand) Condition {
+	return format("%s <= %s", left, right)
+}
+
+func GreaterThan(left, right Operand) Condition {
+	return format("%s > %s", left, right)
+}
+
+func GreaterThanEqual(left, right Operand) Condition {
+	return format("%s >= %s", left, right)
+}
+
+// Between is true if lower <= operand <= upper.
+func Between(operand, lower, upper Operand) Condition {
+	return format("%s BETWEEN %s AND %s", operand, lower, upper)
+}
+
+// In is true if operand equals any of the candidates. Dynamo allows between 1
+// and 100 candidates.
+func In(operand Operand, candidates ...Operand) Condition {
+	return Condition{func(st *buildState) (string, error) {
+		if len(candidates) == 0 || len(candidates) > 100 {
+			return "", fmt.Errorf("aws.dynamodb.expression: IN requires 1 to 100 candidates, not %d", len(candidates))
+		}
+		o, err := operand.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		cs := make([]string, len(candidates))
+		for i, c := range candidates {
+			if cs[i], err = c.buildOperand(st); err != nil {
+				return "", err
+			}
+		}
+		return o + " IN (" + strings.Join(cs, ", ") + ")", nil
+	}}
+}
+
+func BeginsWith(name NameOperand, prefix string) Condition {
+	return format("begins_with(%s, %s)", name, Value(prefix))
+}
+
+// Contains is true if the string attribute contains a substring, or the set or
+// list attribute contains an element.
+func Contains(name NameOperand, operand Operand) Condition {
+	return format("contains(%s, %s)", name, operand)
+}
+
+func AttributeExists(name NameOperand) Condition {
+	return format("attribute_exists(%s)", name)
+}
+
+func AttributeNotExists(name NameOperand) Condition {
+	return format("attribute_not_exists(%s)", name)
+}
+
+func AttributeType(name NameOperand, t dynamodb.AttributeValueType) Condition {
+	return format("attribute_type(%s, %s)", name, Value(t.String()))
+}
+
+// And is true if all of the conditions are.
+func And(conds ...Condition) Condition {
+	return join("AND", conds)
+}
+
+// Or is true if any of the conditions are.
+func Or(conds ...Condition) Condition {
+	return join("OR", conds)
+}
+
+func Not(cond Condition) Condition {
+	return Condition{func(st *buildState) (string, error) {
+		s, err := buildCondition(cond, st)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + s + ")", nil
+	}}
+}
+
+// join combines conditions with a logical operator, parenthesizing each so
+// that precedence doesn't depend on how they were built.
+func join(op string, conds []Condition) Condition {
+	return Condition{func(st *buildState) (string, error) {
+		if len(conds) == 0 {
+			return "", fmt.Errorf("aws.dynamodb.expression: %s requires at least one condition", op)
+		}
+		if len(conds) == 1 {
+			return buildCondition(conds[0], st)
+		}
+		parts := make([]string, len(conds))
+		for i, c := range conds {
+			s, err := buildCondition(c, st)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = "(" + s + ")"
+		}
+		return strings.Join(parts, " "+op+" "), nil
+	}}
+}
@@ -0,0 +1,67 @@
+package expression_test
+
+import (
+	"backflip/aws/dynamodb"
+	. "backflip/aws/dynamodb/expression"
+
+	ck "gopkg.in/check.v1"
+)
+
+func condition(c *ck.C, cond Condition) string {
+	expr, err := NewBuilder().WithCondition(cond).Build()
+	c.Assert(err, IsNil)
+	return expr.ConditionExpression
+}
+
+func (s *ExpressionSuite) TestComparisons(c *ck.C) {
+	c.Assert(condition(c, Equal(Name("a"), Value(1))), Equals, "#n0 = :v0")
+	c.Assert(condition(c, NotEqual(Name("a"), Name("b"))), Equals, "#n0 <> #n1")
+	c.Assert(condition(c, LessThan(Name("a"), Value(1))), Equals, "#n0 < :v0")
+	c.Assert(condition(c, LessThanEqual(Name("a"), Value(1))), Equals, "#n0 <= :v0")
+	c.Assert(condition(c, GreaterThan(Size(Name("a")), Value(1))), Equals, "size(#n0) > :v0")
+	c.Assert(condition(c, GreaterThanEqual(Name("a"), Value(1))), Equals, "#n0 >= :v0")
+	c.Assert(condition(c, Between(Name("a"), Value(1), Value(2))), Equals, "#n0 BETWEEN :v0 AND :v1")
+	c.Assert(condition(c, In(Name("a"), Value(1), Value(2), Name("b"))), Equals, "#n0 IN (:v0, :v1, #n1)")
+
+	_, err := NewBuilder().WithCondition(In(Name("a"))).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: IN requires 1 to 100 candidates, not 0")
+}
+
+func (s *ExpressionSuite) TestFunctions(c *ck.C) {
+	c.Assert(condition(c, BeginsWith(Name("a"), "pre")), Equals, "begins_with(#n0, :v0)")
+	c.Assert(condition(c, Contains(Name("a"), Value("x"))), Equals, "contains(#n0, :v0)")
+	c.Assert(condition(c, AttributeExists(Name("a"))), Equals, "attribute_exists(#n0)")
+	c.Assert(condition(c, AttributeNotExists(Name("a"))), Equals, "attribute_not_exists(#n0)")
+
+	expr, err := NewBuilder().WithCondition(AttributeType(Name("a"), dynamodb.SS)).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ConditionExpression, Equals, "attribute_type(#n0, :v0)")
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].S, Equals, "SS")
+
+	// empty operands are kept rather than encoded as NULL
+	expr, err = NewBuilder().WithCondition(And(BeginsWith(Name("x"), ""), Equal(Name("y"), Value([]byte{})))).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ExpressionAttributeValues[":v0"].S, NotNil)
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].S, Equals, "")
+	c.Assert(expr.ExpressionAttributeValues[":v1"].B, DeepEquals, []byte{})
+}
+
+func (s *ExpressionSuite) TestLogical(c *ck.C) {
+	cond := And(
+		Equal(Name("status"), Value("active")),
+		Or(AttributeNotExists(Name("deleted")), Not(Equal(Name("deleted"), Value(true)))),
+	)
+	expr, err := NewBuilder().WithCondition(cond).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ConditionExpression, Equals, "(#n0 = :v0) AND ((attribute_not_exists(#n1)) OR (NOT (#n1 = :v1)))")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{"#n0": "status", "#n1": "deleted"})
+	c.Assert(expr.ExpressionAttributeValues, HasLen, 2)
+
+	c.Assert(condition(c, And(AttributeExists(Name("a")))), Equals, "attribute_exists(#n0)")
+
+	_, err = NewBuilder().WithCondition(Or()).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: OR requires at least one condition")
+	_, err = NewBuilder().WithCondition(Not(Condition{})).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: condition is not set")
+	c.Assert(Condition{}.IsSet(), Equals, false)
+}
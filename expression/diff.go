@@ -0,0 +1,187 @@
+package expression
+
+import (
+	"backflip/aws/dynamodb"
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Diff encodes before and after and returns the Update that changes an item
+// stored as before into after, so that a read-modify-write doesn't overwrite
+// attributes changed concurrently by someone else. See DiffAttributeValues.
+// Use DiffWithEncoder for items written with Encoder options.
+func Diff(before, after interface{}) (Update, error) {
+	return diffEncoded(dynamodb.EncodeToAttributeValue, before, after)
+}
+
+// DiffWithEncoder is like Diff, encoding before and after with enc.
+func DiffWithEncoder(enc *dynamodb.Encoder, before, after interface{}) (Update, error) {
+	return diffEncoded(enc.EncodeToAttributeValue, before, after)
+}
+
+func diffEncoded(encode func(interface{}) (*dynamodb.AttributeValue, error), before, after interface{}) (Update, error) {
+	beforeAttr, err := encode(before)
+	if err != nil {
+		return Update{}, err
+	}
+	afterAttr, err := encode(after)
+	if err != nil {
+		return Update{}, err
+	}
+	return DiffAttributeValues(beforeAttr, afterAttr)
+}
+
+// DiffAttributeValues returns the Update that changes the item before into
+// after, both of which must be M attributes. Maps present in both are compared
+// attribute by attribute and lists element by element, so only changed paths
+// are SET, and attributes or trailing list elements that no longer exist are
+// REMOVEd. The Update has no actions if before and after are equal.
+//
+// Attributes used as keys can't be updated, so before and after should have
+// the same key.
+func DiffAttributeValues(before, after *dynamodb.AttributeValue) (Update, error) {
+	if before == nil || after == nil || before.M == nil || after.M == nil {
+		return Update{}, fmt.Errorf("aws.dynamodb.expression: Diff requires values encoded as M")
+	}
+	var u Update
+	diffMap(&u, nil, before.M, after.M)
+	return u, nil
+}
+
+func diffMap(u *Update, path []pathElem, before, after dynamodb.AttributeValueMap) {
+	keys := make([]string, 0, len(before)+len(after))
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := appendPath(path, pathElem{name: k, index: -1})
+		b, inBefore := before[k]
+		a, inAfter := after[k]
+		switch {
+		case !inAfter:
+			*u = u.Remove(NameOperand{elems: p})
+		case !inBefore:
+			*u = u.Set(NameOperand{elems: p}, Value(a))
+		default:
+			diffValue(u, p, b, a)
+		}
+	}
+}
+
+func diffValue(u *Update, path []pathElem, before, after *dynamodb.AttributeValue) {
+	switch {
+	case equalAttributes(before, after):
+	case before.M != nil && after.M != nil:
+		diffMap(u, path, before.M, after.M)
+	case before.L != nil && after.L != nil:
+		i := 0
+		for ; i < len(before.L) && i < len(after.L); i++ {
+			diffValue(u, appendPath(path, pathElem{index: i}), before.L[i], after.L[i])
+		}
+		// setting an index past the end of a list appends to it
+		for ; i < len(after.L); i++ {
+			*u = u.Set(NameOperand{elems: appendPath(path, pathElem{index: i})}, Value(after.L[i]))
+		}
+		for ; i < len(before.L); i++ {
+			*u = u.Remove(NameOperand{elems: appendPath(path, pathElem{index: i})})
+		}
+	default:
+		*u = u.Set(NameOperand{elems: path}, Value(after))
+	}
+}
+
+// appendPath returns a copy of path with e appended, so that paths held by
+// earlier actions aren't modified.
+func appendPath(path []pathElem, e pathElem) []pathElem {
+	p := make([]pathElem, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, e)
+}
+
+// equalAttributes reports whether a and b hold the same value, ignoring the
+// order of set elements.
+func equalAttributes(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Type() {
+	case dynamodb.B:
+		return bytes.Equal(a.B, b.B)
+	case dynamodb.BOOL:
+		return *a.BOOL == *b.BOOL
+	case dynamodb.S:
+		return *a.S == *b.S
+	case dynamodb.N:
+		return *a.N == *b.N
+	case dynamodb.NULL:
+		return *a.NULL == *b.NULL
+	case dynamodb.M:
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for k, av := range a.M {
+			if bv, ok := b.M[k]; !ok || !equalAttributes(av, bv) {
+				return false
+			}
+		}
+		return true
+	case dynamodb.L:
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !equalAttributes(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case dynamodb.SS:
+		return equalSets(stringElems(a.SS), stringElems(b.SS))
+	case dynamodb.NS:
+		return equalSets(stringElems(a.NS), stringElems(b.NS))
+	case dynamodb.BS:
+		as := make([]string, len(a.BS))
+		for i, e := range a.BS {
+			as[i] = string(e)
+		}
+		bs := make([]string, len(b.BS))
+		for i, e := range b.BS {
+			bs[i] = string(e)
+		}
+		return equalSets(as, bs)
+	}
+	return false
+}
+
+func stringElems(set []*string) []string {
+	out := make([]string, len(set))
+	for i, s := range set {
+		out[i] = *s
+	}
+	return out
+}
+
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package expression_test
+
+import (
+	"backflip/aws/dynamodb"
+	. "backflip/aws/dynamodb/expression"
+
+	ck "gopkg.in/check.v1"
+)
+
+type diffLine struct {
+	SKU string `dynamodb:"sku"`
+	Qty int    `dynamodb:"qty"`
+}
+
+type diffItem struct {
+	ID      string              `dynamodb:"id"`
+	Status  string              `dynamodb:"status,omitempty"`
+	Lease   string              `dynamodb:"lease,omitempty"`
+	Lines   []diffLine          `dynamodb:"lines"`
+	Tags    []string            `dynamodb:"tags,set"`
+	Meta    map[string]string   `dynamodb:"meta"`
+	Weird   map[string]int      `dynamodb:"weird"`
+	Options map[string][]string `dynamodb:"options"`
+}
+
+func diff(c *ck.C, before, after interface{}) Expression {
+	u, err := Diff(before, after)
+	c.Assert(err, IsNil)
+	expr, err := NewBuilder().WithUpdate(u).Build()
+	c.Assert(err, IsNil)
+	return expr
+}
+
+func (s *ExpressionSuite) TestDiff(c *ck.C) {
+	before := diffItem{
+		ID:     "1",
+		Lease:  "abc",
+		Lines:  []diffLine{{"a", 1}, {"b", 2}, {"c", 3}},
+		Tags:   []string{"x", "y"},
+		Meta:   map[string]string{"k1": "v1", "k2": "v2"},
+		Weird:  map[string]int{"a.b": 1},
+		Status: "open",
+	}
+	after := before
+	after.Status = "closed"
+	after.Lease = ""
+	after.Lines = []diffLine{{"a", 1}, {"b", 5}}
+	after.Tags = []string{"y", "x"}
+	after.Meta = map[string]string{"k1": "v1", "k3": "v3"}
+	after.Weird = map[string]int{"a.b": 2}
+
+	expr := diff(c, &before, &after)
+	c.Assert(expr.UpdateExpression, Equals,
+		"SET #n1[1].#n2 = :v0, #n3.#n5 = :v1, #n6 = :v2, #n7.#n8 = :v3 REMOVE #n0, #n1[2], #n3.#n4")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{
+		"#n0": "lease", "#n1": "lines", "#n2": "qty", "#n3": "meta", "#n4": "k2", "#n5": "k3",
+		"#n6": "status", "#n7": "weird", "#n8": "a.b",
+	})
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].N, Equals, "5")
+	c.Assert(*expr.ExpressionAttributeValues[":v2"].S, Equals, "closed")
+}
+
+func (s *ExpressionSuite) TestDiffLists(c *ck.C) {
+	before := diffItem{ID: "1", Lines: []diffLine{{"a", 1}}, Options: map[string][]string{"o": {"x"}}}
+	after := before
+	after.Lines = []diffLine{{"a", 1}, {"b", 2}, {"c", 3}}
+	after.Options = map[string][]string{"o": {"z", "x"}}
+
+	expr := diff(c, &before, &after)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0[1] = :v0, #n0[2] = :v1, #n1.#n2[0] = :v2, #n1.#n2[1] = :v3")
+	c.Assert(*expr.ExpressionAttributeValues[":v1"].M["sku"].S, Equals, "c")
+
+	// a change of type replaces the whole value
+	after.Lines = nil
+	expr = diff(c, &before, &after)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = :v0, #n1.#n2[0] = :v1, #n1.#n2[1] = :v2")
+	c.Assert(expr.ExpressionAttributeValues[":v0"].NULL, NotNil)
+}
+
+func (s *ExpressionSuite) TestDiffWithEncoder(c *ck.C) {
+	type item struct {
+		ID   string `ddb:"id"`
+		Note string `ddb:"note"`
+	}
+	enc := dynamodb.NewEncoder(dynamodb.TagName("ddb"), dynamodb.EmptyStrings(dynamodb.EmptyPreserved))
+	u, err := DiffWithEncoder(enc, &item{ID: "1", Note: "x"}, &item{ID: "1"})
+	c.Assert(err, IsNil)
+	expr, err := NewBuilder().WithUpdate(u).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = :v0")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{"#n0": "note"})
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].S, Equals, "")
+}
+
+func (s *ExpressionSuite) TestDiffEqual(c *ck.C) {
+	item := diffItem{ID: "1", Tags: []string{"a", "b"}}
+	u, err := Diff(&item, &item)
+	c.Assert(err, IsNil)
+	c.Assert(u.IsSet(), Equals, false)
+
+	_, err = Diff(1, &item)
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: Diff requires values encoded as M")
+	_, err = DiffAttributeValues(nil, dynamodb.MustEncodeToAttributeValue(&item))
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: Diff requires values encoded as M")
+}
@@ -0,0 +1,307 @@
+// Package expression builds dynamodb condition, filter, update, key condition
+// and projection expressions along with the ExpressionAttributeNames and
+// ExpressionAttributeValues they refer to. Every attribute name is replaced
+// with a placeholder, so reserved words need no special handling, and values
+// are encoded with a dynamodb.Encoder.
+//
+//	cond := expression.And(
+//	    expression.Equal(expression.Name("status"), expression.Value("active")),
+//	    expression.AttributeNotExists(expression.Name("deleted")),
+//	)
+//	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+package expression
+
+import (
+	"backflip/aws/dynamodb"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An Expression holds rendered expressions and the placeholders they use, with
+// the field names of the dynamodb request parameters.
+type Expression struct {
+	ConditionExpression       string                     `json:",omitempty"`
+	KeyConditionExpression    string                     `json:",omitempty"`
+	UpdateExpression          string                     `json:",omitempty"`
+	FilterExpression          string                     `json:",omitempty"`
+	ProjectionExpression      string                     `json:",omitempty"`
+	ExpressionAttributeNames  map[string]string          `json:",omitempty"`
+	ExpressionAttributeValues dynamodb.AttributeValueMap `json:",omitempty"`
+}
+
+// A Builder collects the expressions for a single request. Placeholders are
+// shared between them, so they must be built together.
+type Builder struct {
+	encoder    *dynamodb.Encoder
+	condition  *Condition
+	filter     *Condition
+	update     *Update
+	key        *KeyCondition
+	projection *Projection
+}
+
+func NewBuilder() Builder {
+	return Builder{}
+}
+
+// WithEncoder sets the Encoder used for values, in place of the default,
+// which differs from dynamodb's default by keeping empty strings and binary
+// values, so that an operand such as BeginsWith(name, "") isn't sent as NULL.
+func (b Builder) WithEncoder(enc *dynamodb.Encoder) Builder {
+	b.encoder = enc
+	return b
+}
+
+// WithCondition sets the ConditionExpression.
+func (b Builder) WithCondition(cond Condition) Builder {
+	b.condition = &cond
+	return b
+}
+
+// WithUpdate sets the UpdateExpression.
+func (b Builder) WithUpdate(u Update) Builder {
+	b.update = &u
+	return b
+}
+
+// WithKeyCondition sets the KeyConditionExpression.
+func (b Builder) WithKeyCondition(k KeyCondition) Builder {
+	b.key = &k
+	return b
+}
+
+// WithProjection sets the ProjectionExpression.
+func (b Builder) WithProjection(p Projection) Builder {
+	b.projection = &p
+	return b
+}
+
+// WithFilter sets the FilterExpression.
+func (b Builder) WithFilter(cond Condition) Builder {
+	b.filter = &cond
+	return b
+}
+
+func (b Builder) Build() (Expression, error) {
+	st := newBuildState(b.encoder)
+	var expr Expression
+	var err error
+	if b.condition != nil {
+		if expr.ConditionExpression, err = buildCondition(*b.condition, st); err != nil {
+			return Expression{}, err
+		}
+	}
+	if b.update != nil {
+		if expr.UpdateExpression, err = b.update.build(st); err != nil {
+			return Expression{}, err
+		}
+	}
+	if b.key != nil {
+		if expr.KeyConditionExpression, err = b.key.build(st); err != nil {
+			return Expression{}, err
+		}
+	}
+	if b.filter != nil {
+		if expr.FilterExpression, err = buildCondition(*b.filter, st); err != nil {
+			return Expression{}, err
+		}
+	}
+	if b.projection != nil {
+		if expr.ProjectionExpression, err = b.projection.build(st); err != nil {
+			return Expression{}, err
+		}
+	}
+	if len(st.names) > 0 {
+		expr.ExpressionAttributeNames = st.names
+	}
+	if len(st.values) > 0 {
+		expr.ExpressionAttributeValues = st.values
+	}
+	return expr, nil
+}
+
+// defaultEncoder encodes operands when the Builder has no Encoder.
+var defaultEncoder = dynamodb.NewEncoder(dynamodb.EmptyStrings(dynamodb.EmptyPreserved))
+
+// buildState assigns placeholders while expressions are rendered.
+type buildState struct {
+	encoder      *dynamodb.Encoder
+	names        map[string]string // placeholder to name
+	placeholders map[string]string // name to placeholder
+	values       dynamodb.AttributeValueMap
+
+	// keyValues is set while a key condition is built, since dynamo rejects
+	// key values that are NULL or empty.
+	keyValues bool
+}
+
+func newBuildState(enc *dynamodb.Encoder) *buildState {
+	if enc == nil {
+		enc = defaultEncoder
+	}
+	return &buildState{
+		encoder:      enc,
+		names:        map[string]string{},
+		placeholders: map[string]string{},
+		values:       dynamodb.AttributeValueMap{},
+	}
+}
+
+// name returns the placeholder for an attribute name, reusing it if the name
+// has been seen before.
+func (st *buildState) name(n string) string {
+	if p, ok := st.placeholders[n]; ok {
+		return p
+	}
+	p := "#n" + strconv.Itoa(len(st.names))
+	st.names[p] = n
+	st.placeholders[n] = p
+	return p
+}
+
+// value encodes v and returns its placeholder.
+func (st *buildState) value(v interface{}) (string, error) {
+	attr, err := st.encoder.EncodeToAttributeValue(v)
+	if err != nil {
+		return "", err
+	}
+	if st.keyValues && (attr.NULL != nil || (attr.S != nil && *attr.S == "") || (attr.B != nil && len(attr.B) == 0)) {
+		return "", fmt.Errorf("aws.dynamodb.expression: key condition value %#v must not be NULL or empty", v)
+	}
+	p := ":v" + strconv.Itoa(len(st.values))
+	st.values[p] = attr
+	return p, nil
+}
+
+// An Operand is a name, value or function of a name used in a condition.
+type Operand interface {
+	buildOperand(st *buildState) (string, error)
+}
+
+// buildOperands renders operands into a format string, each replacing a verb.
+func buildOperands(st *buildState, f string, operands ...Operand) (string, error) {
+	args := make([]interface{}, len(operands))
+	for i, o := range operands {
+		s, err := o.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		args[i] = s
+	}
+	return fmt.Sprintf(f, args...), nil
+}
+
+// A NameOperand is an attribute path.
+type NameOperand struct {
+	path string
+	// elems is used in place of path for names that aren't parsed, which
+	// may contain dots or brackets.
+	elems []pathElem
+}
+
+// Name returns an operand for a document path, such as a.b[0].c. Each name in
+// the path is replaced with a placeholder.
+func Name(path string) NameOperand {
+	return NameOperand{path: path}
+}
+
+// AttributeName returns an operand for a top level attribute, without parsing
+// the name as a document path.
+func AttributeName(name string) NameOperand {
+	return NameOperand{elems: []pathElem{{name: name, index: -1}}}
+}
+
+func (n NameOperand) buildOperand(st *buildState) (string, error) {
+	elems := n.elems
+	if elems == nil {
+		var err error
+		if elems, err = parsePath(n.path); err != nil {
+			return "", err
+		}
+	}
+	var sb strings.Builder
+	for _, e := range elems {
+		if e.index < 0 {
+			if e.name == "" {
+				return "", fmt.Errorf("aws.dynamodb.expression: empty attribute name")
+			}
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(st.name(e.name))
+		} else {
+			sb.WriteString("[" + strconv.Itoa(e.index) + "]")
+		}
+	}
+	return sb.String(), nil
+}
+
+// pathElem is a name or, when index isn't negative, a list index in a
+// document path.
+type pathElem struct {
+	name  string
+	index int
+}
+
+func parsePath(path string) ([]pathElem, error) {
+	pathError := func() error {
+		return fmt.Errorf("aws.dynamodb.expression: invalid document path %q", path)
+	}
+
+	var elems []pathElem
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name = part[:i]
+		}
+		if name == "" || strings.ContainsAny(name, "]") {
+			return nil, pathError()
+		}
+		elems = append(elems, pathElem{name: name, index: -1})
+
+		for rest := part[len(name):]; rest != ""; {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, pathError()
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, pathError()
+			}
+			elems = append(elems, pathElem{index: n})
+			rest = rest[end+1:]
+		}
+	}
+	return elems, nil
+}
+
+// A ValueOperand is a value encoded into ExpressionAttributeValues.
+type ValueOperand struct {
+	value interface{}
+}
+
+func Value(v interface{}) ValueOperand {
+	return ValueOperand{v}
+}
+
+func (v ValueOperand) buildOperand(st *buildState) (string, error) {
+	return st.value(v.value)
+}
+
+// A SizeOperand is the size of an attribute, as returned by the size function.
+type SizeOperand struct {
+	name NameOperand
+}
+
+func Size(name NameOperand) SizeOperand {
+	return SizeOperand{name}
+}
+
+func (s SizeOperand) buildOperand(st *buildState) (string, error) {
+	n, err := s.name.buildOperand(st)
+	if err != nil {
+		return "", err
+	}
+	return "size(" + n + ")", nil
+}
@@ -0,0 +1,71 @@
+package expression_test
+
+import (
+	"backflip/aws/dynamodb"
+	. "backflip/aws/dynamodb/expression"
+	"backflip/tools/testutils"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+var TestingT = ck.TestingT
+
+var Equals = ck.Equals
+var IsNil = ck.IsNil
+var NotNil = ck.NotNil
+var Suite = ck.Suite
+var DeepEquals = ck.DeepEquals
+var HasLen = ck.HasLen
+var ErrorMatches = ck.ErrorMatches
+
+func TestExpression(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&ExpressionSuite{})
+	TestingT(t)
+}
+
+type ExpressionSuite struct {
+}
+
+func (s *ExpressionSuite) TestNames(c *ck.C) {
+	expr, err := NewBuilder().WithCondition(AttributeExists(Name("a.b[0][12].size.a"))).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ConditionExpression, Equals, "attribute_exists(#n0.#n1[0][12].#n2.#n0)")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{"#n0": "a", "#n1": "b", "#n2": "size"})
+	c.Assert(expr.ExpressionAttributeValues, IsNil)
+
+	for _, path := range []string{"", "a.", ".a", "a..b", "a[", "a[x]", "a[-1]", "a]", "[0]", "a[0]b"} {
+		_, err := NewBuilder().WithCondition(AttributeExists(Name(path))).Build()
+		c.Assert(err, ErrorMatches, `aws.dynamodb.expression: invalid document path ".*"`, ck.Commentf("%q", path))
+	}
+}
+
+func (s *ExpressionSuite) TestValues(c *ck.C) {
+	type item struct {
+		A int `dynamodb:"a"`
+	}
+	expr, err := NewBuilder().
+		WithCondition(Equal(Name("x"), Value(item{A: 1}))).
+		WithFilter(Equal(Name("x"), Value("s"))).
+		Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ConditionExpression, Equals, "#n0 = :v0")
+	c.Assert(expr.FilterExpression, Equals, "#n0 = :v1")
+	c.Assert(expr.ExpressionAttributeValues, HasLen, 2)
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].M["a"].N, Equals, "1")
+	c.Assert(*expr.ExpressionAttributeValues[":v1"].S, Equals, "s")
+
+	// values use the builder's encoder
+	expr, err = NewBuilder().
+		WithEncoder(dynamodb.NewEncoder(dynamodb.TagName("ddb"))).
+		WithCondition(Equal(Name("x"), Value(struct {
+			A int `ddb:"b"`
+		}{1}))).
+		Build()
+	c.Assert(err, IsNil)
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].M["b"].N, Equals, "1")
+
+	_, err = NewBuilder().WithCondition(Equal(Name("x"), Value(func() {}))).Build()
+	c.Assert(err, ErrorMatches, ".*unsupported type.*")
+}
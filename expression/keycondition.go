@@ -0,0 +1,75 @@
+package expression
+
+import (
+	"fmt"
+)
+
+// A KeyCondition is a KeyConditionExpression for a Query: equality on the
+// partition key, optionally with a condition on the sort key.
+//
+//	key := expression.KeyEqual(expression.Name("customer"), id).
+//	    SortBeginsWith(expression.Name("order"), "2020-")
+type KeyCondition struct {
+	partition Condition
+	sort      Condition
+}
+
+// KeyEqual matches items whose partition key equals value.
+func KeyEqual(name NameOperand, value interface{}) KeyCondition {
+	return KeyCondition{partition: Equal(name, Value(value))}
+}
+
+func (k KeyCondition) SortEqual(name NameOperand, value interface{}) KeyCondition {
+	k.sort = Equal(name, Value(value))
+	return k
+}
+
+func (k KeyCondition) SortLessThan(name NameOperand, value interface{}) KeyCondition {
+	k.sort = LessThan(name, Value(value))
+	return k
+}
+
+func (k KeyCondition) SortLessThanEqual(name NameOperand, value interface{}) KeyCondition {
+	k.sort = LessThanEqual(name, Value(value))
+	return k
+}
+
+func (k KeyCondition) SortGreaterThan(name NameOperand, value interface{}) KeyCondition {
+	k.sort = GreaterThan(name, Value(value))
+	return k
+}
+
+func (k KeyCondition) SortGreaterThanEqual(name NameOperand, value interface{}) KeyCondition {
+	k.sort = GreaterThanEqual(name, Value(value))
+	return k
+}
+
+func (k KeyCondition) SortBetween(name NameOperand, lower, upper interface{}) KeyCondition {
+	k.sort = Between(name, Value(lower), Value(upper))
+	return k
+}
+
+func (k KeyCondition) SortBeginsWith(name NameOperand, prefix string) KeyCondition {
+	k.sort = BeginsWith(name, prefix)
+	return k
+}
+
+func (k KeyCondition) build(st *buildState) (string, error) {
+	if !k.partition.IsSet() {
+		return "", fmt.Errorf("aws.dynamodb.expression: key condition has no partition key")
+	}
+	st.keyValues = true
+	defer func() { st.keyValues = false }()
+	s, err := buildCondition(k.partition, st)
+	if err != nil {
+		return "", err
+	}
+	if k.sort.IsSet() {
+		sort, err := buildCondition(k.sort, st)
+		if err != nil {
+			return "", err
+		}
+		s += " AND " + sort
+	}
+	return s, nil
+}
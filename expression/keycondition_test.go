@@ -0,0 +1,50 @@
+package expression_test
+
+import (
+	. "backflip/aws/dynamodb/expression"
+
+	ck "gopkg.in/check.v1"
+)
+
+func keyCondition(c *ck.C, k KeyCondition) string {
+	expr, err := NewBuilder().WithKeyCondition(k).Build()
+	c.Assert(err, IsNil)
+	return expr.KeyConditionExpression
+}
+
+func (s *ExpressionSuite) TestKeyCondition(c *ck.C) {
+	pk := KeyEqual(Name("customer"), "c1")
+	c.Assert(keyCondition(c, pk), Equals, "#n0 = :v0")
+	c.Assert(keyCondition(c, pk.SortEqual(Name("order"), 1)), Equals, "#n0 = :v0 AND #n1 = :v1")
+	c.Assert(keyCondition(c, pk.SortLessThan(Name("order"), 1)), Equals, "#n0 = :v0 AND #n1 < :v1")
+	c.Assert(keyCondition(c, pk.SortLessThanEqual(Name("order"), 1)), Equals, "#n0 = :v0 AND #n1 <= :v1")
+	c.Assert(keyCondition(c, pk.SortGreaterThan(Name("order"), 1)), Equals, "#n0 = :v0 AND #n1 > :v1")
+	c.Assert(keyCondition(c, pk.SortGreaterThanEqual(Name("order"), 1)), Equals, "#n0 = :v0 AND #n1 >= :v1")
+	c.Assert(keyCondition(c, pk.SortBetween(Name("order"), 1, 5)), Equals, "#n0 = :v0 AND #n1 BETWEEN :v1 AND :v2")
+	c.Assert(keyCondition(c, pk.SortBeginsWith(Name("order"), "2020-")), Equals, "#n0 = :v0 AND begins_with(#n1, :v1)")
+
+	// the last sort key condition wins
+	c.Assert(keyCondition(c, pk.SortEqual(Name("a"), 1).SortLessThan(Name("b"), 2)), Equals, "#n0 = :v0 AND #n1 < :v1")
+
+	expr, err := NewBuilder().
+		WithKeyCondition(pk.SortBeginsWith(Name("order"), "2020-")).
+		WithFilter(Equal(Name("status"), Value("open"))).
+		WithProjection(NamesList(Name("order"), Name("total"))).
+		Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.KeyConditionExpression, Equals, "#n0 = :v0 AND begins_with(#n1, :v1)")
+	c.Assert(expr.FilterExpression, Equals, "#n2 = :v2")
+	c.Assert(expr.ProjectionExpression, Equals, "#n1, #n3")
+
+	_, err = NewBuilder().WithKeyCondition(KeyCondition{}).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: key condition has no partition key")
+
+	// dynamo rejects NULL and empty key values
+	_, err = NewBuilder().WithKeyCondition(pk.SortBeginsWith(Name("order"), "")).Build()
+	c.Assert(err, ErrorMatches, `aws.dynamodb.expression: key condition value "" must not be NULL or empty`)
+	_, err = NewBuilder().WithKeyCondition(KeyEqual(Name("customer"), nil)).Build()
+	c.Assert(err, ErrorMatches, `aws.dynamodb.expression: key condition value <nil> must not be NULL or empty`)
+	// but values elsewhere may be empty
+	_, err = NewBuilder().WithKeyCondition(pk).WithFilter(BeginsWith(Name("note"), "")).Build()
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,60 @@
+package expression
+
+import (
+	"backflip/aws/dynamodb"
+	"fmt"
+	"strings"
+)
+
+// A Projection is a ProjectionExpression listing the attributes to read.
+type Projection struct {
+	names []NameOperand
+}
+
+// NamesList returns a Projection of the given names.
+func NamesList(names ...NameOperand) Projection {
+	return Projection{names}
+}
+
+// ProjectionFor returns a Projection of exactly the attributes that would be
+// decoded into the fields of a struct, so that reads fetch no more than is
+// used. v may be a struct, a pointer to one or its reflect.Type. Use
+// ProjectionForDecoder for structs read with the TagName option.
+func ProjectionFor(v interface{}) Projection {
+	return projectionOf(dynamodb.AttributeNames(v))
+}
+
+// ProjectionForDecoder is like ProjectionFor, using the Decoder's struct tags.
+func ProjectionForDecoder(dec *dynamodb.Decoder, v interface{}) Projection {
+	return projectionOf(dec.AttributeNames(v))
+}
+
+func projectionOf(attrs []string) Projection {
+	names := make([]NameOperand, len(attrs))
+	for i, n := range attrs {
+		names[i] = AttributeName(n)
+	}
+	return Projection{names}
+}
+
+// AddNames returns a Projection with additional names.
+func (p Projection) AddNames(names ...NameOperand) Projection {
+	all := make([]NameOperand, 0, len(p.names)+len(names))
+	all = append(all, p.names...)
+	return Projection{append(all, names...)}
+}
+
+func (p Projection) build(st *buildState) (string, error) {
+	if len(p.names) == 0 {
+		return "", fmt.Errorf("aws.dynamodb.expression: projection has no names")
+	}
+	parts := make([]string, len(p.names))
+	for i, n := range p.names {
+		s, err := n.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ", "), nil
+}
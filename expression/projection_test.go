@@ -0,0 +1,50 @@
+package expression_test
+
+import (
+	"backflip/aws/dynamodb"
+	. "backflip/aws/dynamodb/expression"
+	"reflect"
+
+	ck "gopkg.in/check.v1"
+)
+
+type order struct {
+	ID     string `dynamodb:"id"`
+	Total  int    `json:"total"`
+	Notes  string `dynamodb:"-"`
+	Dotted string `dynamodb:"a.b" ddb:"dotted"`
+	Status string
+}
+
+func (s *ExpressionSuite) TestProjectionFor(c *ck.C) {
+	expr, err := NewBuilder().WithProjection(ProjectionFor(&order{})).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ProjectionExpression, Equals, "#n0, #n1, #n2, #n3")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{
+		"#n0": "id", "#n1": "total", "#n2": "a.b", "#n3": "Status",
+	})
+
+	expr, err = NewBuilder().WithProjection(ProjectionFor(reflect.TypeOf(order{}))).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ExpressionAttributeNames, HasLen, 4)
+
+	dec := dynamodb.NewDecoder(dynamodb.TagName("ddb"))
+	expr, err = NewBuilder().WithProjection(ProjectionForDecoder(dec, order{})).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{
+		"#n0": "ID", "#n1": "total", "#n2": "Notes", "#n3": "dotted", "#n4": "Status",
+	})
+}
+
+func (s *ExpressionSuite) TestProjectionNames(c *ck.C) {
+	p := NamesList(Name("a.b[1]"), AttributeName("c.d"))
+	expr, err := NewBuilder().WithProjection(p.AddNames(Name("a"))).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.ProjectionExpression, Equals, "#n0.#n1[1], #n2, #n0")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{"#n0": "a", "#n1": "b", "#n2": "c.d"})
+
+	_, err = NewBuilder().WithProjection(ProjectionFor(1)).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: projection has no names")
+	_, err = NewBuilder().WithProjection(NamesList(AttributeName(""))).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: empty attribute name")
+}
@@ -0,0 +1,205 @@
+package expression
+
+import (
+	"backflip/aws/dynamodb"
+	"fmt"
+	"strings"
+)
+
+// An Update is an UpdateExpression made of SET, REMOVE, ADD and DELETE
+// actions. Updates are immutable; each method returns a new Update with the
+// action appended.
+//
+//	update := expression.Set(expression.Name("status"), expression.Value("done")).
+//	    Increment(expression.Name("version"), 1).
+//	    Remove(expression.Name("lease"))
+type Update struct {
+	actions []updateAction
+}
+
+type updateClause int
+
+// clauses are rendered in this order
+const (
+	setClause updateClause = iota
+	removeClause
+	addClause
+	deleteClause
+)
+
+var clauseNames = []string{"SET", "REMOVE", "ADD", "DELETE"}
+
+type updateAction struct {
+	clause updateClause
+	build  func(st *buildState) (string, error)
+}
+
+func (u Update) add(clause updateClause, build func(st *buildState) (string, error)) Update {
+	actions := make([]updateAction, len(u.actions), len(u.actions)+1)
+	copy(actions, u.actions)
+	return Update{append(actions, updateAction{clause, build})}
+}
+
+// IsSet reports whether u has any actions.
+func (u Update) IsSet() bool {
+	return len(u.actions) > 0
+}
+
+func Set(name NameOperand, value Operand) Update {
+	return Update{}.Set(name, value)
+}
+
+func SetIfNotExists(name NameOperand, value interface{}) Update {
+	return Update{}.SetIfNotExists(name, value)
+}
+
+func ListAppend(name NameOperand, values interface{}) Update {
+	return Update{}.ListAppend(name, values)
+}
+
+func Increment(name NameOperand, n interface{}) Update {
+	return Update{}.Increment(name, n)
+}
+
+func Remove(name NameOperand) Update {
+	return Update{}.Remove(name)
+}
+
+func Add(name NameOperand, value interface{}) Update {
+	return Update{}.Add(name, value)
+}
+
+func Delete(name NameOperand, value interface{}) Update {
+	return Update{}.Delete(name, value)
+}
+
+// Set sets an attribute to a value, or to another attribute.
+func (u Update) Set(name NameOperand, value Operand) Update {
+	return u.add(setClause, func(st *buildState) (string, error) {
+		return buildOperands(st, "%s = %s", name, value)
+	})
+}
+
+// SetIfNotExists sets an attribute to a value only if it doesn't already exist.
+func (u Update) SetIfNotExists(name NameOperand, value interface{}) Update {
+	return u.add(setClause, func(st *buildState) (string, error) {
+		return buildOperands(st, "%[1]s = if_not_exists(%[1]s, %[2]s)", name, Value(value))
+	})
+}
+
+// ListAppend appends the elements of a slice to a list attribute, creating the
+// list if it doesn't exist.
+func (u Update) ListAppend(name NameOperand, values interface{}) Update {
+	return u.add(setClause, func(st *buildState) (string, error) {
+		n, err := name.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		v, err := st.typedValue(values, "list_append", dynamodb.L)
+		if err != nil {
+			return "", err
+		}
+		empty, err := st.value(&dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}})
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%[1]s = list_append(if_not_exists(%[1]s, %[2]s), %[3]s)", n, empty, v), nil
+	})
+}
+
+// Increment adds n to a number attribute, treating a missing attribute as 0.
+// Unlike Add it works on nested attributes. Use a negative n to decrement.
+func (u Update) Increment(name NameOperand, n interface{}) Update {
+	return u.add(setClause, func(st *buildState) (string, error) {
+		p, err := name.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		zero, err := st.value(0)
+		if err != nil {
+			return "", err
+		}
+		v, err := st.typedValue(n, "Increment", dynamodb.N)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%[1]s = if_not_exists(%[1]s, %[2]s) + %[3]s", p, zero, v), nil
+	})
+}
+
+// Remove removes an attribute, or an element of a list.
+func (u Update) Remove(name NameOperand) Update {
+	return u.add(removeClause, func(st *buildState) (string, error) {
+		return name.buildOperand(st)
+	})
+}
+
+// Add adds a number to a number attribute, or elements to a set attribute,
+// creating the attribute if it doesn't exist. Dynamo only allows ADD on top
+// level attributes.
+func (u Update) Add(name NameOperand, value interface{}) Update {
+	return u.add(addClause, func(st *buildState) (string, error) {
+		n, err := name.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		v, err := st.typedValue(value, "ADD", dynamodb.N, dynamodb.SS, dynamodb.NS, dynamodb.BS)
+		if err != nil {
+			return "", err
+		}
+		return n + " " + v, nil
+	})
+}
+
+// Delete removes elements from a set attribute.
+func (u Update) Delete(name NameOperand, value interface{}) Update {
+	return u.add(deleteClause, func(st *buildState) (string, error) {
+		n, err := name.buildOperand(st)
+		if err != nil {
+			return "", err
+		}
+		v, err := st.typedValue(value, "DELETE", dynamodb.SS, dynamodb.NS, dynamodb.BS)
+		if err != nil {
+			return "", err
+		}
+		return n + " " + v, nil
+	})
+}
+
+func (u Update) build(st *buildState) (string, error) {
+	if len(u.actions) == 0 {
+		return "", fmt.Errorf("aws.dynamodb.expression: update has no actions")
+	}
+	clauses := make([][]string, len(clauseNames))
+	for _, a := range u.actions {
+		s, err := a.build(st)
+		if err != nil {
+			return "", err
+		}
+		clauses[a.clause] = append(clauses[a.clause], s)
+	}
+
+	var parts []string
+	for i, actions := range clauses {
+		if len(actions) > 0 {
+			parts = append(parts, clauseNames[i]+" "+strings.Join(actions, ", "))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// typedValue encodes v and returns its placeholder, or an error if it isn't
+// encoded as one of the allowed attribute types.
+func (st *buildState) typedValue(v interface{}, op string, types ...dynamodb.AttributeValueType) (string, error) {
+	p, err := st.value(v)
+	if err != nil {
+		return "", err
+	}
+	t := st.values[p].Type()
+	for _, allowed := range types {
+		if t == allowed {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("aws.dynamodb.expression: %s does not accept a value encoded as %s", op, t.String())
+}
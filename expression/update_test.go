@@ -0,0 +1,76 @@
+package expression_test
+
+import (
+	. "backflip/aws/dynamodb/expression"
+
+	ck "gopkg.in/check.v1"
+)
+
+func (s *ExpressionSuite) TestUpdate(c *ck.C) {
+	update := Delete(Name("tags"), map[string]struct{}{"old": {}}).
+		Add(Name("count"), 1).
+		Set(Name("status"), Value("done")).
+		Remove(Name("lease")).
+		Set(Name("copy"), Name("status")).
+		Remove(Name("list[2]"))
+	expr, err := NewBuilder().WithUpdate(update).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n2 = :v2, #n4 = #n2 REMOVE #n3, #n5[2] ADD #n1 :v1 DELETE #n0 :v0")
+	c.Assert(expr.ExpressionAttributeNames, DeepEquals, map[string]string{
+		"#n0": "tags", "#n1": "count", "#n2": "status", "#n3": "lease", "#n4": "copy", "#n5": "list",
+	})
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].SS[0], Equals, "old")
+	c.Assert(*expr.ExpressionAttributeValues[":v1"].N, Equals, "1")
+}
+
+func (s *ExpressionSuite) TestUpdateFunctions(c *ck.C) {
+	expr, err := NewBuilder().WithUpdate(SetIfNotExists(Name("created"), 10)).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = if_not_exists(#n0, :v0)")
+
+	expr, err = NewBuilder().WithUpdate(ListAppend(Name("a.log"), []string{"x"})).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0.#n1 = list_append(if_not_exists(#n0.#n1, :v1), :v0)")
+	c.Assert(expr.ExpressionAttributeValues[":v1"].L, HasLen, 0)
+
+	expr, err = NewBuilder().WithUpdate(Increment(Name("n"), -2)).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = if_not_exists(#n0, :v0) + :v1")
+	c.Assert(*expr.ExpressionAttributeValues[":v0"].N, Equals, "0")
+	c.Assert(*expr.ExpressionAttributeValues[":v1"].N, Equals, "-2")
+}
+
+func (s *ExpressionSuite) TestUpdateWithCondition(c *ck.C) {
+	expr, err := NewBuilder().
+		WithUpdate(Set(Name("version"), Value(2))).
+		WithCondition(Equal(Name("version"), Value(1))).
+		Build()
+	c.Assert(err, IsNil)
+	// names are shared between the expressions
+	c.Assert(expr.ConditionExpression, Equals, "#n0 = :v0")
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = :v1")
+	c.Assert(expr.ExpressionAttributeNames, HasLen, 1)
+	c.Assert(expr.ExpressionAttributeValues, HasLen, 2)
+}
+
+func (s *ExpressionSuite) TestUpdateErrors(c *ck.C) {
+	_, err := NewBuilder().WithUpdate(Update{}).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: update has no actions")
+	c.Assert(Update{}.IsSet(), Equals, false)
+
+	_, err = NewBuilder().WithUpdate(Add(Name("a"), "s")).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: ADD does not accept a value encoded as S")
+	_, err = NewBuilder().WithUpdate(Delete(Name("a"), 1)).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: DELETE does not accept a value encoded as N")
+	_, err = NewBuilder().WithUpdate(Increment(Name("a"), "1")).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: Increment does not accept a value encoded as S")
+	_, err = NewBuilder().WithUpdate(ListAppend(Name("a"), 1)).Build()
+	c.Assert(err, ErrorMatches, "aws.dynamodb.expression: list_append does not accept a value encoded as N")
+
+	// updates are immutable
+	base := Set(Name("a"), Value(1))
+	base.Remove(Name("b"))
+	expr, err := NewBuilder().WithUpdate(base).Build()
+	c.Assert(err, IsNil)
+	c.Assert(expr.UpdateExpression, Equals, "SET #n0 = :v0")
+}
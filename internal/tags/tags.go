@@ -0,0 +1,98 @@
+// Package tags parses the struct tags naming and configuring fields, for the
+// reflective encoder in package dynamodb and for the code cmd/ddbgen
+// generates, so the two read tags the same way.
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Default is the struct tag read when no other is named.
+const Default = "dynamodb"
+
+// Lookup returns the tag used to name a struct field. The tag named tagName,
+// or "dynamodb" if it is empty, takes precedence over and entirely replaces
+// the "json" tag so that the stored shape of a struct can differ from its API
+// shape.
+func Lookup(tag reflect.StructTag, tagName string) string {
+	if tagName == "" {
+		tagName = Default
+	}
+	if t, ok := tag.Lookup(tagName); ok {
+		return t
+	}
+	return tag.Get("json")
+}
+
+// Parse splits a struct field's tag into its name and comma-separated
+// options. The name is empty if the tag doesn't give a valid one.
+func Parse(tag string) (string, Options) {
+	name, opts := tag, Options("")
+	if idx := strings.Index(tag, ","); idx != -1 {
+		name, opts = tag[:idx], Options(tag[idx+1:])
+	}
+	if !isValidName(name) {
+		name = ""
+	}
+	return name, opts
+}
+
+func isValidName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		default:
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Options is the string following a comma in a struct field's tag, or the
+// empty string. It does not include the leading comma.
+type Options string
+
+// Contains reports whether the comma-separated options include optionName.
+func (o Options) Contains(optionName string) bool {
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == optionName {
+			return true
+		}
+		s = next
+	}
+	return false
+}
+
+// Values returns the value of each name=value option with the given name.
+func (o Options) Values(optionName string) []string {
+	var values []string
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, optionName+"=") {
+			values = append(values, s[len(optionName)+1:])
+		}
+		s = next
+	}
+	return values
+}
@@ -0,0 +1,263 @@
+package dynamodb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// KeyOf returns the primary key attributes of item, a struct or pointer to a
+// struct whose key fields are tagged with the hashkey and rangekey options:
+//
+//	type Order struct {
+//	    Customer string    `dynamodb:"customer,hashkey"`
+//	    ID       string    `dynamodb:"id,rangekey"`
+//	    Status   string    `dynamodb:"status,gsi=ByStatus:hash"`
+//	    Placed   time.Time `dynamodb:"placed,unixtime,gsi=ByStatus:range,lsi=ByPlaced"`
+//	}
+//
+// The gsi option names a global secondary index and whether the field is its
+// hash or range key, and the lsi option names a local secondary index using the
+// field as its range key. Fields may belong to several indexes.
+func KeyOf(item interface{}) (AttributeValueMap, error) {
+	return defaultEncoder.KeyOf(item)
+}
+
+// IndexKeyOf returns the key attributes of item for the named secondary index.
+// The key of a local secondary index includes the table's hash key.
+func IndexKeyOf(item interface{}, index string) (AttributeValueMap, error) {
+	return defaultEncoder.IndexKeyOf(item, index)
+}
+
+func (enc *Encoder) KeyOf(item interface{}) (AttributeValueMap, error) {
+	return enc.keyOf(item, "")
+}
+
+func (enc *Encoder) IndexKeyOf(item interface{}, index string) (AttributeValueMap, error) {
+	if index == "" {
+		return nil, EncodeError{Message: "index name is empty"}
+	}
+	return enc.keyOf(item, index)
+}
+
+func (enc *Encoder) keyOf(item interface{}, index string) (AttributeValueMap, error) {
+	v := reflect.ValueOf(item)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, EncodeError{Message: fmt.Sprintf("cannot get the key of type %T", item)}
+	}
+
+	ks, err := enc.codecs.keySchema(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	is := &ks.table
+	if index != "" {
+		if is = ks.index(index); is == nil {
+			return nil, EncodeError{Message: fmt.Sprintf("type %s has no index %s", v.Type().String(), index), Struct: v.Type()}
+		}
+	}
+
+	e := &encodeState{Encoder: enc}
+	out := make(AttributeValueMap, 2)
+	for _, kf := range []*keyField{is.hash, is.rng} {
+		if kf == nil {
+			continue
+		}
+		attr, err := kf.encode(e, v)
+		if err != nil {
+			return nil, err
+		}
+		out[kf.name] = attr
+	}
+	return out, nil
+}
+
+// A keyField is a struct field used as a key attribute.
+type keyField struct {
+	field
+	owner reflect.Type
+	enc   encoderFunc
+}
+
+// encode encodes the key field of the struct v, which must be an S, N or B
+// attribute.
+func (kf *keyField) encode(e *encodeState, v reflect.Value) (*AttributeValue, error) {
+	fv := fieldByIndex(v, kf.index)
+	var attr *AttributeValue
+	if fv.IsValid() {
+		var err error
+		if attr, err = kf.enc(e, fv); err != nil {
+			return nil, encodeErrorAt(err, kf.name, kf.owner, kf.goName)
+		}
+	}
+	if attr == nil || !isKeyAttribute(attr) {
+		at := NULL
+		if attr != nil {
+			at = attr.Type()
+		}
+		return nil, EncodeError{
+			Message:       "key attributes must be a non-empty S, N or B",
+			Path:          kf.name,
+			Struct:        kf.owner,
+			Field:         kf.goName,
+			AttributeType: at,
+		}
+	}
+	return attr, nil
+}
+
+// isKeyAttribute reports whether attr can be used as a key, which must be a
+// non-empty S or B, or an N.
+func isKeyAttribute(attr *AttributeValue) bool {
+	switch {
+	case attr.S != nil:
+		return *attr.S != ""
+	case attr.B != nil:
+		return len(attr.B) > 0
+	}
+	return attr.N != nil
+}
+
+// An indexSchema holds the key fields of a table or secondary index.
+type indexSchema struct {
+	name  string
+	local bool
+	hash  *keyField
+	rng   *keyField
+}
+
+// A keySchema holds the key fields of a struct type, from its hashkey,
+// rangekey, gsi and lsi tag options.
+type keySchema struct {
+	table indexSchema
+	// indexes are sorted by name
+	indexes []indexSchema
+}
+
+func (ks *keySchema) index(name string) *indexSchema {
+	for i := range ks.indexes {
+		if ks.indexes[i].name == name {
+			return &ks.indexes[i]
+		}
+	}
+	return nil
+}
+
+type keySchemaResult struct {
+	ks  *keySchema
+	err error
+}
+
+// keySchema returns the cached key schema for a struct type, building it if
+// needed.
+func (c *codecSet) keySchema(t reflect.Type) (*keySchema, error) {
+	if r, ok := c.keys.Load(t); ok {
+		return r.(keySchemaResult).ks, r.(keySchemaResult).err
+	}
+	ks, err := c.newKeySchema(t)
+	c.keys.Store(t, keySchemaResult{ks, err})
+	return ks, err
+}
+
+func (c *codecSet) newKeySchema(t reflect.Type) (*keySchema, error) {
+	schemaError := func(format string, args ...interface{}) error {
+		return EncodeError{Message: fmt.Sprintf(format, args...), Struct: t}
+	}
+	// set assigns a key field, failing if it's already assigned
+	set := func(dst **keyField, kf *keyField, what string) error {
+		if *dst != nil {
+			return schemaError("type %s has more than one %s: %s and %s", t.String(), what, (*dst).goName, kf.goName)
+		}
+		*dst = kf
+		return nil
+	}
+
+	ks := &keySchema{}
+	indexes := map[string]*indexSchema{}
+	index := func(name string, local bool) (*indexSchema, error) {
+		is := indexes[name]
+		if is == nil {
+			is = &indexSchema{name: name, local: local}
+			indexes[name] = is
+		} else if is.local != local {
+			return nil, schemaError("type %s uses %s as both a gsi and an lsi", t.String(), name)
+		}
+		return is, nil
+	}
+
+	for _, f := range cachedTypeFields(t, c.tagName) {
+		if !f.hashKey && !f.rangeKey && len(f.gsi) == 0 && len(f.lsi) == 0 {
+			continue
+		}
+		kf := &keyField{
+			field: f,
+			owner: fieldOwner(t, f.index),
+			enc:   c.newFieldEncoder(f, typeByIndex(t, f.index)),
+		}
+
+		if f.hashKey {
+			if err := set(&ks.table.hash, kf, "hashkey"); err != nil {
+				return nil, err
+			}
+		}
+		if f.rangeKey {
+			if err := set(&ks.table.rng, kf, "rangekey"); err != nil {
+				return nil, err
+			}
+		}
+		for _, opt := range f.gsi {
+			name, kind := opt, ""
+			if i := strings.LastIndexByte(opt, ':'); i >= 0 {
+				name, kind = opt[:i], opt[i+1:]
+			}
+			if name == "" || (kind != "hash" && kind != "range") {
+				return nil, schemaError("invalid option gsi=%s on %s.%s, expected gsi=Name:hash or gsi=Name:range", opt, t.String(), f.goName)
+			}
+			is, err := index(name, false)
+			if err != nil {
+				return nil, err
+			}
+			if kind == "hash" {
+				err = set(&is.hash, kf, "hash key for index "+name)
+			} else {
+				err = set(&is.rng, kf, "range key for index "+name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range f.lsi {
+			if name == "" {
+				return nil, schemaError("invalid option lsi= on %s.%s, expected lsi=Name", t.String(), f.goName)
+			}
+			is, err := index(name, true)
+			if err != nil {
+				return nil, err
+			}
+			if err := set(&is.rng, kf, "range key for index "+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if ks.table.hash == nil {
+		return nil, schemaError("type %s has no hashkey field", t.String())
+	}
+	for _, is := range indexes {
+		if is.local {
+			if ks.table.rng == nil {
+				return nil, schemaError("local index %s of type %s requires a rangekey field", is.name, t.String())
+			}
+			is.hash = ks.table.hash
+		} else if is.hash == nil {
+			return nil, schemaError("index %s of type %s has no hash key", is.name, t.String())
+		}
+		ks.indexes = append(ks.indexes, *is)
+	}
+	sort.Slice(ks.indexes, func(i, j int) bool { return ks.indexes[i].name < ks.indexes[j].name })
+	return ks, nil
+}
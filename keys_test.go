@@ -0,0 +1,137 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"testing"
+	"time"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestKeys(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&KeySuite{})
+	TestingT(t)
+}
+
+type KeySuite struct {
+}
+
+type order struct {
+	Customer string    `dynamodb:"customer,hashkey"`
+	ID       int64     `dynamodb:"id,string,rangekey"`
+	Status   string    `dynamodb:"status,gsi=ByStatus:hash"`
+	Placed   time.Time `dynamodb:"placed,unixtime,gsi=ByStatus:range,lsi=ByPlaced"`
+	Notes    string    `dynamodb:"notes"`
+}
+
+func (s *KeySuite) TestKeyOf(c *ck.C) {
+	o := &order{Customer: "c1", ID: 42, Status: "open", Placed: time.Unix(1500000000, 0), Notes: "x"}
+
+	key, err := KeyOf(o)
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, AttributeValueMap{
+		"customer": sAttr("c1"),
+		"id":       sAttr("42"),
+	})
+
+	key, err = IndexKeyOf(*o, "ByStatus")
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, AttributeValueMap{
+		"status": sAttr("open"),
+		"placed": nAttr("1500000000"),
+	})
+
+	key, err = IndexKeyOf(o, "ByPlaced")
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, AttributeValueMap{
+		"customer": sAttr("c1"),
+		"placed":   nAttr("1500000000"),
+	})
+
+	_, err = IndexKeyOf(o, "ByNothing")
+	c.Check(err, ErrorMatches, ".*has no index ByNothing")
+}
+
+func (s *KeySuite) TestEmbeddedKey(c *ck.C) {
+	type Base struct {
+		PK string `dynamodb:"pk,hashkey"`
+	}
+	type item struct {
+		Base
+		Name string `dynamodb:"name"`
+	}
+	key, err := KeyOf(item{Base{"p"}, "n"})
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, AttributeValueMap{"pk": sAttr("p")})
+}
+
+func (s *KeySuite) TestTagName(c *ck.C) {
+	type item struct {
+		PK string `dynamodb:"-" ddb:"pk,hashkey"`
+	}
+	key, err := NewEncoder(TagName("ddb")).KeyOf(&item{"p"})
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, AttributeValueMap{"pk": sAttr("p")})
+}
+
+func (s *KeySuite) TestInvalidKeyValues(c *ck.C) {
+	_, err := KeyOf(order{ID: 1})
+	c.Check(err, ErrorMatches, "aws.dynamodb.EncodeError: customer: key attributes must be a non-empty S, N or B")
+
+	type listKey struct {
+		PK []string `dynamodb:"pk,hashkey"`
+	}
+	_, err = KeyOf(listKey{[]string{"a"}})
+	c.Check(err, ErrorMatches, ".*pk: key attributes must be.*")
+	c.Check(err.(EncodeError).AttributeType, Equals, L)
+
+	_, err = KeyOf("pk")
+	c.Check(err, ErrorMatches, ".*cannot get the key of type string")
+}
+
+func (s *KeySuite) TestInvalidSchemas(c *ck.C) {
+	type noHash struct {
+		SK string `dynamodb:"sk,rangekey"`
+	}
+	type twoHash struct {
+		A string `dynamodb:"a,hashkey"`
+		B string `dynamodb:"b,hashkey"`
+	}
+	type badGSI struct {
+		A string `dynamodb:"a,hashkey"`
+		B string `dynamodb:"b,gsi=Index"`
+	}
+	type gsiNoHash struct {
+		A string `dynamodb:"a,hashkey"`
+		B string `dynamodb:"b,gsi=Index:range"`
+	}
+	type lsiNoRange struct {
+		A string `dynamodb:"a,hashkey"`
+		B string `dynamodb:"b,lsi=Index"`
+	}
+	type mixed struct {
+		A string `dynamodb:"a,hashkey,rangekey"`
+		B string `dynamodb:"b,gsi=Index:hash,lsi=Index"`
+	}
+
+	for _, t := range []struct {
+		item interface{}
+		err  string
+	}{
+		{noHash{"s"}, ".*has no hashkey field"},
+		{twoHash{"a", "b"}, ".*has more than one hashkey: A and B"},
+		{badGSI{"a", "b"}, ".*invalid option gsi=Index on .*badGSI.B.*"},
+		{gsiNoHash{"a", "b"}, ".*index Index of type .* has no hash key"},
+		{lsiNoRange{"a", "b"}, ".*local index Index of type .* requires a rangekey field"},
+		{mixed{"a", "b"}, ".*uses Index as both a gsi and an lsi"},
+	} {
+		_, err := KeyOf(t.item)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func sAttr(s string) *AttributeValue { return &AttributeValue{S: &s} }
+
+func nAttr(n string) *AttributeValue { return &AttributeValue{N: &n} }
@@ -0,0 +1,203 @@
+package dynamodb
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Number is a dynamo number in its string form. It is encoded as an N
+// attribute without any loss of precision, and can be used to decode numbers
+// into interface{} values with the UseNumber option.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+var (
+	numberType   = reflect.TypeOf(Number(""))
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// isNumberType reports whether t is Number or one of the math/big types, which
+// are always encoded as N rather than through their marshaling methods.
+func isNumberType(t reflect.Type) bool {
+	return t == numberType || t == bigIntType || t == bigFloatType || t == bigRatType
+}
+
+// Dynamo numbers have up to 38 significant digits, and a magnitude between
+// 1E-130 and 9.9999999999999999999999999999999999999E+125.
+const (
+	maxNumberDigits   = 38
+	minNumberExponent = -129
+	maxNumberExponent = 126
+)
+
+// bigFloatPrec is the precision given to a big.Float with no precision set when
+// decoding, which is enough to hold every dynamo number.
+const bigFloatPrec = 128
+
+// checkNumber returns an error if s isn't a number dynamo can store.
+func checkNumber(s string) error {
+	mant, exp := s, "0"
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mant, exp = s[:i], s[i+1:]
+	}
+	if len(mant) > 0 && (mant[0] == '-' || mant[0] == '+') {
+		mant = mant[1:]
+	}
+
+	intPart, fracPart := mant, ""
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		intPart, fracPart = mant[:i], mant[i+1:]
+	}
+	e, err := strconv.Atoi(exp)
+	if err != nil || (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return fmt.Errorf("malformed number %q", s)
+	}
+
+	// the number is 0.digits * 10^point
+	digits := intPart + fracPart
+	point := len(intPart)
+	trimmed := strings.TrimLeft(digits, "0")
+	point -= len(digits) - len(trimmed)
+	digits = strings.TrimRight(trimmed, "0")
+	if digits == "" {
+		return nil
+	}
+	if len(digits) > maxNumberDigits {
+		return fmt.Errorf("number %s has more than %d significant digits", s, maxNumberDigits)
+	}
+	if point+e < minNumberExponent || point+e > maxNumberExponent {
+		return fmt.Errorf("number %s is out of range", s)
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatBigNumber formats a big.Int, big.Float or big.Rat as a decimal string.
+func formatBigNumber(v reflect.Value) (string, error) {
+	switch x := bigNumberAddr(v).(type) {
+	case *big.Int:
+		return x.String(), nil
+	case *big.Float:
+		if x.IsInf() {
+			return "", fmt.Errorf("infinite numbers are not supported")
+		}
+		return x.Text('g', -1), nil
+	case *big.Rat:
+		if x.IsInt() {
+			return x.Num().String(), nil
+		}
+		n, exact := decimalPlaces(x)
+		if !exact {
+			return "", fmt.Errorf("%s has no exact decimal representation", x.String())
+		}
+		return x.FloatString(n), nil
+	}
+	panic(fmt.Errorf("aws.dynamodb.formatBigNumber: unsupported type %#v", v.Type()))
+}
+
+// decimalPlaces returns the number of digits after the decimal point needed to
+// write x exactly, which is possible only if its denominator has no prime
+// factors other than 2 and 5.
+func decimalPlaces(x *big.Rat) (int, bool) {
+	d := new(big.Int).Set(x.Denom())
+	var q, r big.Int
+	count := func(p int64) int {
+		n := 0
+		for {
+			q.QuoRem(d, big.NewInt(p), &r)
+			if r.Sign() != 0 {
+				return n
+			}
+			d.Set(&q)
+			n++
+		}
+	}
+	twos, fives := count(2), count(5)
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// bigNumberAddr returns a pointer to the math/big value v, copying it if it
+// isn't addressable since the math/big methods have pointer receivers.
+func bigNumberAddr(v reflect.Value) interface{} {
+	if !v.CanAddr() {
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		v = pv.Elem()
+	}
+	return v.Addr().Interface()
+}
+
+func numberDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.N != nil {
+		v.SetString(*attr.N)
+	} else if attr.NULL != nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		d.typeMismatch(attr, v.Type())
+	}
+	return nil
+}
+
+func bigNumberDecoder(d *decodeState, attr *AttributeValue, v reflect.Value) error {
+	if attr.N == nil {
+		if attr.NULL != nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			d.typeMismatch(attr, v.Type())
+		}
+		return nil
+	}
+
+	ok := false
+	switch x := v.Addr().Interface().(type) {
+	case *big.Int:
+		var r *big.Rat
+		if r, ok = new(big.Rat).SetString(*attr.N); ok && r.IsInt() {
+			x.Set(r.Num())
+		} else {
+			ok = false
+		}
+	case *big.Float:
+		if x.Prec() == 0 {
+			x.SetPrec(bigFloatPrec)
+		}
+		_, ok = x.SetString(*attr.N)
+	case *big.Rat:
+		_, ok = x.SetString(*attr.N)
+	}
+	if !ok {
+		return DecodeError{Message: fmt.Sprintf("cannot parse number %s into type %s", *attr.N, v.Type().String()), AttributeType: attr.Type()}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"math"
+	"math/big"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestNumbers(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&NumberSuite{})
+	TestingT(t)
+}
+
+type NumberSuite struct {
+}
+
+func (s *NumberSuite) TestNumber(c *ck.C) {
+	type X struct {
+		N     Number
+		Empty Number
+		Set   []Number `dynamodb:",set"`
+	}
+	x := X{N: "123456789012345678901234567890", Set: []Number{"1", "2.5"}}
+	attr, err := EncodeToAttributeValue(&x)
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["N"].N, Equals, "123456789012345678901234567890")
+	c.Assert(*attr.M["Empty"].N, Equals, "0")
+	c.Assert(attr.M["Set"].NS, HasLen, 2)
+
+	var out X
+	c.Assert(DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out, DeepEquals, X{N: x.N, Empty: "0", Set: x.Set})
+
+	n, err := Number("12").Int64()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(12))
+	f, err := Number("1.5").Float64()
+	c.Assert(err, IsNil)
+	c.Assert(f, Equals, 1.5)
+}
+
+func (s *NumberSuite) TestUseNumber(c *ck.C) {
+	attr := MustEncodeToAttributeValue(map[string]interface{}{
+		"big": Number("9007199254740993"),
+		"set": []int{1, 2},
+	})
+	ns := []string{"1", "9007199254740993"}
+	attr.M["set"] = &AttributeValue{NS: []*string{&ns[0], &ns[1]}}
+
+	var out interface{}
+	c.Assert(NewDecoder(UseNumber()).DecodeAttributeValueToInterface(attr, &out), IsNil)
+	m := out.(map[string]interface{})
+	c.Assert(m["big"], Equals, Number("9007199254740993"))
+	c.Assert(m["set"], DeepEquals, []Number{"1", "9007199254740993"})
+}
+
+func (s *NumberSuite) TestBigNumbers(c *ck.C) {
+	type X struct {
+		I  *big.Int
+		F  *big.Float
+		R  *big.Rat
+		IV big.Int
+		N  *big.Int
+	}
+	i, _ := new(big.Int).SetString("-12345678901234567890123456789012345678", 10)
+	f, _ := new(big.Float).SetPrec(128).SetString("1.25e100")
+	x := X{I: i, F: f, R: big.NewRat(1, 8), IV: *big.NewInt(7)}
+
+	attr, err := EncodeToAttributeValue(&x)
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["I"].N, Equals, "-12345678901234567890123456789012345678")
+	c.Assert(*attr.M["F"].N, Equals, "1.25e+100")
+	c.Assert(*attr.M["R"].N, Equals, "0.125")
+	c.Assert(*attr.M["IV"].N, Equals, "7")
+	c.Assert(attr.M["N"].NULL, NotNil)
+
+	var out X
+	c.Assert(DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out.I.Cmp(x.I), Equals, 0)
+	c.Assert(out.F.Cmp(x.F), Equals, 0)
+	c.Assert(out.R.Cmp(x.R), Equals, 0)
+	c.Assert(out.IV.Cmp(&x.IV), Equals, 0)
+	c.Assert(out.N, IsNil)
+
+	// big.Int can't hold a fraction
+	n := "1.5"
+	err = DecodeAttributeValueToInterface(&AttributeValue{N: &n}, &out.IV)
+	c.Assert(err, ErrorMatches, "aws.dynamodb.DecodeError: cannot parse number 1.5 into type big.Int")
+
+	_, err = EncodeToAttributeValue(big.NewRat(1, 3))
+	c.Assert(err, ErrorMatches, ".*1/3 has no exact decimal representation")
+}
+
+func (s *NumberSuite) TestNumberLimits(c *ck.C) {
+	for _, n := range []Number{
+		"0", "-0.0", "1E-130", "9.9999999999999999999999999999999999999E+125", "-1e-130",
+		"12345678901234567890123456789012345678", "1000000000000000000000000000000000000000000", ".5", "5.",
+	} {
+		_, err := EncodeToAttributeValue(n)
+		c.Assert(err, IsNil, ck.Commentf("%s", n))
+	}
+
+	for _, n := range []Number{
+		"1e-131", "1E+126", "123456789012345678901234567890123456789", "abc", "1e", "-", ".", "1.2.3", "0x10",
+	} {
+		_, err := EncodeToAttributeValue(n)
+		c.Assert(err, NotNil, ck.Commentf("%s", n))
+	}
+
+	_, err := EncodeToAttributeValue(1e200)
+	c.Assert(err, ErrorMatches, "aws.dynamodb.EncodeError: number 1e\\+200 is out of range")
+	_, err = EncodeToAttributeValue(math.NaN())
+	c.Assert(err, ErrorMatches, ".*NaN and infinite floats not supported")
+	_, err = EncodeToAttributeValue(map[string]float64{"a": math.Inf(1)})
+	c.Assert(err, ErrorMatches, "aws.dynamodb.EncodeError: a: NaN and infinite floats not supported")
+}
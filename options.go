@@ -0,0 +1,198 @@
+package dynamodb
+
+import (
+	"reflect"
+	"sync"
+)
+
+// An EncoderOption configures an Encoder.
+type EncoderOption interface {
+	applyEncoder(*Encoder)
+}
+
+// A DecoderOption configures a Decoder.
+type DecoderOption interface {
+	applyDecoder(*Decoder)
+}
+
+// An Option configures both Encoders and Decoders. The same options should be
+// given to an Encoder and the Decoder reading back what it wrote.
+type Option interface {
+	EncoderOption
+	DecoderOption
+}
+
+type encoderOptionFunc func(*Encoder)
+
+func (f encoderOptionFunc) applyEncoder(enc *Encoder) { f(enc) }
+
+type decoderOptionFunc func(*Decoder)
+
+func (f decoderOptionFunc) applyDecoder(dec *Decoder) { f(dec) }
+
+type configOptionFunc func(*config)
+
+func (f configOptionFunc) applyEncoder(enc *Encoder) { f(&enc.config) }
+
+func (f configOptionFunc) applyDecoder(dec *Decoder) { f(&dec.config) }
+
+// config holds the settings that change how types are compiled into encoders
+// and decoders.
+type config struct {
+	tagName    string
+	timeFormat string
+	codecs     map[reflect.Type]Codec
+}
+
+// TagName sets the struct tag used to name fields and set their options in
+// place of "dynamodb". The "json" tag is still used when a field has no tag
+// of that name.
+func TagName(name string) Option {
+	return configOptionFunc(func(c *config) {
+		c.tagName = name
+	})
+}
+
+// TimeFormat encodes time.Time values as S attributes formatted with layout,
+// rather than as B attributes holding their JSON encoding. Decoding still
+// accepts B attributes so existing items remain readable.
+func TimeFormat(layout string) Option {
+	return configOptionFunc(func(c *config) {
+		c.timeFormat = layout
+	})
+}
+
+// A Codec encodes and decodes values of a single type, overriding how the
+// type would otherwise be handled, including any Marshaler implementation.
+type Codec struct {
+	// Encode is passed a value of the registered type.
+	Encode func(v interface{}) (*AttributeValue, error)
+	// Decode is passed a pointer to a value of the registered type.
+	Decode func(attr *AttributeValue, v interface{}) error
+}
+
+// WithCodec registers a Codec for values of type t, which is useful for types
+// from other packages that can't implement Marshaler and Unmarshaler.
+func WithCodec(t reflect.Type, codec Codec) Option {
+	return configOptionFunc(func(c *config) {
+		codecs := make(map[reflect.Type]Codec, len(c.codecs)+1)
+		for k, v := range c.codecs {
+			codecs[k] = v
+		}
+		codecs[t] = codec
+		c.codecs = codecs
+	})
+}
+
+// EmptyPolicy controls how empty strings and collections are encoded.
+type EmptyPolicy int
+
+const (
+	// EmptyAsNull encodes empty values as NULL, since dynamo historically
+	// rejected empty strings, binary and lists. Empty but non-nil maps are
+	// still encoded as an empty M.
+	EmptyAsNull EmptyPolicy = iota
+	// EmptyOmitted leaves empty values out of structs and maps. Empty list
+	// elements are still encoded as NULL so that indexes are preserved.
+	EmptyOmitted
+	// EmptyPreserved encodes empty values as an empty S, B, L or M, which
+	// dynamo accepts outside of keys, and nil slices and maps as NULL so the
+	// two can be told apart when decoding. Empty sets are still encoded as
+	// NULL since dynamo doesn't allow them.
+	EmptyPreserved
+)
+
+// EmptyStrings sets how empty strings and binary values are encoded.
+func EmptyStrings(p EmptyPolicy) EncoderOption {
+	return encoderOptionFunc(func(enc *Encoder) {
+		enc.emptyStrings = p
+	})
+}
+
+// EmptyCollections sets how empty lists, maps and sets are encoded.
+func EmptyCollections(p EmptyPolicy) EncoderOption {
+	return encoderOptionFunc(func(enc *Encoder) {
+		enc.emptyCollections = p
+	})
+}
+
+// PreserveEmpty is shorthand for EmptyStrings(EmptyPreserved) and
+// EmptyCollections(EmptyPreserved).
+func PreserveEmpty() EncoderOption {
+	return encoderOptionFunc(func(enc *Encoder) {
+		enc.emptyStrings = EmptyPreserved
+		enc.emptyCollections = EmptyPreserved
+	})
+}
+
+// NumberHandling controls how N attributes are decoded into interface{} values.
+type NumberHandling int
+
+const (
+	// Float64Numbers decodes every number as a float64, like encoding/json.
+	Float64Numbers NumberHandling = iota
+	// Int64Numbers decodes integers that fit in an int64 as int64, and all
+	// other numbers as float64. Number sets are decoded as []interface{}
+	// rather than []float64.
+	Int64Numbers
+	// NumberValues decodes every number as a Number, and number sets as
+	// []Number, so no precision is lost.
+	NumberValues
+)
+
+// InterfaceNumbers sets how numbers are decoded into interface{} values.
+func InterfaceNumbers(h NumberHandling) DecoderOption {
+	return decoderOptionFunc(func(dec *Decoder) {
+		dec.numbers = h
+	})
+}
+
+// UseNumber is shorthand for InterfaceNumbers(NumberValues).
+func UseNumber() DecoderOption {
+	return InterfaceNumbers(NumberValues)
+}
+
+// DisallowUnknownFields reports attributes that don't match any field of the
+// struct being decoded.
+func DisallowUnknownFields() DecoderOption {
+	return decoderOptionFunc(func(dec *Decoder) {
+		dec.disallowUnknownFields = true
+	})
+}
+
+// StrictTypes reports attributes whose type can't be decoded into the target,
+// such as an S attribute for an int field, instead of ignoring them.
+func StrictTypes() DecoderOption {
+	return decoderOptionFunc(func(dec *Decoder) {
+		dec.strictTypes = true
+	})
+}
+
+// A codecSet holds the encoders and decoders compiled for one config. Encoders
+// and Decoders created with the default config share a single codecSet.
+type codecSet struct {
+	config
+	encoders sync.Map // map[reflect.Type]encoderFunc
+	decoders sync.Map // map[reflect.Type]decoderFunc
+	keys     sync.Map // map[reflect.Type]keySchemaResult
+
+	wireEncoders   sync.Map // map[reflect.Type]wireEncoderFunc
+	streamDecoders sync.Map // map[reflect.Type]streamDecoderFunc
+}
+
+var defaultCodecs = &codecSet{}
+
+func newCodecSet(c config) *codecSet {
+	if c.tagName == "" && c.timeFormat == "" && len(c.codecs) == 0 {
+		return defaultCodecs
+	}
+	return &codecSet{config: c}
+}
+
+// overrides reports whether the config replaces the default handling of t.
+func (c *codecSet) overrides(t reflect.Type) bool {
+	if _, ok := c.codecs[t]; ok {
+		return true
+	}
+	return t == timeType && c.timeFormat != ""
+}
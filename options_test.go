@@ -0,0 +1,199 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestOptions(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&OptionsSuite{})
+	TestingT(t)
+}
+
+type OptionsSuite struct {
+}
+
+func (s *OptionsSuite) TestTagName(c *ck.C) {
+	type item struct {
+		A string `ddb:"a" dynamodb:"x"`
+		B string `json:"b"`
+		C string `ddb:"-"`
+	}
+	enc := NewEncoder(TagName("ddb"))
+	attr, err := enc.EncodeToAttributeValue(&item{A: "1", B: "2", C: "3"})
+	c.Assert(err, IsNil)
+	c.Assert(attr.M, HasLen, 2)
+	c.Assert(*attr.M["a"].S, Equals, "1")
+	c.Assert(*attr.M["b"].S, Equals, "2")
+
+	// the default encoder still reads the dynamodb tag
+	attr, err = EncodeToAttributeValue(&item{A: "1"})
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["x"].S, Equals, "1")
+
+	var out item
+	dec := NewDecoder(TagName("ddb"))
+	c.Assert(dec.DecodeAttributeValueToInterface(MustEncodeToAttributeValue(map[string]string{"a": "1", "b": "2", "C": "3"}), &out), IsNil)
+	c.Assert(out, DeepEquals, item{A: "1", B: "2"})
+}
+
+func (s *OptionsSuite) TestTimeFormat(c *ck.C) {
+	type item struct {
+		T  time.Time
+		TP *time.Time
+	}
+	t := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	enc := NewEncoder(TimeFormat(time.RFC3339))
+	attr, err := enc.EncodeToAttributeValue(&item{T: t, TP: &t})
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["T"].S, Equals, "2020-05-01T12:30:00Z")
+	c.Assert(*attr.M["TP"].S, Equals, "2020-05-01T12:30:00Z")
+
+	var out item
+	dec := NewDecoder(TimeFormat(time.RFC3339))
+	c.Assert(dec.DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out.T.Equal(t), Equals, true)
+	c.Assert(out.TP.Equal(t), Equals, true)
+
+	// items written without the option are still readable
+	var out2 item
+	c.Assert(dec.DecodeAttributeValueToInterface(MustEncodeToAttributeValue(&item{T: t}), &out2), IsNil)
+	c.Assert(out2.T.Equal(t), Equals, true)
+	c.Assert(out2.TP, IsNil)
+}
+
+func (s *OptionsSuite) TestWithCodec(c *ck.C) {
+	type item struct {
+		D time.Duration
+		M money
+	}
+	durationCodec := Codec{
+		Encode: func(v interface{}) (*AttributeValue, error) {
+			s := v.(time.Duration).String()
+			return &AttributeValue{S: &s}, nil
+		},
+		Decode: func(attr *AttributeValue, v interface{}) error {
+			d, err := time.ParseDuration(*attr.S)
+			*v.(*time.Duration) = d
+			return err
+		},
+	}
+	moneyCodec := Codec{
+		Encode: func(v interface{}) (*AttributeValue, error) {
+			n := "42"
+			return &AttributeValue{N: &n}, nil
+		},
+		Decode: func(attr *AttributeValue, v interface{}) error {
+			*v.(*money) = money{Cents: 42}
+			return nil
+		},
+	}
+	opts := []Option{
+		WithCodec(reflect.TypeOf(time.Duration(0)), durationCodec),
+		WithCodec(reflect.TypeOf(money{}), moneyCodec),
+	}
+	encOpts := make([]EncoderOption, len(opts))
+	decOpts := make([]DecoderOption, len(opts))
+	for i, opt := range opts {
+		encOpts[i], decOpts[i] = opt, opt
+	}
+
+	attr, err := NewEncoder(encOpts...).EncodeToAttributeValue(&item{D: 90 * time.Second, M: money{Cents: 1}})
+	c.Assert(err, IsNil)
+	c.Assert(*attr.M["D"].S, Equals, "1m30s")
+	// the codec takes precedence over money's Marshaler
+	c.Assert(*attr.M["M"].N, Equals, "42")
+
+	var out item
+	c.Assert(NewDecoder(decOpts...).DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out, DeepEquals, item{D: 90 * time.Second, M: money{Cents: 42}})
+}
+
+func (s *OptionsSuite) TestEmptyOmitted(c *ck.C) {
+	type item struct {
+		S  string
+		B  []byte
+		L  []int
+		M  map[string]int
+		E  map[string]int
+		SS []string `dynamodb:",set"`
+	}
+	in := &item{E: map[string]int{}, SS: []string{}}
+
+	attr, err := EncodeToAttributeValue(in)
+	c.Assert(err, IsNil)
+	c.Assert(attr.M, HasLen, 6)
+	c.Assert(attr.M["E"].M, HasLen, 0)
+
+	attr, err = NewEncoder(EmptyStrings(EmptyOmitted)).EncodeToAttributeValue(in)
+	c.Assert(err, IsNil)
+	c.Assert(attr.M, HasLen, 4)
+	c.Assert(attr.M["L"].NULL, NotNil)
+
+	attr, err = NewEncoder(EmptyCollections(EmptyOmitted)).EncodeToAttributeValue(in)
+	c.Assert(err, IsNil)
+	c.Assert(attr.M, HasLen, 2)
+	c.Assert(attr.M["S"].NULL, NotNil)
+
+	// list elements keep their position
+	attr, err = NewEncoder(EmptyStrings(EmptyOmitted)).EncodeToAttributeValue([]string{"a", "", "b"})
+	c.Assert(err, IsNil)
+	c.Assert(attr.L, HasLen, 3)
+	c.Assert(attr.L[1].NULL, NotNil)
+
+	attr, err = NewEncoder(EmptyStrings(EmptyOmitted)).EncodeToAttributeValue(map[string]string{"a": "", "b": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(attr.M, HasLen, 1)
+}
+
+func (s *OptionsSuite) TestInterfaceNumbers(c *ck.C) {
+	attr := MustEncodeToAttributeValue(map[string]interface{}{"i": 9007199254740993, "f": 1.5})
+	ns := []string{"1", "2.5"}
+	attr.M["ns"] = &AttributeValue{NS: []*string{&ns[0], &ns[1]}}
+
+	var out interface{}
+	c.Assert(DecodeAttributeValueToInterface(attr, &out), IsNil)
+	c.Assert(out.(map[string]interface{})["i"], Equals, float64(9007199254740993))
+	c.Assert(out.(map[string]interface{})["ns"], DeepEquals, []float64{1, 2.5})
+
+	dec := NewDecoder(InterfaceNumbers(Int64Numbers))
+	c.Assert(dec.DecodeAttributeValueToInterface(attr, &out), IsNil)
+	m := out.(map[string]interface{})
+	c.Assert(m["i"], Equals, int64(9007199254740993))
+	c.Assert(m["f"], Equals, 1.5)
+	c.Assert(m["ns"], DeepEquals, []interface{}{int64(1), 2.5})
+}
+
+func (s *OptionsSuite) TestConcurrentReuse(c *ck.C) {
+	type item struct {
+		T time.Time `ddb:"t"`
+		N []int     `ddb:"n"`
+	}
+	enc := NewEncoder(TagName("ddb"), TimeFormat(time.RFC3339))
+	dec := NewDecoder(TagName("ddb"), TimeFormat(time.RFC3339))
+	t := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			attr, err := enc.EncodeToAttributeValue(&item{T: t, N: []int{i}})
+			if err == nil {
+				var out item
+				err = dec.DecodeAttributeValueToInterface(attr, &out)
+				if err == nil && (out.N[0] != i || !out.T.Equal(t)) {
+					err = fmt.Errorf("decoded %v, want %d", out, i)
+				}
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		c.Assert(<-errs, IsNil)
+	}
+}
@@ -0,0 +1,322 @@
+package dynamodb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PlainNumberFormat controls how ToPlainJSON writes N and NS values.
+type PlainNumberFormat int
+
+const (
+	// NumbersAsJSON writes numbers as JSON numbers, keeping their exact text.
+	NumbersAsJSON PlainNumberFormat = iota
+	// NumbersAsStrings writes numbers as JSON strings, for readers that would
+	// lose precision parsing them into a float64.
+	NumbersAsStrings
+)
+
+// PlainBinaryFormat controls how B and BS values are represented.
+type PlainBinaryFormat int
+
+const (
+	// BinaryAsBase64 writes binary values as base64 strings, which
+	// FromPlainJSON reads back as S.
+	BinaryAsBase64 PlainBinaryFormat = iota
+	// BinaryAsTagged writes binary values as {"$binary":"<base64>"}, and
+	// has FromPlainJSON read them back as B.
+	BinaryAsTagged
+)
+
+// PlainSetFormat controls how SS, NS and BS values are represented.
+type PlainSetFormat int
+
+const (
+	// SetsAsArrays writes sets as JSON arrays, which FromPlainJSON reads back
+	// as L.
+	SetsAsArrays PlainSetFormat = iota
+	// SetsAsTagged writes sets as {"$set":[...]}, and has FromPlainJSON read
+	// them back as an SS, NS or BS depending on their elements.
+	SetsAsTagged
+)
+
+const (
+	plainBinaryKey = "$binary"
+	plainSetKey    = "$set"
+)
+
+// A PlainJSONOption configures ToPlainJSON and FromPlainJSON.
+type PlainJSONOption func(*plainJSONConfig)
+
+type plainJSONConfig struct {
+	numbers PlainNumberFormat
+	binary  PlainBinaryFormat
+	sets    PlainSetFormat
+}
+
+func PlainNumbers(f PlainNumberFormat) PlainJSONOption {
+	return func(c *plainJSONConfig) { c.numbers = f }
+}
+
+func PlainBinary(f PlainBinaryFormat) PlainJSONOption {
+	return func(c *plainJSONConfig) { c.binary = f }
+}
+
+func PlainSets(f PlainSetFormat) PlainJSONOption {
+	return func(c *plainJSONConfig) { c.sets = f }
+}
+
+func newPlainJSONConfig(opts []PlainJSONOption) plainJSONConfig {
+	var c plainJSONConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// ToPlainJSON converts an AttributeValue into an ordinary JSON document without
+// type descriptors, so {"M":{"a":{"N":"1"}}} becomes {"a":1}. NULL becomes
+// null, and map keys are sorted.
+func ToPlainJSON(attr *AttributeValue, opts ...PlainJSONOption) ([]byte, error) {
+	c := newPlainJSONConfig(opts)
+	v, err := c.toPlain(attr)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (c plainJSONConfig) toPlain(attr *AttributeValue) (interface{}, error) {
+	if attr == nil {
+		return nil, EncodeError{Message: "nil AttributeValue"}
+	}
+	switch attr.Type() {
+	case B:
+		return c.plainBinary(attr.B), nil
+	case BOOL:
+		return *attr.BOOL, nil
+	case S:
+		return *attr.S, nil
+	case N:
+		return c.plainNumber(*attr.N)
+	case NULL:
+		return nil, nil
+	case M:
+		out := make(map[string]interface{}, len(attr.M))
+		for k, elem := range attr.M {
+			v, err := c.toPlain(elem)
+			if err != nil {
+				return nil, encodeErrorAt(err, k, nil, "")
+			}
+			out[k] = v
+		}
+		return out, nil
+	case L:
+		out := make([]interface{}, len(attr.L))
+		for i, elem := range attr.L {
+			v, err := c.toPlain(elem)
+			if err != nil {
+				return nil, encodeErrorAt(err, indexPath(i), nil, "")
+			}
+			out[i] = v
+		}
+		return out, nil
+	case SS:
+		out := make([]interface{}, len(attr.SS))
+		for i, s := range attr.SS {
+			out[i] = *s
+		}
+		return c.plainSet(out), nil
+	case NS:
+		out := make([]interface{}, len(attr.NS))
+		for i, n := range attr.NS {
+			v, err := c.plainNumber(*n)
+			if err != nil {
+				return nil, encodeErrorAt(err, indexPath(i), nil, "")
+			}
+			out[i] = v
+		}
+		return c.plainSet(out), nil
+	case BS:
+		out := make([]interface{}, len(attr.BS))
+		for i, b := range attr.BS {
+			out[i] = c.plainBinary(b)
+		}
+		return c.plainSet(out), nil
+	}
+	return nil, EncodeError{Message: "AttributeValue has no value set"}
+}
+
+func (c plainJSONConfig) plainNumber(n string) (interface{}, error) {
+	if err := checkNumber(n); err != nil {
+		return nil, EncodeError{Message: err.Error(), AttributeType: N}
+	}
+	if c.numbers == NumbersAsStrings {
+		return n, nil
+	}
+	return json.Number(jsonNumber(n)), nil
+}
+
+// jsonNumber rewrites the forms dynamo accepts but JSON doesn't, such as +1,
+// 007, .5 and 1., into JSON number literals.
+func jsonNumber(n string) string {
+	sign := ""
+	if n[0] == '-' || n[0] == '+' {
+		if n[0] == '-' {
+			sign = "-"
+		}
+		n = n[1:]
+	}
+	exp := ""
+	if i := strings.IndexAny(n, "eE"); i >= 0 {
+		n, exp = n[:i], n[i:]
+	}
+	intPart, fracPart := n, ""
+	if i := strings.IndexByte(n, '.'); i >= 0 {
+		intPart, fracPart = n[:i], n[i+1:]
+	}
+	if intPart = strings.TrimLeft(intPart, "0"); intPart == "" {
+		intPart = "0"
+	}
+	if fracPart != "" {
+		intPart += "." + fracPart
+	}
+	return sign + intPart + exp
+}
+
+func (c plainJSONConfig) plainBinary(b []byte) interface{} {
+	s := base64.StdEncoding.EncodeToString(b)
+	if c.binary == BinaryAsTagged {
+		return map[string]interface{}{plainBinaryKey: s}
+	}
+	return s
+}
+
+func (c plainJSONConfig) plainSet(elems []interface{}) interface{} {
+	if c.sets == SetsAsTagged {
+		return map[string]interface{}{plainSetKey: elems}
+	}
+	return elems
+}
+
+// FromPlainJSON converts an ordinary JSON document into an AttributeValue:
+// objects become M, arrays L, strings S, numbers N with their exact text,
+// booleans BOOL and null NULL. With the BinaryAsTagged or SetsAsTagged
+// options, the tagged objects written by ToPlainJSON are read back as B or as
+// sets, so documents written with them round trip.
+func FromPlainJSON(data []byte, opts ...PlainJSONOption) (*AttributeValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, DecodeError{Message: fmt.Sprintf("invalid JSON: %s", err)}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, DecodeError{Message: "invalid JSON: data after the top level value"}
+	}
+	return newPlainJSONConfig(opts).fromPlain(v)
+}
+
+func (c plainJSONConfig) fromPlain(v interface{}) (*AttributeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return nullAttribute(), nil
+	case bool:
+		return &AttributeValue{BOOL: &v}, nil
+	case string:
+		return &AttributeValue{S: &v}, nil
+	case json.Number:
+		n := string(v)
+		if err := checkNumber(n); err != nil {
+			return nil, DecodeError{Message: err.Error(), AttributeType: N}
+		}
+		return &AttributeValue{N: &n}, nil
+	case []interface{}:
+		out := make([]*AttributeValue, len(v))
+		for i, elem := range v {
+			attr, err := c.fromPlain(elem)
+			if err != nil {
+				return nil, decodeErrorAt(err, indexPath(i), nil, "")
+			}
+			out[i] = attr
+		}
+		return &AttributeValue{L: out}, nil
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if b, ok := v[plainBinaryKey]; ok && c.binary == BinaryAsTagged {
+				return fromPlainBinary(b)
+			}
+			if elems, ok := v[plainSetKey]; ok && c.sets == SetsAsTagged {
+				return c.fromPlainSet(elems)
+			}
+		}
+		out := make(AttributeValueMap, len(v))
+		for k, elem := range v {
+			attr, err := c.fromPlain(elem)
+			if err != nil {
+				return nil, decodeErrorAt(err, k, nil, "")
+			}
+			out[k] = attr
+		}
+		return &AttributeValue{M: out}, nil
+	}
+	return nil, DecodeError{Message: fmt.Sprintf("unexpected JSON value %T", v)}
+}
+
+func fromPlainBinary(v interface{}) (*AttributeValue, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, DecodeError{Message: plainBinaryKey + " requires a base64 string", AttributeType: B}
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, DecodeError{Message: fmt.Sprintf("invalid %s value: %s", plainBinaryKey, err), AttributeType: B}
+	}
+	return &AttributeValue{B: b}, nil
+}
+
+// fromPlainSet converts the elements of a tagged set, which must all be
+// strings, numbers or binary values.
+func (c plainJSONConfig) fromPlainSet(v interface{}) (*AttributeValue, error) {
+	elems, ok := v.([]interface{})
+	if !ok || len(elems) == 0 {
+		return nil, DecodeError{Message: plainSetKey + " requires a non-empty array"}
+	}
+	attrs := make([]*AttributeValue, len(elems))
+	for i, elem := range elems {
+		attr, err := c.fromPlain(elem)
+		if err != nil {
+			return nil, decodeErrorAt(err, indexPath(i), nil, "")
+		}
+		if t := attr.Type(); t != S && t != N && t != B {
+			return nil, DecodeError{Message: fmt.Sprintf("%s elements must be strings, numbers or binary, not %s", plainSetKey, t.String()), Path: indexPath(i), AttributeType: t}
+		}
+		if i > 0 && attr.Type() != attrs[0].Type() {
+			return nil, DecodeError{Message: plainSetKey + " elements must all have the same type", Path: indexPath(i), AttributeType: attr.Type()}
+		}
+		attrs[i] = attr
+	}
+
+	out := &AttributeValue{}
+	for _, attr := range attrs {
+		switch attr.Type() {
+		case S:
+			out.SS = append(out.SS, attr.S)
+		case N:
+			out.NS = append(out.NS, attr.N)
+		case B:
+			out.BS = append(out.BS, attr.B)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"encoding/json"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestPlainJSON(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&PlainJSONSuite{})
+	TestingT(t)
+}
+
+type PlainJSONSuite struct {
+}
+
+// wireAttr parses an AttributeValue from dynamo's JSON format.
+func wireAttr(c *ck.C, s string) *AttributeValue {
+	attr := &AttributeValue{}
+	c.Assert(json.Unmarshal([]byte(s), attr), IsNil)
+	return attr
+}
+
+func (s *PlainJSONSuite) TestToPlainJSON(c *ck.C) {
+	attr := wireAttr(c, `{"M":{
+		"s":{"S":"a<b"},
+		"n":{"N":"12345678901234567890.5"},
+		"odd":{"L":[{"N":"+007"},{"N":".5"},{"N":"1."},{"N":"-2E+3"}]},
+		"b":{"B":"AQI="},
+		"t":{"BOOL":true},
+		"z":{"NULL":true},
+		"ss":{"SS":["x","y"]},
+		"ns":{"NS":["1","2"]},
+		"bs":{"BS":["AQ=="]},
+		"m":{"M":{}}
+	}}`)
+
+	b, err := ToPlainJSON(attr)
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{"b":"AQI=","bs":["AQ=="],"m":{},"n":12345678901234567890.5,"ns":[1,2],"odd":[7,0.5,1,-2E+3],"s":"a<b","ss":["x","y"],"t":true,"z":null}`)
+
+	b, err = ToPlainJSON(attr, PlainNumbers(NumbersAsStrings), PlainBinary(BinaryAsTagged), PlainSets(SetsAsTagged))
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{"b":{"$binary":"AQI="},"bs":{"$set":[{"$binary":"AQ=="}]},"m":{},"n":"12345678901234567890.5","ns":{"$set":["1","2"]},"odd":["+007",".5","1.","-2E+3"],"s":"a<b","ss":{"$set":["x","y"]},"t":true,"z":null}`)
+}
+
+func (s *PlainJSONSuite) TestFromPlainJSON(c *ck.C) {
+	attr, err := FromPlainJSON([]byte(`{"a":[1.50,"x",true,null,{}],"big":123456789012345678901234567890}`))
+	c.Assert(err, IsNil)
+	c.Check(attr, DeepEquals, wireAttr(c, `{"M":{
+		"a":{"L":[{"N":"1.50"},{"S":"x"},{"BOOL":true},{"NULL":true},{"M":{}}]},
+		"big":{"N":"123456789012345678901234567890"}
+	}}`))
+
+	// tagged values are only recognized with the matching options
+	doc := []byte(`{"b":{"$binary":"AQI="},"s":{"$set":[1,2]}}`)
+	attr, err = FromPlainJSON(doc)
+	c.Assert(err, IsNil)
+	c.Check(attr.M["b"].M["$binary"], DeepEquals, wireAttr(c, `{"S":"AQI="}`))
+
+	attr, err = FromPlainJSON(doc, PlainBinary(BinaryAsTagged), PlainSets(SetsAsTagged))
+	c.Assert(err, IsNil)
+	c.Check(attr, DeepEquals, wireAttr(c, `{"M":{"b":{"B":"AQI="},"s":{"NS":["1","2"]}}}`))
+}
+
+func (s *PlainJSONSuite) TestRoundTrip(c *ck.C) {
+	opts := []PlainJSONOption{PlainBinary(BinaryAsTagged), PlainSets(SetsAsTagged)}
+	attr := wireAttr(c, `{"M":{"b":{"B":"AQI="},"bs":{"BS":["AQ==","Ag=="]},"ss":{"SS":["x"]},"l":{"L":[{"NS":["1.5"]}]}}}`)
+
+	b, err := ToPlainJSON(attr, opts...)
+	c.Assert(err, IsNil)
+	back, err := FromPlainJSON(b, opts...)
+	c.Assert(err, IsNil)
+	c.Check(back, DeepEquals, attr)
+}
+
+func (s *PlainJSONSuite) TestErrors(c *ck.C) {
+	bad := "1x"
+	_, err := ToPlainJSON(&AttributeValue{M: AttributeValueMap{"a": {L: []*AttributeValue{{N: &bad}}}}})
+	c.Check(err, ErrorMatches, `aws.dynamodb.EncodeError: a\[0\]: malformed number "1x"`)
+
+	_, err = ToPlainJSON(&AttributeValue{})
+	c.Check(err, ErrorMatches, ".*AttributeValue has no value set")
+
+	tagged := []PlainJSONOption{PlainBinary(BinaryAsTagged), PlainSets(SetsAsTagged)}
+	for _, t := range []struct {
+		doc string
+		err string
+	}{
+		{`{"a":`, ".*invalid JSON: .*"},
+		{`{} {}`, ".*invalid JSON: data after the top level value"},
+		{`{"a":[1e400]}`, `aws.dynamodb.DecodeError: a\[0\]: number 1e400 is out of range`},
+		{`{"b":{"$binary":"!"}}`, ".*b: invalid \\$binary value: .*"},
+		{`{"s":{"$set":[]}}`, ".*s: \\$set requires a non-empty array"},
+		{`{"s":{"$set":["a",1]}}`, `.*s\[1\]: \$set elements must all have the same type`},
+		{`{"s":{"$set":[[1]]}}`, `.*s\[0\]: \$set elements must be strings, numbers or binary, not L`},
+	} {
+		_, err := FromPlainJSON([]byte(t.doc), tagged...)
+		c.Check(err, ErrorMatches, t.err, ck.Commentf("%s", t.doc))
+	}
+}
@@ -0,0 +1,204 @@
+package dynamodb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A TableSchema holds the key schema and indexes of a table, with the field
+// names of the CreateTable request parameters so that it encodes to their wire
+// JSON. TableName and any capacity settings are left to the caller.
+type TableSchema struct {
+	TableName              string                 `json:",omitempty"`
+	AttributeDefinitions   []AttributeDefinition  `json:",omitempty"`
+	KeySchema              []KeySchemaElement     `json:",omitempty"`
+	GlobalSecondaryIndexes []SecondaryIndexSchema `json:",omitempty"`
+	LocalSecondaryIndexes  []SecondaryIndexSchema `json:",omitempty"`
+}
+
+// An AttributeDefinition declares the type of a key attribute, which is S, N
+// or B.
+type AttributeDefinition struct {
+	AttributeName string
+	AttributeType string
+}
+
+// A KeySchemaElement names a key attribute and its KeyType, HASH or RANGE.
+type KeySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type SecondaryIndexSchema struct {
+	IndexName  string
+	KeySchema  []KeySchemaElement
+	Projection ProjectionSchema
+}
+
+// A ProjectionSchema lists the attributes copied into an index. ProjectionType
+// is ALL, KEYS_ONLY or INCLUDE, and NonKeyAttributes is only set for INCLUDE.
+type ProjectionSchema struct {
+	ProjectionType   string
+	NonKeyAttributes []string `json:",omitempty"`
+}
+
+// TableSchemaFor returns the schema of a table holding items of the struct type
+// of v, which may be a struct, a pointer to one or its reflect.Type, from the
+// key and index tag options described in KeyOf.
+//
+// Attribute types are inferred from the Go types of the key fields: strings
+// and text marshalers are S, numbers N, and byte slices B, following the field
+// options. Indexes project ALL attributes unless non-key fields are tagged with
+// project=Name, which makes index Name an INCLUDE projection of those fields.
+func TableSchemaFor(v interface{}) (*TableSchema, error) {
+	return defaultEncoder.TableSchemaFor(v)
+}
+
+// TableSchemaFor is like the package function of the same name, but applies
+// the Encoder's TagName and TimeFormat options.
+func (enc *Encoder) TableSchemaFor(v interface{}) (*TableSchema, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, EncodeError{Message: fmt.Sprintf("cannot get the table schema of type %v", t)}
+	}
+
+	c := enc.codecs
+	ks, err := c.keySchema(t)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &TableSchema{}
+	types := map[string]AttributeValueType{}
+	define := func(kf *keyField) error {
+		if kf == nil {
+			return nil
+		}
+		if _, ok := types[kf.name]; ok {
+			return nil
+		}
+		at, err := c.keyAttributeType(kf.field, typeByIndex(t, kf.index))
+		if err != nil {
+			return encodeErrorAt(err, kf.name, kf.owner, kf.goName)
+		}
+		types[kf.name] = at
+		schema.AttributeDefinitions = append(schema.AttributeDefinitions, AttributeDefinition{kf.name, at.String()})
+		return nil
+	}
+	keyElements := func(is *indexSchema) ([]KeySchemaElement, error) {
+		if err := define(is.hash); err != nil {
+			return nil, err
+		}
+		elems := []KeySchemaElement{{is.hash.name, "HASH"}}
+		if is.rng != nil {
+			if err := define(is.rng); err != nil {
+				return nil, err
+			}
+			elems = append(elems, KeySchemaElement{is.rng.name, "RANGE"})
+		}
+		return elems, nil
+	}
+
+	if schema.KeySchema, err = keyElements(&ks.table); err != nil {
+		return nil, err
+	}
+	projections, err := c.projections(t, ks)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ks.indexes {
+		is := &ks.indexes[i]
+		elems, err := keyElements(is)
+		if err != nil {
+			return nil, err
+		}
+		index := SecondaryIndexSchema{IndexName: is.name, KeySchema: elems, Projection: ProjectionSchema{ProjectionType: "ALL"}}
+		if names := projections[is.name]; len(names) > 0 {
+			index.Projection = ProjectionSchema{ProjectionType: "INCLUDE", NonKeyAttributes: names}
+		}
+		if is.local {
+			schema.LocalSecondaryIndexes = append(schema.LocalSecondaryIndexes, index)
+		} else {
+			schema.GlobalSecondaryIndexes = append(schema.GlobalSecondaryIndexes, index)
+		}
+	}
+	sort.Slice(schema.AttributeDefinitions, func(i, j int) bool {
+		return schema.AttributeDefinitions[i].AttributeName < schema.AttributeDefinitions[j].AttributeName
+	})
+	return schema, nil
+}
+
+// projections returns the names of the non-key attributes tagged with the
+// project option, by index.
+func (c *codecSet) projections(t reflect.Type, ks *keySchema) (map[string][]string, error) {
+	isKey := func(name string) bool {
+		for _, is := range append([]indexSchema{ks.table}, ks.indexes...) {
+			if is.hash.name == name || (is.rng != nil && is.rng.name == name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	projections := map[string][]string{}
+	for _, f := range cachedTypeFields(t, c.tagName) {
+		for _, name := range f.project {
+			if ks.index(name) == nil {
+				return nil, EncodeError{Message: fmt.Sprintf("invalid option project=%s on %s.%s, type %s has no index %s", name, t.String(), f.goName, t.String(), name), Struct: t}
+			}
+			if !isKey(f.name) {
+				projections[name] = append(projections[name], f.name)
+			}
+		}
+	}
+	return projections, nil
+}
+
+// keyAttributeType returns the type of attribute a key field of type t is
+// encoded as.
+func (c *codecSet) keyAttributeType(f field, t reflect.Type) (AttributeValueType, error) {
+	if t.Kind() == reflect.Ptr && !c.overrides(t) {
+		t = t.Elem()
+	}
+	implements := func(it reflect.Type) bool {
+		return t.Implements(it) || reflect.PtrTo(t).Implements(it)
+	}
+
+	switch {
+	case f.unixTime:
+		return N, nil
+	case f.binary:
+		return B, nil
+	case f.quoted && isQuotable(t):
+		return S, nil
+	case t == timeType && c.timeFormat != "":
+		return S, nil
+	case c.overrides(t) || implements(MarshalerType):
+		return NULL, EncodeError{Message: fmt.Sprintf("cannot infer the key attribute type of %s, which has a custom encoding", t.String())}
+	case isNumberType(t):
+		return N, nil
+	case implements(JSONMarshalerType):
+		return B, nil
+	case implements(TextMarshalerType):
+		return S, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return S, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return N, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return B, nil
+		}
+	}
+	return NULL, EncodeError{Message: fmt.Sprintf("cannot infer the key attribute type of %s", t.String())}
+}
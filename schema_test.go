@@ -0,0 +1,103 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestSchema(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&SchemaSuite{})
+	TestingT(t)
+}
+
+type SchemaSuite struct {
+}
+
+type event struct {
+	Stream  string    `dynamodb:"stream,hashkey"`
+	Seq     uint64    `dynamodb:"seq,rangekey"`
+	Kind    string    `dynamodb:"kind,gsi=ByKind:hash,gsi=ByKindTime:hash"`
+	At      time.Time `dynamodb:"at,unixtime,gsi=ByKindTime:range,lsi=ByTime"`
+	Digest  []byte    `dynamodb:"digest,gsi=ByDigest:hash"`
+	Payload string    `dynamodb:"payload,project=ByKind"`
+	Source  string    `dynamodb:"source,project=ByKind,project=ByTime"`
+}
+
+func (s *SchemaSuite) TestTableSchemaFor(c *ck.C) {
+	schema, err := TableSchemaFor(reflect.TypeOf(event{}))
+	c.Assert(err, IsNil)
+
+	b, err := json.Marshal(schema)
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `{`+
+		`"AttributeDefinitions":[`+
+		`{"AttributeName":"at","AttributeType":"N"},`+
+		`{"AttributeName":"digest","AttributeType":"B"},`+
+		`{"AttributeName":"kind","AttributeType":"S"},`+
+		`{"AttributeName":"seq","AttributeType":"N"},`+
+		`{"AttributeName":"stream","AttributeType":"S"}],`+
+		`"KeySchema":[{"AttributeName":"stream","KeyType":"HASH"},{"AttributeName":"seq","KeyType":"RANGE"}],`+
+		`"GlobalSecondaryIndexes":[`+
+		`{"IndexName":"ByDigest","KeySchema":[{"AttributeName":"digest","KeyType":"HASH"}],"Projection":{"ProjectionType":"ALL"}},`+
+		`{"IndexName":"ByKind","KeySchema":[{"AttributeName":"kind","KeyType":"HASH"}],"Projection":{"ProjectionType":"INCLUDE","NonKeyAttributes":["payload","source"]}},`+
+		`{"IndexName":"ByKindTime","KeySchema":[{"AttributeName":"kind","KeyType":"HASH"},{"AttributeName":"at","KeyType":"RANGE"}],"Projection":{"ProjectionType":"ALL"}}],`+
+		`"LocalSecondaryIndexes":[`+
+		`{"IndexName":"ByTime","KeySchema":[{"AttributeName":"stream","KeyType":"HASH"},{"AttributeName":"at","KeyType":"RANGE"}],"Projection":{"ProjectionType":"INCLUDE","NonKeyAttributes":["source"]}}]`+
+		`}`)
+
+	// a value or pointer gives the same schema
+	fromPtr, err := TableSchemaFor(&event{})
+	c.Assert(err, IsNil)
+	c.Check(fromPtr, DeepEquals, schema)
+}
+
+func (s *SchemaSuite) TestAttributeTypes(c *ck.C) {
+	type item struct {
+		PK  int64     `dynamodb:"pk,string,hashkey"`
+		A   *float64  `dynamodb:"a,gsi=A:hash"`
+		B   string    `dynamodb:"b,binary,gsi=B:hash"`
+		N   Number    `dynamodb:"n,gsi=N:hash"`
+		T   time.Time `dynamodb:"t,gsi=T:hash"`
+		Key keyID     `dynamodb:"key,gsi=Key:hash"`
+	}
+	schema, err := TableSchemaFor(item{})
+	c.Assert(err, IsNil)
+	c.Check(schema.AttributeDefinitions, DeepEquals, []AttributeDefinition{
+		{"a", "N"}, {"b", "B"}, {"key", "S"}, {"n", "N"}, {"pk", "S"}, {"t", "B"},
+	})
+
+	schema, err = NewEncoder(TimeFormat(time.RFC3339)).TableSchemaFor(item{})
+	c.Assert(err, IsNil)
+	c.Check(schema.AttributeDefinitions[5], DeepEquals, AttributeDefinition{"t", "S"})
+}
+
+func (s *SchemaSuite) TestSchemaErrors(c *ck.C) {
+	type custom struct {
+		PK money `dynamodb:"pk,hashkey"`
+	}
+	_, err := TableSchemaFor(custom{})
+	c.Check(err, ErrorMatches, ".*pk: cannot infer the key attribute type of dynamodb_test.money, which has a custom encoding")
+
+	type list struct {
+		PK []string `dynamodb:"pk,hashkey"`
+	}
+	_, err = TableSchemaFor(list{})
+	c.Check(err, ErrorMatches, ".*pk: cannot infer the key attribute type of \\[\\]string")
+
+	type badProjection struct {
+		PK   string `dynamodb:"pk,hashkey"`
+		Data string `dynamodb:"data,project=Missing"`
+	}
+	_, err = TableSchemaFor(badProjection{})
+	c.Check(err, ErrorMatches, ".*invalid option project=Missing on .*badProjection.Data.*")
+
+	_, err = TableSchemaFor(42)
+	c.Check(err, ErrorMatches, ".*cannot get the table schema of type int")
+}
@@ -0,0 +1,115 @@
+package dynamodb
+
+import "strings"
+
+// MaxItemSize is the largest item dynamo stores, as counted by ItemSize.
+const MaxItemSize = 400 * 1024
+
+const (
+	// containerOverhead is the size of an L or M, regardless of its contents
+	containerOverhead = 3
+	// elementOverhead is added for each element of an L or M
+	elementOverhead = 1
+)
+
+// Size returns the number of bytes dynamo counts for the value: the UTF-8
+// length of strings, the length of binary values, about one byte per two
+// significant digits of numbers, one byte for BOOL and NULL, and the sum of
+// the elements of sets. An L or M takes 3 bytes plus 1 byte per element in
+// addition to its elements, whose names count for an M.
+func (a *AttributeValue) Size() int {
+	if a == nil {
+		return 0
+	}
+	switch a.Type() {
+	case B:
+		return len(a.B)
+	case BOOL, NULL:
+		return 1
+	case S:
+		return len(*a.S)
+	case N:
+		return numberSize(*a.N)
+	case M:
+		size := containerOverhead
+		for k, v := range a.M {
+			size += len(k) + v.Size() + elementOverhead
+		}
+		return size
+	case L:
+		size := containerOverhead
+		for _, v := range a.L {
+			size += v.Size() + elementOverhead
+		}
+		return size
+	case SS:
+		size := 0
+		for _, s := range a.SS {
+			size += len(*s)
+		}
+		return size
+	case NS:
+		size := 0
+		for _, n := range a.NS {
+			size += numberSize(*n)
+		}
+		return size
+	case BS:
+		size := 0
+		for _, b := range a.BS {
+			size += len(b)
+		}
+		return size
+	}
+	return 0
+}
+
+// ItemSize returns the size dynamo counts for an item, the sum of its
+// attribute names and the sizes of their values. Items larger than
+// MaxItemSize are rejected.
+func ItemSize(item AttributeValueMap) int {
+	size := 0
+	for k, v := range item {
+		size += len(k) + v.Size()
+	}
+	return size
+}
+
+// numberSize returns the size of a number, one byte per two significant
+// digits plus one byte, and another for negative numbers.
+func numberSize(n string) int {
+	size := 1
+	if strings.HasPrefix(n, "-") {
+		size++
+	}
+	if i := strings.IndexAny(n, "eE"); i >= 0 {
+		n = n[:i]
+	}
+	n = strings.TrimLeft(n, "+-")
+	n = strings.Replace(n, ".", "", 1)
+	digits := len(strings.Trim(n, "0"))
+	return size + (digits+1)/2
+}
+
+// WriteCapacityUnits returns the write capacity units consumed writing an item
+// of the given size: one per KB, rounded up.
+func WriteCapacityUnits(itemSize int) int {
+	if itemSize <= 0 {
+		return 1
+	}
+	return (itemSize + 1023) / 1024
+}
+
+// ReadCapacityUnits returns the read capacity units consumed reading an item of
+// the given size: one per 4 KB, rounded up, or half that for eventually
+// consistent reads.
+func ReadCapacityUnits(itemSize int, consistent bool) float64 {
+	units := 1
+	if itemSize > 0 {
+		units = (itemSize + 4095) / 4096
+	}
+	if consistent {
+		return float64(units)
+	}
+	return float64(units) / 2
+}
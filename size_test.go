@@ -0,0 +1,71 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"strings"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestSize(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&SizeSuite{})
+	TestingT(t)
+}
+
+type SizeSuite struct {
+}
+
+func (s *SizeSuite) TestSize(c *ck.C) {
+	for _, t := range []struct {
+		attr string
+		size int
+	}{
+		{`{"S":"abc"}`, 3},
+		{`{"S":"héllo"}`, 6},
+		{`{"S":""}`, 0},
+		{`{"B":"AQID"}`, 3},
+		{`{"BOOL":false}`, 1},
+		{`{"NULL":true}`, 1},
+		{`{"N":"0"}`, 1},
+		{`{"N":"7"}`, 2},
+		{`{"N":"12"}`, 2},
+		{`{"N":"123"}`, 3},
+		{`{"N":"-123"}`, 4},
+		{`{"N":"001.2300"}`, 3},
+		{`{"N":"1.5E+10"}`, 2},
+		{`{"N":"12345678901234567890123456789012345678"}`, 20},
+		{`{"SS":["a","bc"]}`, 3},
+		{`{"NS":["1","-22"]}`, 5},
+		{`{"BS":["AQ==","AQI="]}`, 3},
+		{`{"L":[]}`, 3},
+		{`{"M":{}}`, 3},
+		{`{"L":[{"S":"ab"},{"NULL":true}]}`, 3 + 2 + 1 + 1 + 1},
+		{`{"M":{"key":{"S":"ab"}}}`, 3 + 3 + 2 + 1},
+		{`{"M":{"a":{"L":[{"BOOL":true}]}}}`, 3 + 1 + (3 + 1 + 1) + 1},
+	} {
+		c.Check(wireAttr(c, t.attr).Size(), Equals, t.size, ck.Commentf("%s", t.attr))
+	}
+	c.Check((*AttributeValue)(nil).Size(), Equals, 0)
+}
+
+func (s *SizeSuite) TestItemSize(c *ck.C) {
+	item := wireAttr(c, `{"M":{"id":{"S":"abc"},"count":{"N":"42"},"tags":{"L":[{"S":"x"}]}}}`).M
+	c.Check(ItemSize(item), Equals, (2+3)+(5+2)+(4+3+1+1))
+
+	// the item limit counts names as well as values
+	big := strings.Repeat("x", MaxItemSize-2)
+	c.Check(ItemSize(AttributeValueMap{"ab": {S: &big}}), Equals, MaxItemSize)
+}
+
+func (s *SizeSuite) TestCapacityUnits(c *ck.C) {
+	c.Check(WriteCapacityUnits(0), Equals, 1)
+	c.Check(WriteCapacityUnits(1024), Equals, 1)
+	c.Check(WriteCapacityUnits(1025), Equals, 2)
+	c.Check(ReadCapacityUnits(100, true), Equals, 1.0)
+	c.Check(ReadCapacityUnits(100, false), Equals, 0.5)
+	c.Check(ReadCapacityUnits(4097, true), Equals, 2.0)
+	c.Check(ReadCapacityUnits(9000, false), Equals, 1.5)
+}
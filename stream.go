@@ -0,0 +1,467 @@
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// A StreamEncoder writes Go values to an io.Writer in dynamo's JSON format.
+// Like Encode, it writes values directly rather than building an
+// AttributeValue first; only values with custom encodings, such as Marshalers,
+// are converted to an AttributeValue on the way.
+type StreamEncoder struct {
+	enc *Encoder
+	w   io.Writer
+	buf []byte
+}
+
+// NewStreamEncoder returns a StreamEncoder using the default options.
+func NewStreamEncoder(w io.Writer) *StreamEncoder {
+	return defaultEncoder.NewStreamEncoder(w)
+}
+
+// NewStreamEncoder returns a StreamEncoder that applies the Encoder's options.
+func (enc *Encoder) NewStreamEncoder(w io.Writer) *StreamEncoder {
+	return &StreamEncoder{enc: enc, w: w}
+}
+
+// Encode writes v as an AttributeValue, as Encode would, followed by a newline.
+func (se *StreamEncoder) Encode(v interface{}) error {
+	b, err := se.enc.appendValue(se.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	return se.write(b)
+}
+
+// EncodeItem writes v, which must encode as an M, as an item: a JSON object of
+// attributes like the Items of a Query result, followed by a newline.
+func (se *StreamEncoder) EncodeItem(v interface{}) error {
+	b, err := se.enc.appendValue(se.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	if len(b) < len(`{"M":}`) || string(b[:5]) != `{"M":` {
+		return EncodeError{Message: fmt.Sprintf("cannot encode type %T as an item", v)}
+	}
+	return se.write(b[5 : len(b)-1])
+}
+
+func (se *StreamEncoder) write(b []byte) error {
+	b = append(b, '\n')
+	_, err := se.w.Write(b)
+	se.buf = b[:0]
+	return err
+}
+
+// A StreamDecoder reads Go values from an io.Reader in dynamo's JSON format.
+// Structs, maps, slices and arrays are decoded directly from the input rather
+// than from an AttributeValue tree; only values decoded by types with custom
+// decoding, such as Unmarshalers, and scalars are parsed into an
+// AttributeValue first.
+//
+// A stream may hold any sequence of values, such as newline-delimited items.
+// Token and More can be used to step into a larger document, like a Query
+// result, and decode its items one by one:
+//
+//	sd := dynamodb.NewStreamDecoder(r)
+//	sd.Token() // {
+//	for sd.More() {
+//	    name, _ := sd.Token()
+//	    if name != "Items" {
+//	        sd.Skip()
+//	        continue
+//	    }
+//	    sd.Token() // [
+//	    for sd.More() {
+//	        var o Order
+//	        err := sd.DecodeItem(&o)
+//	    }
+//	    sd.Token() // ]
+//	}
+type StreamDecoder struct {
+	dec *Decoder
+	r   *json.Decoder
+}
+
+// NewStreamDecoder returns a StreamDecoder using the default options.
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	return defaultDecoder.NewStreamDecoder(r)
+}
+
+// NewStreamDecoder returns a StreamDecoder that applies the Decoder's options.
+func (dec *Decoder) NewStreamDecoder(r io.Reader) *StreamDecoder {
+	jd := json.NewDecoder(r)
+	jd.UseNumber()
+	return &StreamDecoder{dec: dec, r: jd}
+}
+
+// Decode reads the next AttributeValue, as written by Encode, into the value
+// pointed to by item. It returns io.EOF at the end of the input.
+func (sd *StreamDecoder) Decode(item interface{}) error {
+	return sd.decode(item, false)
+}
+
+// DecodeItem reads the next item, a JSON object of attributes as written by
+// EncodeItem, into the value pointed to by item. It returns io.EOF at the end
+// of the input.
+func (sd *StreamDecoder) DecodeItem(item interface{}) error {
+	return sd.decode(item, true)
+}
+
+func (sd *StreamDecoder) decode(item interface{}, isItem bool) error {
+	if !sd.r.More() {
+		tok, err := sd.r.Token()
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return DecodeError{Message: err.Error()}
+		}
+		return DecodeError{Message: fmt.Sprintf("unexpected %v in place of a value", tok)}
+	}
+
+	v := reflect.ValueOf(item)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return DecodeError{Message: fmt.Sprintf("cannot decode into non-pointer type %T", item)}
+	}
+	v = v.Elem()
+
+	d := &decodeState{Decoder: sd.dec}
+	s := &streamState{sd.r}
+	f := sd.dec.codecs.streamDecoder(v.Type())
+	var err error
+	if isItem {
+		err = f(d, s, "M", v)
+	} else {
+		err = s.readAttribute(d, f, v)
+	}
+	if err != nil {
+		return err
+	}
+	return d.mismatchError()
+}
+
+// Token returns the next JSON token of the input, as json.Decoder does.
+func (sd *StreamDecoder) Token() (json.Token, error) {
+	return sd.r.Token()
+}
+
+// More reports whether there's another value in the current array or object,
+// or in the input.
+func (sd *StreamDecoder) More() bool {
+	return sd.r.More()
+}
+
+// Skip reads and discards the next JSON value.
+func (sd *StreamDecoder) Skip() error {
+	var raw json.RawMessage
+	return sd.r.Decode(&raw)
+}
+
+// streamState reads the tokens of a single stream.
+type streamState struct {
+	r *json.Decoder
+}
+
+func (s *streamState) syntaxError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if _, ok := err.(DecodeError); ok {
+		return err
+	}
+	return DecodeError{Message: err.Error()}
+}
+
+func (s *streamState) readDelim(want json.Delim) error {
+	tok, err := s.r.Token()
+	if err != nil {
+		return s.syntaxError(err)
+	}
+	if tok != want {
+		return DecodeError{Message: fmt.Sprintf("expected %v, found %v", want, tok)}
+	}
+	return nil
+}
+
+func (s *streamState) readKey() (string, error) {
+	tok, err := s.r.Token()
+	if err != nil {
+		return "", s.syntaxError(err)
+	}
+	return tok.(string), nil
+}
+
+// readAttribute reads an AttributeValue, decoding the value of its type
+// descriptor with f.
+func (s *streamState) readAttribute(d *decodeState, f streamDecoderFunc, v reflect.Value) error {
+	tok, err := s.r.Token()
+	if err != nil {
+		return s.syntaxError(err)
+	}
+	if tok != json.Delim('{') {
+		return DecodeError{Message: fmt.Sprintf("AttributeValue must be a JSON object, not %v", tok)}
+	}
+	if !s.r.More() {
+		s.r.Token()
+		return DecodeError{Message: "AttributeValue has no type descriptor"}
+	}
+	desc, err := s.readKey()
+	if err != nil {
+		return err
+	}
+	if err := f(d, s, desc, v); err != nil {
+		return err
+	}
+	if s.r.More() {
+		return DecodeError{Message: "AttributeValue must have exactly one type descriptor"}
+	}
+	return s.readDelim('}')
+}
+
+// readDescriptor reads the value of a type descriptor into an AttributeValue.
+func (s *streamState) readDescriptor(desc string) (*AttributeValue, error) {
+	var raw json.RawMessage
+	if err := s.r.Decode(&raw); err != nil {
+		return nil, s.syntaxError(err)
+	}
+	attr, err := unmarshalDescriptor(desc, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &attr, nil
+}
+
+// A streamDecoderFunc decodes the value of the type descriptor desc, the next
+// value in the stream, into v.
+type streamDecoderFunc func(d *decodeState, s *streamState, desc string, v reflect.Value) error
+
+// streamDecoder returns the cached stream decoder for a type, building it if
+// needed. See typeEncoder for how recursive types are handled.
+func (c *codecSet) streamDecoder(t reflect.Type) streamDecoderFunc {
+	if fi, ok := c.streamDecoders.Load(t); ok {
+		return fi.(streamDecoderFunc)
+	}
+
+	var (
+		wg sync.WaitGroup
+		f  streamDecoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := c.streamDecoders.LoadOrStore(t, streamDecoderFunc(func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		wg.Wait()
+		return f(d, s, desc, v)
+	}))
+	if loaded {
+		return fi.(streamDecoderFunc)
+	}
+
+	f = c.newStreamDecoder(t)
+	wg.Done()
+	c.streamDecoders.Store(t, f)
+	return f
+}
+
+// newStreamDecoder builds a stream decoder for a type. Containers are read
+// directly when the input has the matching type descriptor, and everything else
+// goes through the type's decoderFunc.
+func (c *codecSet) newStreamDecoder(t reflect.Type) streamDecoderFunc {
+	dec := c.typeDecoder(t)
+	attrDec := newAttributeStreamDecoder(dec)
+	if c.customEncoding(t, UnmarshalerType, JSONUnmarshalerType, TextUnmarshalerType) {
+		return attrDec
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return c.newStructStreamDecoder(t, attrDec)
+	case reflect.Map:
+		nameDec := newMapKeyDecoder(t.Key())
+		if nameDec == nil {
+			return attrDec
+		}
+		return c.newMapStreamDecoder(t, nameDec, attrDec)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return attrDec
+		}
+		return c.newArrayStreamDecoder(t, attrDec)
+	case reflect.Array:
+		return c.newArrayStreamDecoder(t, attrDec)
+	case reflect.Ptr:
+		elemDec := c.streamDecoder(t.Elem())
+		return func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+			if desc == "NULL" {
+				return attrDec(d, s, desc, v)
+			}
+			v.Set(reflect.New(t.Elem()))
+			return elemDec(d, s, desc, v.Elem())
+		}
+	}
+	return attrDec
+}
+
+// newAttributeStreamDecoder returns a stream decoder that reads an
+// AttributeValue and decodes it with dec.
+func newAttributeStreamDecoder(dec decoderFunc) streamDecoderFunc {
+	return func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		attr, err := s.readDescriptor(desc)
+		if err != nil {
+			return err
+		}
+		return dec(d, attr, v)
+	}
+}
+
+func (c *codecSet) newStructStreamDecoder(t reflect.Type, attrDec streamDecoderFunc) streamDecoderFunc {
+	fields := cachedTypeFields(t, c.tagName)
+	byName := make(map[string]int, len(fields))
+	decoders := make([]streamDecoderFunc, len(fields))
+	for i, f := range fields {
+		byName[f.name] = i
+		ft := typeByIndex(t, f.index)
+		if f.unixTime || f.binary || f.quoted {
+			decoders[i] = newAttributeStreamDecoder(c.newFieldDecoder(f, ft))
+		} else {
+			decoders[i] = c.streamDecoder(ft)
+		}
+	}
+
+	return func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		if desc != "M" {
+			return attrDec(d, s, desc, v)
+		}
+		if err := s.readDelim('{'); err != nil {
+			return err
+		}
+		tracking := d.tracking()
+		for s.r.More() {
+			k, err := s.readKey()
+			if err != nil {
+				return err
+			}
+			i, ok := byName[k]
+			if !ok {
+				err := s.readAttribute(d, func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+					attr, err := s.readDescriptor(desc)
+					if err == nil && d.disallowUnknownFields {
+						d.push(k, nil, "")
+						d.addMismatch(DecodeError{Message: fmt.Sprintf("unknown attribute for type %s", t.String()), Struct: t, AttributeType: attr.Type()})
+						d.pop()
+					}
+					return err
+				}, reflect.Value{})
+				if err != nil {
+					return decodeErrorAt(err, k, nil, "")
+				}
+				continue
+			}
+
+			f := &fields[i]
+			fv := settableField(v, f.index)
+			if tracking {
+				d.push(k, fieldOwner(t, f.index), f.goName)
+			}
+			err = s.readAttribute(d, decoders[i], fv)
+			if tracking {
+				d.pop()
+			}
+			if err != nil {
+				return decodeErrorAt(err, k, fieldOwner(t, f.index), f.goName)
+			}
+		}
+		return s.readDelim('}')
+	}
+}
+
+func (c *codecSet) newMapStreamDecoder(t reflect.Type, nameDec func(string) (reflect.Value, error), attrDec streamDecoderFunc) streamDecoderFunc {
+	elemDec := c.streamDecoder(t.Elem())
+	return func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		if desc != "M" {
+			return attrDec(d, s, desc, v)
+		}
+		if err := s.readDelim('{'); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		tracking := d.tracking()
+		for s.r.More() {
+			key, err := s.readKey()
+			if err != nil {
+				return err
+			}
+			kv, err := nameDec(key)
+			if err != nil {
+				return decodeErrorAt(err, key, nil, "")
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if tracking {
+				d.push(key, nil, "")
+			}
+			err = s.readAttribute(d, elemDec, value)
+			if tracking {
+				d.pop()
+			}
+			if err != nil {
+				return decodeErrorAt(err, key, nil, "")
+			}
+			v.SetMapIndex(kv, value)
+		}
+		return s.readDelim('}')
+	}
+}
+
+func (c *codecSet) newArrayStreamDecoder(t reflect.Type, attrDec streamDecoderFunc) streamDecoderFunc {
+	elemDec := c.streamDecoder(t.Elem())
+	skip := func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		_, err := s.readDescriptor(desc)
+		return err
+	}
+
+	return func(d *decodeState, s *streamState, desc string, v reflect.Value) error {
+		if desc != "L" {
+			return attrDec(d, s, desc, v)
+		}
+		if err := s.readDelim('['); err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, 0, 0))
+		}
+		tracking := d.tracking()
+		i := 0
+		for ; s.r.More(); i++ {
+			if t.Kind() == reflect.Slice {
+				v.Set(reflect.Append(v, reflect.Zero(t.Elem())))
+			}
+			if i >= v.Len() {
+				if err := s.readAttribute(d, skip, reflect.Value{}); err != nil {
+					return decodeErrorAt(err, indexPath(i), nil, "")
+				}
+				continue
+			}
+			if tracking {
+				d.push(indexPath(i), nil, "")
+			}
+			err := s.readAttribute(d, elemDec, v.Index(i))
+			if tracking {
+				d.pop()
+			}
+			if err != nil {
+				return decodeErrorAt(err, indexPath(i), nil, "")
+			}
+		}
+
+		// zero out the rest of an array
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(t.Elem()))
+		}
+		return s.readDelim(']')
+	}
+}
@@ -0,0 +1,237 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestStream(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&StreamSuite{})
+	TestingT(t)
+}
+
+type StreamSuite struct {
+}
+
+type streamOrder struct {
+	ID      string `dynamodb:"id"`
+	Lines   []streamLine
+	Tags    []string          `dynamodb:",set"`
+	Meta    map[string]string `json:",omitempty"`
+	Price   money
+	Placed  time.Time `dynamodb:",unixtime"`
+	Note    *string
+	Private int `dynamodb:"-"`
+}
+
+type streamLine struct {
+	SKU   string
+	Qty   int `dynamodb:",string"`
+	Extra interface{}
+}
+
+func streamValues() []interface{} {
+	note := "fragile & <heavy>"
+	bad := "bad \xff utf8 \u2028\t\x01\""
+	return []interface{}{
+		&Root{
+			Int1:         10,
+			String1:      "foo",
+			Map1:         map[string]Foo{"b": {2}, "a": {1}},
+			Slice1:       []Foo{{3}, {4}, {5}},
+			Array1:       [4]*Foo{{1}, nil},
+			Generic1:     map[string]interface{}{"x": []interface{}{1.5, "y", nil}},
+			GenericArray: []interface{}{true, []byte("bin")},
+			ByteSlice1:   []byte("bytes"),
+			DeepNesting:  &NestedStruct{Next: &NestedStruct{}},
+		},
+		&tree{Name: "root", Children: []*tree{{Name: "a"}, {Name: "b", Children: []*tree{{Name: "c"}}}}},
+		streamOrder{
+			ID:     "o1",
+			Lines:  []streamLine{{SKU: "s1", Qty: 2}, {SKU: bad, Extra: map[int]string{2: "two", 1: "one"}}},
+			Tags:   []string{"gift"},
+			Price:  money{150, "USD"},
+			Placed: time.Unix(1500000000, 0),
+			Note:   &note,
+		},
+		map[string][]int{"empty": {}, "nil": nil, "full": {1, 2}},
+		[]string{},
+		nil,
+		"",
+		42,
+	}
+}
+
+func (s *StreamSuite) TestEncodeMatchesEncode(c *ck.C) {
+	for _, enc := range []*Encoder{
+		NewEncoder(),
+		NewEncoder(EmptyStrings(EmptyPreserved), EmptyCollections(EmptyPreserved)),
+		NewEncoder(EmptyCollections(EmptyOmitted)),
+	} {
+		var buf bytes.Buffer
+		se := enc.NewStreamEncoder(&buf)
+		var want []string
+		for _, v := range streamValues() {
+			d, err := enc.Encode(v)
+			c.Assert(err, IsNil)
+			want = append(want, string(d))
+			c.Assert(se.Encode(v), IsNil)
+		}
+		c.Assert(strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), DeepEquals, want)
+	}
+}
+
+func (s *StreamSuite) TestEncodeErrors(c *ck.C) {
+	se := NewStreamEncoder(io.Discard)
+	err := se.Encode(streamOrder{Lines: []streamLine{{Extra: money{}}}})
+	c.Assert(err, ErrorMatches, ".*Lines\\[0\\]\\.Extra.*missing currency.*")
+	c.Assert(se.Encode(&AttributeValue{}), ErrorMatches, ".*no values set.*")
+	c.Assert(se.EncodeItem([]int{1}), ErrorMatches, ".*cannot encode type \\[\\]int as an item.*")
+}
+
+func (s *StreamSuite) TestEncodeItem(c *ck.C) {
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	c.Assert(se.EncodeItem(&tree{Name: "a"}), IsNil)
+	c.Assert(se.EncodeItem(map[string]int{"n": 1}), IsNil)
+	c.Assert(buf.String(), Equals, `{"Name":{"S":"a"}}`+"\n"+`{"n":{"N":"1"}}`+"\n")
+}
+
+func (s *StreamSuite) TestDecodeMatchesDecode(c *ck.C) {
+	var buf bytes.Buffer
+	se := NewStreamEncoder(&buf)
+	for _, v := range streamValues()[:3] {
+		c.Assert(se.Encode(v), IsNil)
+	}
+	data := bytes.Split(buf.Bytes(), []byte("\n"))
+
+	sd := NewStreamDecoder(&buf)
+	var root, wantRoot Root
+	c.Assert(sd.Decode(&root), IsNil)
+	c.Assert(Decode(data[0], &wantRoot), IsNil)
+	c.Assert(root, DeepEquals, wantRoot)
+
+	var t, wantTree tree
+	c.Assert(sd.Decode(&t), IsNil)
+	c.Assert(Decode(data[1], &wantTree), IsNil)
+	c.Assert(t, DeepEquals, wantTree)
+
+	var o, wantOrder streamOrder
+	c.Assert(sd.Decode(&o), IsNil)
+	c.Assert(Decode(data[2], &wantOrder), IsNil)
+	c.Assert(o, DeepEquals, wantOrder)
+	c.Assert(o.Lines[1].SKU, Equals, "bad \ufffd utf8 \u2028\t\x01\"")
+
+	c.Assert(sd.Decode(&o), Equals, io.EOF)
+}
+
+func (s *StreamSuite) TestDecodeInto(c *ck.C) {
+	type X struct {
+		Arr  [2]int
+		Ptr  *tree
+		Set  map[string]struct{}
+		Map  map[int]bool
+		List []interface{}
+		*Foo
+	}
+	x := X{Arr: [2]int{7, 7}, Ptr: &tree{Name: "old"}}
+	sd := NewStreamDecoder(strings.NewReader(`{"M":{
+		"Arr":{"L":[{"N":"1"}]},
+		"Ptr":{"NULL":true},
+		"Set":{"SS":["a","b"]},
+		"Map":{"M":{"3":{"BOOL":true}}},
+		"List":{"L":[{"S":"x"},{"N":"2"}]},
+		"Int1":{"N":"5"}
+	}}`))
+	c.Assert(sd.Decode(&x), IsNil)
+	c.Assert(x, DeepEquals, X{
+		Arr:  [2]int{1, 0},
+		Set:  map[string]struct{}{"a": {}, "b": {}},
+		Map:  map[int]bool{3: true},
+		List: []interface{}{"x", 2.0},
+		Foo:  &Foo{5},
+	})
+}
+
+func (s *StreamSuite) TestQueryPage(c *ck.C) {
+	page := `{
+		"Count": 2,
+		"Items": [
+			{"Name":{"S":"a"}},
+			{"Name":{"S":"b"},"Children":{"L":[{"M":{"Name":{"S":"c"}}}]}}
+		],
+		"LastEvaluatedKey": {"Name":{"S":"b"}}
+	}`
+	sd := NewStreamDecoder(strings.NewReader(page))
+	var items []tree
+	var last map[string]string
+
+	_, err := sd.Token()
+	c.Assert(err, IsNil)
+	for sd.More() {
+		name, err := sd.Token()
+		c.Assert(err, IsNil)
+		switch name {
+		case "Items":
+			_, err = sd.Token()
+			c.Assert(err, IsNil)
+			for sd.More() {
+				var t tree
+				c.Assert(sd.DecodeItem(&t), IsNil)
+				items = append(items, t)
+			}
+			_, err = sd.Token()
+			c.Assert(err, IsNil)
+		case "LastEvaluatedKey":
+			c.Assert(sd.DecodeItem(&last), IsNil)
+		default:
+			c.Assert(sd.Skip(), IsNil)
+		}
+	}
+	c.Assert(items, DeepEquals, []tree{{Name: "a"}, {Name: "b", Children: []*tree{{Name: "c"}}}})
+	c.Assert(last, DeepEquals, map[string]string{"Name": "b"})
+}
+
+func (s *StreamSuite) TestDecodeErrors(c *ck.C) {
+	for _, t := range []struct {
+		input string
+		err   string
+	}{
+		{`{"M":{"Lines":{"L":[{"M":{"Qty":{"S":"x"}}}]}}}`, `.*Lines\[0\]\.Qty.*cannot parse "x".*`},
+		{`{"M":{"Lines":{"L":[{"M":{"SKU":{"S":"a","N":"1"}}}]}}}`, `.*Lines\[0\]\.SKU.*exactly one type descriptor.*`},
+		{`{"M":{"id":{}}}`, `.*id.*no type descriptor.*`},
+		{`{"M":{"id":{"X":"a"}}}`, `.*id.*unknown type descriptor "X".*`},
+		{`{"M":{"Meta":{"M":{"k":{"N":"1e999999"}}}}}`, `.*Meta\.k.*`},
+		{`{"M":{"id":`, `.*unexpected EOF.*`},
+		{`[]`, `.*must be a JSON object.*`},
+	} {
+		var o streamOrder
+		err := NewStreamDecoder(strings.NewReader(t.input)).Decode(&o)
+		c.Assert(err, ErrorMatches, t.err, ck.Commentf("%s", t.input))
+		_, ok := err.(DecodeError)
+		c.Assert(ok, Equals, true, ck.Commentf("%s", t.input))
+	}
+
+	var o streamOrder
+	c.Assert(NewStreamDecoder(strings.NewReader(`{"S":"a"}`)).Decode(o), ErrorMatches, ".*non-pointer.*")
+}
+
+func (s *StreamSuite) TestDecodeMismatches(c *ck.C) {
+	dec := NewDecoder(DisallowUnknownFields(), StrictTypes())
+	input := `{"M":{"id":{"N":"1"},"unknown":{"S":"x"},"Lines":{"L":[{"M":{"SKU":{"S":"a"}}}]}}}`
+
+	var o streamOrder
+	err := dec.NewStreamDecoder(strings.NewReader(input)).Decode(&o)
+	want := dec.Decode([]byte(input), &streamOrder{})
+	c.Assert(err, NotNil)
+	c.Assert(err.(DecodeError).Errors(), HasLen, len(want.(DecodeError).Errors()))
+	c.Assert(o.Lines, DeepEquals, []streamLine{{SKU: "a"}})
+}
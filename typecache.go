@@ -1,11 +1,10 @@
 package dynamodb
 
 import (
+	"backflip/aws/dynamodb/internal/tags"
 	"reflect"
 	"sort"
-	"strings"
 	"sync"
-	"unicode"
 )
 
 // Typecache copied from http://golang.org/src/pkg/encoding/json/encode.go, used to help parse
@@ -41,14 +40,44 @@ func fieldByIndex(v reflect.Value, index []int) reflect.Value {
 	return v
 }
 
+func typeByIndex(t reflect.Type, index []int) reflect.Type {
+	for _, i := range index {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		t = t.Field(i).Type
+	}
+	return t
+}
+
+// fieldOwner returns the struct type that declares the field at index, which
+// differs from t for fields promoted from embedded structs.
+func fieldOwner(t reflect.Type, index []int) reflect.Type {
+	t = typeByIndex(t, index[:len(index)-1])
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
 // A field represents a single field found in a struct.
 type field struct {
 	name      string
+	goName    string
 	tag       bool
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
 	quoted    bool
+	set       bool
+	nullEmpty bool
+	binary    bool
+	unixTime  bool
+	hashKey   bool
+	rangeKey  bool
+	gsi       []string // Name:hash or Name:range for each gsi option
+	lsi       []string // Name for each lsi option
+	project   []string // Name for each project option
 }
 
 // byName sorts field by name, breaking ties with depth,
@@ -92,64 +121,10 @@ func (x byIndex) Less(i, j int) bool {
 	return len(x[i].index) < len(x[j].index)
 }
 
-func isValidTag(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, c := range s {
-		switch {
-		case strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c):
-			// Backslash and quote chars are reserved, but
-			// otherwise any punctuation chars are allowed
-			// in a tag name.
-		default:
-			if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
-				return false
-			}
-		}
-	}
-	return true
-}
-
-// tagOptions is the string following a comma in a struct field's "json"
-// tag, or the empty string. It does not include the leading comma.
-type tagOptions string
-
-// Contains returns whether checks that a comma-separated list of options
-// contains a particular substr flag. substr must be surrounded by a
-// string boundary or commas.
-func (o tagOptions) Contains(optionName string) bool {
-	if len(o) == 0 {
-		return false
-	}
-	s := string(o)
-	for s != "" {
-		var next string
-		i := strings.Index(s, ",")
-		if i >= 0 {
-			s, next = s[:i], s[i+1:]
-		}
-		if s == optionName {
-			return true
-		}
-		s = next
-	}
-	return false
-}
-
-// parseTag splits a struct field's json tag into its name and
-// comma-separated options.
-func parseTag(tag string) (string, tagOptions) {
-	if idx := strings.Index(tag, ","); idx != -1 {
-		return tag[:idx], tagOptions(tag[idx+1:])
-	}
-	return tag, tagOptions("")
-}
-
-// typeFields returns a list of fields that JSON should recognize for the given type.
+// typeFields returns a list of fields that should be recognized for the given type.
 // The algorithm is breadth-first search over the set of structs to include - the top struct
 // and then any reachable anonymous structs.
-func typeFields(t reflect.Type) []field {
+func typeFields(t reflect.Type, tagName string) []field {
 	// Anonymous fields to explore at the current level and the next.
 	current := []field{}
 	next := []field{{typ: t}}
@@ -180,14 +155,11 @@ func typeFields(t reflect.Type) []field {
 				if sf.PkgPath != "" { // unexported
 					continue
 				}
-				tag := sf.Tag.Get("json")
+				tag := tags.Lookup(sf.Tag, tagName)
 				if tag == "-" {
 					continue
 				}
-				name, opts := parseTag(tag)
-				if !isValidTag(name) {
-					name = ""
-				}
+				name, opts := tags.Parse(tag)
 				index := make([]int, len(f.index)+1)
 				copy(index, f.index)
 				index[len(f.index)] = i
@@ -204,8 +176,24 @@ func typeFields(t reflect.Type) []field {
 					if name == "" {
 						name = sf.Name
 					}
-					fields = append(fields, field{name, tagged, index, ft,
-						opts.Contains("omitempty"), opts.Contains("string")})
+					fields = append(fields, field{
+						name:      name,
+						goName:    sf.Name,
+						tag:       tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.Contains("omitempty"),
+						quoted:    opts.Contains("string"),
+						set:       opts.Contains("set"),
+						nullEmpty: opts.Contains("nullempty"),
+						binary:    opts.Contains("binary"),
+						unixTime:  opts.Contains("unixtime"),
+						hashKey:   opts.Contains("hashkey"),
+						rangeKey:  opts.Contains("rangekey"),
+						gsi:       opts.Values("gsi"),
+						lsi:       opts.Values("lsi"),
+						project:   opts.Values("project"),
+					})
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
 						// so that the annihilation code will see a duplicate.
@@ -299,15 +287,21 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
+type fieldCacheKey struct {
+	typ     reflect.Type
+	tagName string
+}
+
 var fieldCache struct {
 	sync.RWMutex
-	m map[reflect.Type][]field
+	m map[fieldCacheKey][]field
 }
 
 // cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
-func cachedTypeFields(t reflect.Type) []field {
+func cachedTypeFields(t reflect.Type, tagName string) []field {
+	key := fieldCacheKey{t, tagName}
 	fieldCache.RLock()
-	f := fieldCache.m[t]
+	f := fieldCache.m[key]
 	fieldCache.RUnlock()
 	if f != nil {
 		return f
@@ -315,16 +309,16 @@ func cachedTypeFields(t reflect.Type) []field {
 
 	// Compute fields without lock.
 	// Might duplicate effort but won't hold other computations back.
-	f = typeFields(t)
+	f = typeFields(t, tagName)
 	if f == nil {
 		f = []field{}
 	}
 
 	fieldCache.Lock()
 	if fieldCache.m == nil {
-		fieldCache.m = map[reflect.Type][]field{}
+		fieldCache.m = map[fieldCacheKey][]field{}
 	}
-	fieldCache.m[t] = f
+	fieldCache.m[key] = f
 	fieldCache.Unlock()
 	return f
 }
@@ -0,0 +1,187 @@
+package dynamodb
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// MaxNestingDepth is the number of levels of M and L dynamo allows within an
+// attribute.
+const MaxNestingDepth = 32
+
+// A Violation is a reason dynamo would reject a value.
+type Violation struct {
+	// Path is the attribute path of the offending value, such as
+	// Orders[3].Lines.sku, or empty for the value being validated.
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path != "" {
+		return v.Path + ": " + v.Message
+	}
+	return v.Message
+}
+
+// Validate checks the value against dynamo's rules and returns every violation
+// found, or nil if there are none: exactly one member must be set, numbers
+// must be well formed and in range, sets must be non-empty with distinct
+// elements, M and L may be nested at most MaxNestingDepth levels, map keys
+// must be non-empty and the value must fit in MaxItemSize.
+func (a *AttributeValue) Validate() []Violation {
+	var vs violations
+	vs.validate("", a, 0)
+	if size := a.Size(); size > MaxItemSize {
+		vs.add("", "size %d exceeds the maximum item size of %d", size, MaxItemSize)
+	}
+	return vs
+}
+
+// ValidateItem checks every attribute of an item as Validate does, and that
+// the item fits in MaxItemSize.
+func ValidateItem(item AttributeValueMap) []Violation {
+	var vs violations
+	for _, k := range sortedKeys(item) {
+		if k == "" {
+			vs.add("", "empty attribute name")
+		}
+		vs.validate(k, item[k], 0)
+	}
+	if size := ItemSize(item); size > MaxItemSize {
+		vs.add("", "item size %d exceeds the maximum of %d", size, MaxItemSize)
+	}
+	return vs
+}
+
+type violations []Violation
+
+func (vs *violations) add(path, format string, args ...interface{}) {
+	*vs = append(*vs, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (vs *violations) validate(path string, a *AttributeValue, depth int) {
+	if a == nil {
+		vs.add(path, "missing value")
+		return
+	}
+	if set := a.members(); len(set) == 0 {
+		vs.add(path, "no value set")
+		return
+	} else if len(set) > 1 {
+		vs.add(path, "more than one value set: %s", strings.Join(set, ", "))
+		return
+	}
+
+	switch a.Type() {
+	case N:
+		if err := checkNumber(*a.N); err != nil {
+			vs.add(path, "%s", err)
+		}
+	case NULL:
+		if !*a.NULL {
+			vs.add(path, "NULL must be true")
+		}
+	case M, L:
+		if depth == MaxNestingDepth {
+			vs.add(path, "nested more than %d levels deep", MaxNestingDepth)
+			return
+		}
+		if a.M != nil {
+			for _, k := range sortedKeys(a.M) {
+				if k == "" {
+					vs.add(path, "empty map key")
+				}
+				elemPath := k
+				if path != "" {
+					elemPath = path + "." + k
+				}
+				vs.validate(elemPath, a.M[k], depth+1)
+			}
+		}
+		for i, elem := range a.L {
+			vs.validate(path+indexPath(i), elem, depth+1)
+		}
+	case SS:
+		vs.validateSet(path, a.SS, func(s string) (string, error) { return s, nil })
+	case NS:
+		vs.validateSet(path, a.NS, canonicalNumber)
+	case BS:
+		elems := make([]*string, len(a.BS))
+		for i, b := range a.BS {
+			e := string(b)
+			elems[i] = &e
+		}
+		vs.validateSet(path, elems, func(s string) (string, error) { return s, nil })
+	}
+}
+
+// validateSet checks the elements of a set, using key to find duplicates.
+func (vs *violations) validateSet(path string, elems []*string, key func(string) (string, error)) {
+	if len(elems) == 0 {
+		vs.add(path, "empty set")
+		return
+	}
+	seen := make(map[string]int, len(elems))
+	for i, e := range elems {
+		if e == nil {
+			vs.add(path+indexPath(i), "missing set element")
+			continue
+		}
+		k, err := key(*e)
+		if err != nil {
+			vs.add(path+indexPath(i), "%s", err)
+			continue
+		}
+		if first, ok := seen[k]; ok {
+			vs.add(path+indexPath(i), "duplicate of set element %d", first)
+			continue
+		}
+		seen[k] = i
+	}
+}
+
+// canonicalNumber returns a form of a valid number that's equal for equal
+// numbers, such as 1, 1.0 and 10E-1.
+func canonicalNumber(n string) (string, error) {
+	if err := checkNumber(n); err != nil {
+		return "", err
+	}
+	r, ok := new(big.Rat).SetString(n)
+	if !ok {
+		return "", fmt.Errorf("malformed number %q", n)
+	}
+	return r.RatString(), nil
+}
+
+// members returns the names of the members that are set.
+func (a *AttributeValue) members() []string {
+	var set []string
+	add := func(isSet bool, name string) {
+		if isSet {
+			set = append(set, name)
+		}
+	}
+	add(a.B != nil, "B")
+	add(a.BOOL != nil, "BOOL")
+	add(a.S != nil, "S")
+	add(a.N != nil, "N")
+	add(a.NULL != nil, "NULL")
+	add(a.M != nil, "M")
+	add(a.L != nil, "L")
+	add(a.SS != nil, "SS")
+	add(a.NS != nil, "NS")
+	add(a.BS != nil, "BS")
+	return set
+}
+
+func sortedKeys(m AttributeValueMap) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
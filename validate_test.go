@@ -0,0 +1,107 @@
+package dynamodb_test
+
+import (
+	. "backflip/aws/dynamodb"
+	"backflip/tools/testutils"
+	"strings"
+	"testing"
+
+	ck "gopkg.in/check.v1"
+)
+
+func TestValidate(t *testing.T) {
+	_ = testutils.GetTestFlags()
+	Suite(&ValidateSuite{})
+	TestingT(t)
+}
+
+type ValidateSuite struct {
+}
+
+func (s *ValidateSuite) TestValid(c *ck.C) {
+	attr := wireAttr(c, `{"M":{
+		"s":{"S":""},
+		"n":{"N":"-1.5E10"},
+		"l":{"L":[{"NULL":true},{"BOOL":false},{"B":"AQ=="}]},
+		"ss":{"SS":["a","b"]},
+		"ns":{"NS":["1","2"]},
+		"bs":{"BS":["AQ==","Ag=="]}
+	}}`)
+	c.Check(attr.Validate(), IsNil)
+	c.Check(ValidateItem(attr.M), IsNil)
+}
+
+func (s *ValidateSuite) TestViolations(c *ck.C) {
+	// these values can't be parsed from JSON, which rejects most of them
+	str := func(s string) *string { return &s }
+	strs := func(ss ...string) []*string {
+		out := make([]*string, len(ss))
+		for i := range ss {
+			out[i] = &ss[i]
+		}
+		return out
+	}
+	f := false
+	attr := &AttributeValue{M: AttributeValueMap{
+		"empty": {},
+		"nan":   {N: str("NaN")},
+		"huge":  {N: str("1E200")},
+		"null":  {NULL: &f},
+		"l": {L: []*AttributeValue{
+			{SS: []*string{}},
+			{SS: strs("a", "", "a", "")},
+		}},
+		"ns":  {NS: strs("1", "1.0", "10E-1", "2", "x", "")},
+		"bs":  {BS: [][]byte{{1}, {1}, {}}},
+		"":    {S: str("x")},
+		"two": {N: str("1"), S: str("a")},
+		"nil": nil,
+	}}
+
+	c.Check(attr.Validate(), DeepEquals, []Violation{
+		{"", "empty map key"},
+		{"bs[1]", "duplicate of set element 0"},
+		{"empty", "no value set"},
+		{"huge", "number 1E200 is out of range"},
+		{"l[0]", "empty set"},
+		{"l[1][2]", "duplicate of set element 0"},
+		{"l[1][3]", "duplicate of set element 1"},
+		{"nan", `malformed number "NaN"`},
+		{"nil", "missing value"},
+		{"ns[1]", "duplicate of set element 0"},
+		{"ns[2]", "duplicate of set element 0"},
+		{"ns[4]", `malformed number "x"`},
+		{"ns[5]", `malformed number ""`},
+		{"null", "NULL must be true"},
+		{"two", "more than one value set: S, N"},
+	})
+
+	vs := ValidateItem(AttributeValueMap{"": {S: str("a")}})
+	c.Check(vs, DeepEquals, []Violation{{"", "empty attribute name"}})
+	c.Check(vs[0].String(), Equals, "empty attribute name")
+	c.Check(Violation{"a.b", "empty set"}.String(), Equals, "a.b: empty set")
+}
+
+func (s *ValidateSuite) TestNestingDepth(c *ck.C) {
+	nest := func(levels int) *AttributeValue {
+		attr := &AttributeValue{S: new(string)}
+		for i := 0; i < levels; i++ {
+			attr = &AttributeValue{L: []*AttributeValue{attr}}
+		}
+		return attr
+	}
+	c.Check(nest(MaxNestingDepth).Validate(), IsNil)
+
+	vs := nest(MaxNestingDepth + 1).Validate()
+	c.Assert(vs, HasLen, 1)
+	c.Check(vs[0].Path, Equals, strings.Repeat("[0]", MaxNestingDepth))
+	c.Check(vs[0].Message, Equals, "nested more than 32 levels deep")
+}
+
+func (s *ValidateSuite) TestSize(c *ck.C) {
+	big := strings.Repeat("x", MaxItemSize)
+	c.Check(ValidateItem(AttributeValueMap{"a": {S: &big}}), DeepEquals, []Violation{
+		{"", "item size 409601 exceeds the maximum of 409600"},
+	})
+	c.Check((&AttributeValue{S: &big}).Validate(), IsNil)
+}
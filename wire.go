@@ -0,0 +1,477 @@
+package dynamodb
+
+import (
+	"encoding/base64"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// appendValue appends the dynamo JSON encoding of item to b.
+func (enc *Encoder) appendValue(b []byte, item interface{}) ([]byte, error) {
+	if av, ok := item.(*AttributeValue); ok {
+		return appendAttribute(b, av)
+	}
+	v := reflect.ValueOf(item)
+	if !v.IsValid() {
+		return append(b, `{"NULL":true}`...), nil
+	}
+
+	e := &encodeState{Encoder: enc}
+	start := len(b)
+	b, err := enc.codecs.wireEncoder(v.Type())(e, b, v)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == start {
+		// an omitted empty value
+		b = append(b, `{"NULL":true}`...)
+	}
+	return b, nil
+}
+
+// A wireEncoderFunc appends the dynamo JSON encoding of a value to b. It
+// returns b unchanged if the value should be omitted.
+type wireEncoderFunc func(e *encodeState, b []byte, v reflect.Value) ([]byte, error)
+
+// wireEncoder returns the cached wire encoder for a type, building it if
+// needed. See typeEncoder for how recursive types are handled.
+func (c *codecSet) wireEncoder(t reflect.Type) wireEncoderFunc {
+	if fi, ok := c.wireEncoders.Load(t); ok {
+		return fi.(wireEncoderFunc)
+	}
+
+	var (
+		wg sync.WaitGroup
+		f  wireEncoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := c.wireEncoders.LoadOrStore(t, wireEncoderFunc(func(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+		wg.Wait()
+		return f(e, b, v)
+	}))
+	if loaded {
+		return fi.(wireEncoderFunc)
+	}
+
+	f = c.newWireEncoder(t)
+	wg.Done()
+	c.wireEncoders.Store(t, f)
+	return f
+}
+
+// newWireEncoder builds a wire encoder for a type. Scalars and containers are
+// written directly, and types with their own encoding go through the type's
+// encoderFunc.
+func (c *codecSet) newWireEncoder(t reflect.Type) wireEncoderFunc {
+	if c.customEncoding(t, MarshalerType, JSONMarshalerType, TextMarshalerType) {
+		return newAttributeWireEncoder(c.typeEncoder(t))
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolWireEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intWireEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintWireEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatWireEncoder
+	case reflect.String:
+		return stringWireEncoder
+	case reflect.Struct:
+		return c.newStructWireEncoder(t)
+	case reflect.Map:
+		keyEnc := newMapKeyEncoder(t.Key())
+		if isSetMapType(t) || keyEnc == nil {
+			return newAttributeWireEncoder(c.typeEncoder(t))
+		}
+		return mapWireEncoder{keyEnc, c.wireEncoder(t.Elem())}.encode
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return newAttributeWireEncoder(bytesEncoder)
+		}
+		return c.newSliceWireEncoder(t)
+	case reflect.Array:
+		return arrayWireEncoder{c.wireEncoder(t.Elem())}.encode
+	case reflect.Ptr:
+		elemEnc := c.wireEncoder(t.Elem())
+		return func(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+			if v.IsNil() {
+				return append(b, `{"NULL":true}`...), nil
+			}
+			return elemEnc(e, b, v.Elem())
+		}
+	case reflect.Interface:
+		return func(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+			if v.IsNil() {
+				return append(b, `{"NULL":true}`...), nil
+			}
+			ev := v.Elem()
+			return c.wireEncoder(ev.Type())(e, b, ev)
+		}
+	}
+	return newAttributeWireEncoder(c.typeEncoder(t))
+}
+
+// customEncoding reports whether values of type t have their own encoding,
+// from the config or from implementing one of marshalers, rather than the
+// default one for their kind.
+func (c *codecSet) customEncoding(t reflect.Type, marshalers ...reflect.Type) bool {
+	if c.overrides(t) || isNumberType(t) {
+		return true
+	}
+	if t.Kind() == reflect.Ptr && (c.overrides(t.Elem()) || isNumberType(t.Elem())) {
+		return true
+	}
+	for _, m := range marshalers {
+		if t.Implements(m) || (t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func boolWireEncoder(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	b = append(b, `{"BOOL":`...)
+	b = strconv.AppendBool(b, v.Bool())
+	return append(b, '}'), nil
+}
+
+func intWireEncoder(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	b = append(b, `{"N":"`...)
+	b = strconv.AppendInt(b, v.Int(), 10)
+	return append(b, `"}`...), nil
+}
+
+func uintWireEncoder(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	b = append(b, `{"N":"`...)
+	b = strconv.AppendUint(b, v.Uint(), 10)
+	return append(b, `"}`...), nil
+}
+
+func floatWireEncoder(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	// floats may be out of dynamo's range, which convertToNumericString checks
+	n, err := convertToNumericString(v)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, `{"N":"`...)
+	b = append(b, n...)
+	return append(b, `"}`...), nil
+}
+
+func stringWireEncoder(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	s := v.String()
+	if len(s) == 0 {
+		return appendEmpty(b, e.emptyString(&AttributeValue{S: &s}))
+	}
+	b = append(b, `{"S":`...)
+	b = appendString(b, s)
+	return append(b, '}'), nil
+}
+
+// newAttributeWireEncoder returns a wire encoder that encodes to an
+// AttributeValue with enc, and writes that.
+func newAttributeWireEncoder(enc encoderFunc) wireEncoderFunc {
+	return func(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+		attr, err := enc(e, v)
+		if err != nil || attr == nil {
+			return b, err
+		}
+		return appendAttribute(b, attr)
+	}
+}
+
+type structWireEncoder struct {
+	// fields are sorted by name, the order in which Encode writes them
+	fields   []field
+	encoders []wireEncoderFunc
+}
+
+func (c *codecSet) newStructWireEncoder(t reflect.Type) wireEncoderFunc {
+	fields := append([]field(nil), cachedTypeFields(t, c.tagName)...)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	se := structWireEncoder{fields: fields, encoders: make([]wireEncoderFunc, len(fields))}
+	for i, f := range fields {
+		ft := typeByIndex(t, f.index)
+		if f.set || f.unixTime || f.binary || f.quoted || f.nullEmpty {
+			se.encoders[i] = newAttributeWireEncoder(c.newFieldEncoder(f, ft))
+		} else {
+			se.encoders[i] = c.wireEncoder(ft)
+		}
+	}
+	return se.encode
+}
+
+func (se structWireEncoder) encode(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	b = append(b, `{"M":{`...)
+	first := true
+	for i, f := range se.fields {
+		fv := fieldByIndex(v, f.index)
+		if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		start := len(b)
+		if !first {
+			b = append(b, ',')
+		}
+		b = appendString(b, f.name)
+		b = append(b, ':')
+		valueStart := len(b)
+		var err error
+		if b, err = se.encoders[i](e, b, fv); err != nil {
+			return nil, encodeErrorAt(err, f.name, fieldOwner(v.Type(), f.index), f.goName)
+		}
+		if len(b) == valueStart {
+			b = b[:start]
+			continue
+		}
+		first = false
+	}
+	return append(b, "}}"...), nil
+}
+
+type mapWireEncoder struct {
+	keyEnc  func(k reflect.Value) (string, error)
+	elemEnc wireEncoderFunc
+}
+
+func (me mapWireEncoder) encode(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	if v.IsNil() {
+		return appendEmpty(b, e.emptyCollection(nullAttribute()))
+	}
+	if v.Len() == 0 && e.emptyCollections == EmptyOmitted {
+		return b, nil
+	}
+
+	type entry struct {
+		key string
+		v   reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := me.keyEnc(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	b = append(b, `{"M":{`...)
+	first := true
+	for _, en := range entries {
+		start := len(b)
+		if !first {
+			b = append(b, ',')
+		}
+		b = appendString(b, en.key)
+		b = append(b, ':')
+		valueStart := len(b)
+		var err error
+		if b, err = me.elemEnc(e, b, en.v); err != nil {
+			return nil, encodeErrorAt(err, en.key, nil, "")
+		}
+		if len(b) == valueStart {
+			b = b[:start]
+			continue
+		}
+		first = false
+	}
+	return append(b, "}}"...), nil
+}
+
+func (c *codecSet) newSliceWireEncoder(t reflect.Type) wireEncoderFunc {
+	arrayEnc := arrayWireEncoder{c.wireEncoder(t.Elem())}.encode
+	return func(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+		if v.IsNil() {
+			return appendEmpty(b, e.emptyCollection(nullAttribute()))
+		}
+		if v.Len() == 0 {
+			return appendEmpty(b, e.emptyCollection(&AttributeValue{L: []*AttributeValue{}}))
+		}
+		return arrayEnc(e, b, v)
+	}
+}
+
+type arrayWireEncoder struct {
+	elemEnc wireEncoderFunc
+}
+
+func (ae arrayWireEncoder) encode(e *encodeState, b []byte, v reflect.Value) ([]byte, error) {
+	b = append(b, `{"L":[`...)
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		start := len(b)
+		var err error
+		if b, err = ae.elemEnc(e, b, v.Index(i)); err != nil {
+			return nil, encodeErrorAt(err, indexPath(i), nil, "")
+		}
+		if len(b) == start {
+			b = append(b, `{"NULL":true}`...)
+		}
+	}
+	return append(b, "]}"...), nil
+}
+
+// appendEmpty appends the encoding of an empty value chosen by an EmptyPolicy,
+// which is nil if it should be omitted.
+func appendEmpty(b []byte, attr *AttributeValue) ([]byte, error) {
+	if attr == nil {
+		return b, nil
+	}
+	return appendAttribute(b, attr)
+}
+
+// appendAttribute appends the JSON encoding of attr to b, which is the same as
+// its MarshalJSON.
+func appendAttribute(b []byte, attr *AttributeValue) ([]byte, error) {
+	if attr == nil {
+		return append(b, "null"...), nil
+	}
+	var err error
+	switch {
+	case attr.B != nil:
+		b = append(b, `{"B":`...)
+		b = appendBytes(b, attr.B)
+	case attr.BOOL != nil:
+		b = append(b, `{"BOOL":`...)
+		b = strconv.AppendBool(b, *attr.BOOL)
+	case attr.S != nil:
+		b = append(b, `{"S":`...)
+		b = appendString(b, *attr.S)
+	case attr.N != nil:
+		b = append(b, `{"N":`...)
+		b = appendString(b, *attr.N)
+	case attr.NULL != nil:
+		b = append(b, `{"NULL":`...)
+		b = strconv.AppendBool(b, *attr.NULL)
+	case attr.M != nil:
+		b = append(b, `{"M":{`...)
+		for i, k := range sortedKeys(attr.M) {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendString(b, k)
+			b = append(b, ':')
+			if b, err = appendAttribute(b, attr.M[k]); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, '}')
+	case attr.L != nil:
+		b = append(b, `{"L":[`...)
+		for i, elem := range attr.L {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if b, err = appendAttribute(b, elem); err != nil {
+				return nil, err
+			}
+		}
+		b = append(b, ']')
+	case attr.SS != nil:
+		b = append(b, `{"SS":`...)
+		b = appendStrings(b, attr.SS)
+	case attr.NS != nil:
+		b = append(b, `{"NS":`...)
+		b = appendStrings(b, attr.NS)
+	case attr.BS != nil:
+		b = append(b, `{"BS":[`...)
+		for i, elem := range attr.BS {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendBytes(b, elem)
+		}
+		b = append(b, ']')
+	default:
+		return nil, EncodeError{Message: "cannot serialize an AttributeValue with no values set"}
+	}
+	return append(b, '}'), nil
+}
+
+func appendStrings(b []byte, set []*string) []byte {
+	b = append(b, '[')
+	for i, s := range set {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if s == nil {
+			b = append(b, "null"...)
+		} else {
+			b = appendString(b, *s)
+		}
+	}
+	return append(b, ']')
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	if v == nil {
+		return append(b, "null"...)
+	}
+	b = append(b, '"')
+	n := base64.StdEncoding.EncodedLen(len(v))
+	b = append(b, make([]byte, n)...)
+	base64.StdEncoding.Encode(b[len(b)-n:], v)
+	return append(b, '"')
+}
+
+const hex = "0123456789abcdef"
+
+// appendString appends s as a JSON string, escaped as encoding/json does: HTML
+// characters and U+2028 and U+2029 are escaped, and invalid UTF-8 is replaced
+// with U+FFFD.
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}